@@ -2,4 +2,7 @@ module HawkEye-Go
 
 go 1.21
 
-require github.com/dlclark/regexp2 v1.10.0
+require (
+	github.com/dlclark/regexp2 v1.10.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,77 @@
+package Engine
+
+import (
+	"HawkEye-Go/src/SqlPaser"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// QueryAllowlist 保存正常业务流量的查询模板（已知的查询结构）。
+// 模板命中时可以直接判定为白数据，不再经过贝叶斯分类器。
+type QueryAllowlist struct {
+	mu        sync.RWMutex
+	templates map[string]string // 模板哈希 -> 模板SQL
+}
+
+func NewQueryAllowlist() *QueryAllowlist {
+	return &QueryAllowlist{templates: make(map[string]string)}
+}
+
+// TemplateOf 解析SQL并返回其查询模板，多条语句作为一个整体模板化。
+// 任何一条语句有语法错误时返回错误，因为无法可靠地判断它的结构。
+func TemplateOf(sql string) (*SqlPaser.QueryTemplate, error) {
+	script := SqlPaser.ParseScript(sql)
+	if len(script.Statements) == 0 {
+		return nil, fmt.Errorf("no statement found")
+	}
+	for _, stmt := range script.Statements {
+		if len(stmt.Errors) > 0 {
+			return nil, fmt.Errorf("parse %q: %s", stmt.Text, strings.Join(stmt.Errors, "; "))
+		}
+	}
+	return SqlPaser.Templatize(script), nil
+}
+
+// Learn 把SQL的模板加入允许列表。
+func (a *QueryAllowlist) Learn(sql string) error {
+	template, err := TemplateOf(sql)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.templates[template.Hash] = template.SQL
+	return nil
+}
+
+// Contains 判断SQL的模板是否在允许列表中，无法解析的SQL不会命中。
+func (a *QueryAllowlist) Contains(sql string) bool {
+	template, err := TemplateOf(sql)
+	if err != nil {
+		return false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.templates[template.Hash]
+	return ok
+}
+
+// Templates 返回允许列表中所有模板的SQL。
+func (a *QueryAllowlist) Templates() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	templates := make([]string, 0, len(a.templates))
+	for _, sql := range a.templates {
+		templates = append(templates, sql)
+	}
+	return templates
+}
+
+// PredictWithAllowlist 返回SQL是黑数据的概率，模板在允许列表中时直接返回0。
+func (nb *NaiveBayes) PredictWithAllowlist(allowlist *QueryAllowlist, sql string) float64 {
+	if allowlist != nil && allowlist.Contains(sql) {
+		return 0
+	}
+	return nb.PredictProbability(ExtractFeatures(sql))
+}
@@ -0,0 +1,415 @@
+package Engine
+
+import (
+	"HawkEye-Go/src/SqlPaser"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// BaselineMode 决定 Baseline.Observe 如何处理查询。
+type BaselineMode int
+
+const (
+	BASELINE_LEARN   BaselineMode = iota // 把查询结构加入基线，不报告
+	BASELINE_ENFORCE                     // 报告并拦截不在基线中的结构
+	BASELINE_REPORT                      // 只报告不拦截，用于上线前观察误报
+)
+
+var baselineModeNames = []string{"learn", "enforce", "report"}
+
+func (m BaselineMode) String() string {
+	if m >= 0 && int(m) < len(baselineModeNames) {
+		return baselineModeNames[m]
+	}
+	return fmt.Sprintf("BaselineMode(%d)", int(m))
+}
+
+func (m BaselineMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *BaselineMode) UnmarshalText(text []byte) error {
+	for i, name := range baselineModeNames {
+		if strings.EqualFold(string(text), name) {
+			*m = BaselineMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown baseline mode %q", text)
+}
+
+// BaselineEntry 是基线中的一个查询结构。
+type BaselineEntry struct {
+	Template  string    `json:"template"`
+	Parsed    bool      `json:"parsed"`   // 模板是否来自AST，无法解析的查询使用令牌模板
+	Comments  int       `json:"comments"` // 学习到的查询中最多的注释数量
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	hash   string
+	tokens []string // 模板的令牌序列，用于判断新查询是否在它的基础上增加了结构
+}
+
+// BaselineFinding 是一次检查的结果。
+type BaselineFinding struct {
+	Query     string   `json:"query"`
+	Template  string   `json:"template"`
+	Known     bool     `json:"known"`             // 结构在基线中
+	Nearest   string   `json:"nearest,omitempty"` // 查询在其基础上增加了结构的已知模板
+	Additions []string `json:"additions,omitempty"`
+	Block     bool     `json:"block"` // 只有 BASELINE_ENFORCE 模式下会拦截
+	Reason    string   `json:"reason,omitempty"`
+}
+
+// Baseline 是从正常流量中学习到的查询结构（正向安全模型）。
+// 查询去掉字面量后的模板从未出现过，或者在已知模板上增加了子句、UNION、注释或语句时视为异常。
+type Baseline struct {
+	mu      sync.RWMutex
+	mode    BaselineMode
+	entries map[string]*BaselineEntry // 模板哈希 -> 结构
+	byVerb  map[string][]*BaselineEntry
+}
+
+// NewBaseline 创建空的基线。
+func NewBaseline(mode BaselineMode) *Baseline {
+	return &Baseline{mode: mode, entries: make(map[string]*BaselineEntry), byVerb: make(map[string][]*BaselineEntry)}
+}
+
+// baselineFile 是基线文件的格式。
+type baselineFile struct {
+	Mode      BaselineMode     `json:"mode"`
+	Templates []*BaselineEntry `json:"templates"`
+}
+
+// LoadBaseline 从文件加载基线。
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file baselineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	b := NewBaseline(file.Mode)
+	for _, entry := range file.Templates {
+		b.add(entry)
+	}
+	return b, nil
+}
+
+// Save 把基线写入文件，先写临时文件再改名，写入过程中不会留下不完整的文件。
+func (b *Baseline) Save(path string) error {
+	b.mu.RLock()
+	file := baselineFile{Mode: b.mode, Templates: make([]*BaselineEntry, 0, len(b.entries))}
+	for _, entry := range b.entries {
+		file.Templates = append(file.Templates, entry)
+	}
+	sort.Slice(file.Templates, func(i, j int) bool { return file.Templates[i].Template < file.Templates[j].Template })
+	data, err := json.MarshalIndent(file, "", "  ")
+	b.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// Mode 返回当前模式。
+func (b *Baseline) Mode() BaselineMode {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.mode
+}
+
+// SetMode 切换模式，例如学习一段时间后切换到 BASELINE_ENFORCE。
+func (b *Baseline) SetMode(mode BaselineMode) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mode = mode
+}
+
+// Len 返回基线中的模板数量。
+func (b *Baseline) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.entries)
+}
+
+// Templates 返回基线中的所有模板，按模板排序。
+func (b *Baseline) Templates() []BaselineEntry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	templates := make([]BaselineEntry, 0, len(b.entries))
+	for _, entry := range b.entries {
+		templates = append(templates, *entry)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Template < templates[j].Template })
+	return templates
+}
+
+// add 把结构加入索引，调用方需要持有写锁或者基线还没有共享。
+func (b *Baseline) add(entry *BaselineEntry) {
+	entry.hash = hashTemplate(entry.Template)
+	entry.tokens, _ = shapeTokens(entry.Template)
+	b.entries[entry.hash] = entry
+	if len(entry.tokens) > 0 {
+		b.byVerb[entry.tokens[0]] = append(b.byVerb[entry.tokens[0]], entry)
+	}
+}
+
+// Observe 按当前模式处理查询：学习模式下加入基线，其他模式下检查。
+func (b *Baseline) Observe(sql string) *BaselineFinding {
+	if b.Mode() == BASELINE_LEARN {
+		return b.Learn(sql)
+	}
+	return b.Check(sql)
+}
+
+// Learn 把查询的结构加入基线。
+func (b *Baseline) Learn(sql string) *BaselineFinding {
+	shape := shapeOf(sql)
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry := b.entries[shape.hash]
+	if entry == nil {
+		entry = &BaselineEntry{Template: shape.template, Parsed: shape.parsed, FirstSeen: now}
+		b.add(entry)
+	}
+	entry.Count++
+	entry.LastSeen = now
+	if shape.comments > entry.Comments {
+		entry.Comments = shape.comments
+	}
+	return &BaselineFinding{Query: sql, Template: shape.template, Known: true}
+}
+
+// Check 检查查询的结构是否在基线中，不修改基线。
+func (b *Baseline) Check(sql string) *BaselineFinding {
+	shape := shapeOf(sql)
+	finding := &BaselineFinding{Query: sql, Template: shape.template}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if entry := b.entries[shape.hash]; entry != nil {
+		if shape.comments <= entry.Comments {
+			finding.Known = true
+			return finding
+		}
+		finding.Nearest = entry.Template
+		finding.Additions = []string{"comment"}
+		finding.Reason = "adds a comment to known template " + entry.Template
+	} else if nearest, additions := b.nearest(shape); nearest != nil {
+		finding.Nearest = nearest.Template
+		if shape.comments > nearest.Comments {
+			additions = append(additions, "comment")
+		}
+		finding.Additions = additions
+		if len(additions) > 0 {
+			finding.Reason = "adds " + strings.Join(additions, ", ") + " to known template " + nearest.Template
+		} else {
+			finding.Reason = "changes known template " + nearest.Template
+		}
+	} else {
+		finding.Reason = "unknown template " + shape.template
+	}
+	finding.Block = b.mode == BASELINE_ENFORCE
+	return finding
+}
+
+// nearest 返回查询在其基础上增加了结构的已知模板，即令牌序列是查询令牌序列的子序列的最长模板，
+// 以及增加的结构。
+func (b *Baseline) nearest(shape *queryShape) (*BaselineEntry, []string) {
+	if len(shape.tokens) == 0 {
+		return nil, nil
+	}
+	var best *BaselineEntry
+	var bestAdded []int
+	for _, entry := range b.byVerb[shape.tokens[0]] {
+		if best != nil && len(entry.tokens) <= len(best.tokens) {
+			continue
+		}
+		if added, ok := subsequence(entry.tokens, shape.tokens); ok {
+			best, bestAdded = entry, added
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return best, describeAdditions(shape.tokens, bestAdded)
+}
+
+// subsequence 判断pattern是否是tokens的子序列，是时返回tokens中多出的令牌的下标。
+func subsequence(pattern, tokens []string) ([]int, bool) {
+	var added []int
+	i := 0
+	for j, token := range tokens {
+		if i < len(pattern) && pattern[i] == token {
+			i++
+		} else {
+			added = append(added, j)
+		}
+	}
+	return added, i == len(pattern)
+}
+
+// additionKinds 是增加后会改变查询含义的令牌。
+var additionKinds = map[string]string{
+	"UNION":     "UNION",
+	"UNION ALL": "UNION",
+	"OR":        "condition",
+	"AND":       "condition",
+	"XOR":       "condition",
+	"WHERE":     "clause WHERE",
+	"GROUP":     "clause GROUP BY",
+	"HAVING":    "clause HAVING",
+	"ORDER":     "clause ORDER BY",
+	"LIMIT":     "clause LIMIT",
+	"JOIN":      "clause JOIN",
+	"INTO":      "clause INTO",
+	"SELECT":    "subquery",
+}
+
+// describeAdditions 描述查询中多出的令牌，分号后还有内容时视为增加了语句。
+func describeAdditions(tokens []string, added []int) []string {
+	var additions []string
+	seen := make(map[string]bool)
+	for _, i := range added {
+		kind := additionKinds[tokens[i]]
+		if tokens[i] == ";" && i < len(tokens)-1 {
+			kind = "statement"
+		}
+		if kind != "" && !seen[kind] {
+			seen[kind] = true
+			additions = append(additions, kind)
+		}
+	}
+	return additions
+}
+
+// queryShape 是查询的结构，可以解析时使用AST模板，否则使用令牌模板。
+type queryShape struct {
+	template string
+	hash     string
+	parsed   bool
+	tokens   []string
+	comments int
+}
+
+func shapeOf(sql string) *queryShape {
+	shape := &queryShape{}
+	shape.tokens, shape.comments = shapeTokens(sql)
+	if template, err := TemplateOf(sql); err == nil {
+		shape.template, shape.parsed = template.SQL, true
+	} else {
+		shape.template = strings.Join(shape.tokens, " ")
+	}
+	shape.hash = hashTemplate(shape.template)
+	return shape
+}
+
+func hashTemplate(template string) string {
+	sum := sha256.Sum256([]byte(template))
+	return hex.EncodeToString(sum[:])
+}
+
+// shapeTokens 返回去掉字面量的令牌序列和注释数量，字面量和参数都写作 ?。
+func shapeTokens(sql string) ([]string, int) {
+	lexer := SqlPaser.NewLexer(sql)
+	var tokens []string
+	comments := 0
+	for {
+		token := lexer.NextToken()
+		switch token.Type {
+		case SqlPaser.EOF:
+			return tokens, comments
+		case SqlPaser.COMMENT:
+			comments++
+		case SqlPaser.NUMBER, SqlPaser.STRING, SqlPaser.PLACEHOLDER:
+			tokens = append(tokens, "?")
+		default:
+			tokens = append(tokens, strings.ToUpper(token.Value))
+		}
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	b := NewBaseline(BASELINE_LEARN)
+	for _, sql := range []string{
+		"SELECT name, email FROM users WHERE id = 1",
+		"SELECT name, email FROM users WHERE id = 2",
+		"SELECT id FROM orders WHERE user_id = ? AND status = 'paid' ORDER BY created DESC LIMIT 10",
+		"UPDATE users SET name = 'bob' WHERE id = 3",
+		"SELECT /* list */ id FROM items",
+	} {
+		if f := b.Observe(sql); !f.Known || f.Block {
+			t.Errorf("Learning %q returned %+v", sql, f)
+		}
+	}
+	if b.Len() != 4 {
+		t.Errorf("Expected 4 templates, got %d", b.Len())
+	}
+
+	b.SetMode(BASELINE_ENFORCE)
+	tests := []struct {
+		sql       string
+		known     bool
+		additions []string
+	}{
+		{"SELECT name, email FROM users WHERE id = 99", true, nil},
+		{"select name, email from users where id = -5", true, nil},
+		{"SELECT id FROM items", true, nil},
+		{"SELECT /* another */ id FROM items", true, nil},
+		{"SELECT name, email FROM users WHERE id = 1 OR 1 = 1", false, []string{"condition"}},
+		{"SELECT name, email FROM users WHERE id = 1 UNION SELECT user, password FROM mysql.user", false, []string{"UNION", "subquery"}},
+		{"SELECT name, email FROM users WHERE id = 1 -- ", false, []string{"comment"}},
+		{"SELECT name, email FROM users WHERE id = 1; DROP TABLE users", false, []string{"statement"}},
+		{"SELECT id FROM orders WHERE user_id = 1 AND status = 'paid' ORDER BY created DESC LIMIT 10", true, nil},
+		{"SELECT id FROM orders WHERE user_id = 1 AND status = 'x' OR 'a' = 'a' ORDER BY created DESC LIMIT 10", false, []string{"condition"}},
+		{"DELETE FROM users WHERE id = 1", false, nil},
+		// 词法分析器不认识的运算符不能截断语句
+		{"SELECT name, email FROM users WHERE id = 1|0 UNION SELECT password FROM mysql.user", false, []string{"UNION", "subquery"}},
+		{"SELECT name, email FROM users WHERE id = 1%0 OR 1=1", false, []string{"condition"}},
+	}
+	for _, tt := range tests {
+		f := b.Observe(tt.sql)
+		if f.Known != tt.known || f.Block == tt.known || strings.Join(f.Additions, ",") != strings.Join(tt.additions, ",") {
+			t.Errorf("For %q, expected known=%v additions=%v but got %+v", tt.sql, tt.known, tt.additions, f)
+		}
+	}
+
+	// 报告模式不拦截
+	b.SetMode(BASELINE_REPORT)
+	if f := b.Observe("SELECT name, email FROM users WHERE id = 1 OR 1 = 1"); f.Known || f.Block || f.Reason == "" {
+		t.Errorf("Expected report mode to report without blocking, got %+v", f)
+	}
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := b.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Mode() != BASELINE_REPORT || loaded.Len() != b.Len() {
+		t.Errorf("Expected loaded baseline to match, got mode %v with %d templates", loaded.Mode(), loaded.Len())
+	}
+	if f := loaded.Check("SELECT name, email FROM users WHERE id = 1 OR 1 = 1"); f.Known || len(f.Additions) != 1 {
+		t.Errorf("Expected loaded baseline to detect added condition, got %+v", f)
+	}
+	if templates := loaded.Templates(); len(templates) != 4 || templates[0].Count == 0 {
+		t.Errorf("Unexpected templates %+v", templates)
+	}
+}
@@ -0,0 +1,15 @@
+package Engine
+
+import "testing"
+
+// TestEngine 运行与被测代码写在同一文件中的测试函数。
+func TestEngine(t *testing.T) {
+	t.Run("Baseline", TestBaseline)
+	t.Run("NaiveBayesPersistence", TestNaiveBayesPersistence)
+	t.Run("ExtractFeatures", TestExtractFeatures)
+	t.Run("RequestInspector", TestRequestInspector)
+	t.Run("ModelStore", TestModelStore)
+	t.Run("RiskCatalog", TestRiskCatalog)
+	t.Run("RuleEngine", TestRuleEngine)
+	t.Run("DetectTimeBlind", TestDetectTimeBlind)
+}
@@ -1,8 +1,13 @@
 package Engine
 
 import (
+	"bytes"
 	"encoding/gob"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
+	"testing"
 )
 
 type NaiveBayes struct {
@@ -116,3 +121,139 @@ func LoadModelFromFile(filename string) (*NaiveBayes, error) {
 	}
 	return nb, nil
 }
+
+// naiveBayesData 是模型的序列化格式，NaiveBayes 的字段都不导出，gob无法直接编码。
+type naiveBayesData struct {
+	ClassCounts        map[string]int
+	FeatureValueCounts map[string]map[string]map[string]int
+	TotalSamples       int
+}
+
+func (nb *NaiveBayes) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(naiveBayesData{nb.classCounts, nb.featureValueCounts, nb.totalSamples})
+	return buf.Bytes(), err
+}
+
+func (nb *NaiveBayes) GobDecode(data []byte) error {
+	var decoded naiveBayesData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return err
+	}
+	*nb = *NewNaiveBayes()
+	for class, count := range decoded.ClassCounts {
+		nb.classCounts[class] = count
+	}
+	for feature, values := range decoded.FeatureValueCounts {
+		nb.featureValueCounts[feature] = values
+	}
+	nb.totalSamples = decoded.TotalSamples
+	return nil
+}
+
+// FeatureWeight 是一个特征值对分类结果的影响。
+type FeatureWeight struct {
+	Feature string  `json:"feature"`
+	Value   string  `json:"value"`
+	Black   int     `json:"black"`    // 黑样本中出现的次数
+	White   int     `json:"white"`    // 白样本中出现的次数
+	LogOdds float64 `json:"log_odds"` // 大于0时倾向黑数据
+}
+
+// ModelSummary 是模型的概况，用于检查训练结果。
+type ModelSummary struct {
+	Samples  int             `json:"samples"`
+	Classes  map[string]int  `json:"classes"`
+	Features int             `json:"features"`         // 不同的特征数量
+	Values   int             `json:"values"`           // 不同的特征值数量
+	Black    []FeatureWeight `json:"black_indicators"` // 最倾向黑数据的特征值
+	White    []FeatureWeight `json:"white_indicators"` // 最倾向白数据的特征值
+}
+
+// Summary 返回模型的概况，每个类别列出top个影响最大的特征值。
+func (nb *NaiveBayes) Summary(top int) *ModelSummary {
+	summary := &ModelSummary{Samples: nb.totalSamples, Classes: make(map[string]int), Features: len(nb.featureValueCounts)}
+	for class, count := range nb.classCounts {
+		summary.Classes[class] = count
+	}
+	var weights []FeatureWeight
+	for _, values := range nb.featureValueCounts {
+		summary.Values += len(values)
+	}
+	for _, weight := range nb.weights() {
+		weights = append(weights, weight)
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		if weights[i].LogOdds != weights[j].LogOdds {
+			return weights[i].LogOdds > weights[j].LogOdds
+		}
+		return weights[i].Feature+"="+weights[i].Value < weights[j].Feature+"="+weights[j].Value
+	})
+	for i := 0; i < len(weights) && i < top && weights[i].LogOdds > 0; i++ {
+		summary.Black = append(summary.Black, weights[i])
+	}
+	for i := len(weights) - 1; i >= 0 && len(weights)-1-i < top && weights[i].LogOdds < 0; i-- {
+		summary.White = append(summary.White, weights[i])
+	}
+	return summary
+}
+
+// weights 返回每个特征值的影响，键为 "特征=值"。
+func (nb *NaiveBayes) weights() map[string]FeatureWeight {
+	weights := make(map[string]FeatureWeight)
+	for feature, values := range nb.featureValueCounts {
+		for value, counts := range values {
+			// 与 PredictProbability 相同的平滑方法
+			black := float64(counts["Black"]+1) / float64(nb.classCounts["Black"]+len(values))
+			white := float64(counts["White"]+1) / float64(nb.classCounts["White"]+len(values))
+			weights[feature+"="+value] = FeatureWeight{feature, value, counts["Black"], counts["White"], math.Log(black / white)}
+		}
+	}
+	return weights
+}
+
+// Clone 返回模型的深拷贝，用于在不阻塞训练的情况下保存或评估模型。
+func (nb *NaiveBayes) Clone() *NaiveBayes {
+	clone := NewNaiveBayes()
+	for class, count := range nb.classCounts {
+		clone.classCounts[class] = count
+	}
+	for feature, values := range nb.featureValueCounts {
+		clone.featureValueCounts[feature] = make(map[string]map[string]int)
+		for value, counts := range values {
+			clone.featureValueCounts[feature][value] = make(map[string]int)
+			for class, count := range counts {
+				clone.featureValueCounts[feature][value][class] = count
+			}
+		}
+	}
+	clone.totalSamples = nb.totalSamples
+	return clone
+}
+
+func TestNaiveBayesPersistence(t *testing.T) {
+	nb := NewNaiveBayes()
+	nb.Train(ExtractFeatures("SELECT name FROM users WHERE id = 1"), "White")
+	nb.Train(ExtractFeatures("SELECT * FROM users WHERE id = 1 OR 1=1"), "Black")
+	nb.Train(ExtractFeatures("DROP TABLE users"), "Black")
+
+	path := filepath.Join(t.TempDir(), "model.gob")
+	if err := nb.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadModelFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range []string{"SELECT name FROM admins", "SELECT 1 OR 1=1"} {
+		features := ExtractFeatures(sql)
+		if got, expected := loaded.PredictProbability(features), nb.PredictProbability(features); got != expected {
+			t.Errorf("For %q, expected probability %v after loading but got %v", sql, expected, got)
+		}
+	}
+
+	summary := loaded.Summary(3)
+	if summary.Samples != 3 || summary.Classes["Black"] != 2 || len(summary.Black) == 0 || len(summary.Black) > 3 || summary.Black[0].LogOdds <= 0 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+}
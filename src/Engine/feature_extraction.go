@@ -1,9 +1,60 @@
 package Engine
 
-// 从SQL语句中提取特征
+import (
+	"HawkEye-Go/src/PythonSqlPaser"
+	"HawkEye-Go/src/SqlPaser"
+	"testing"
+)
+
+// 从SQL语句中提取特征，语句只解析一次，供各项特征共用
 func ExtractFeatures(sql string) map[string]string {
+	return (&payload{sql: sql}).featureValues()
+}
+
+// extractFeatures 在已经解析好的语句和令牌流上提取特征，写入 features。
+func extractFeatures(features map[string]string, script *SqlPaser.Script, tokens []PythonSqlPaser.ParsedToken) {
+	// 危险函数和系统对象目录
+	for name, value := range CatalogFeatures(DefaultCatalog.MatchParsed(script, tokens)) {
+		features[name] = value
+	}
+	// 恒真或恒假的条件
+	for name, value := range ConditionFeatures(SqlPaser.FindConstantConditions(script, SqlPaser.FoldOptions{})) {
+		features[name] = value
+	}
+	// 时间盲注
+	for name, value := range TimeBlindFeatures(DetectTimeBlindParsed(script, tokens)) {
+		features[name] = value
+	}
+}
 
-	return nil
+// ConditionFeatures 把恒真、恒假条件转换为分类器特征。
+func ConditionFeatures(conditions []*SqlPaser.ConstantCondition) map[string]string {
+	features := make(map[string]string)
+	for _, c := range conditions {
+		if c.Value {
+			features["condition:tautology"] = "true"
+			// OR 使整个条件恒真，如 id = 1 OR 1 = 1，这是注入绕过条件的典型形式
+			if expr, ok := c.Node.(*SqlPaser.BinaryExpr); ok && expr.Operator == SqlPaser.OR {
+				features["condition:or_tautology"] = "true"
+			}
+		} else {
+			features["condition:contradiction"] = "true"
+		}
+	}
+	return features
 }
 
 var globalNB = NewNaiveBayes()
+
+func TestExtractFeatures(t *testing.T) {
+	features := ExtractFeatures("SELECT * FROM users WHERE id = 1 OR 1=1; SELECT SLEEP(5)")
+	for _, name := range []string{"condition:or_tautology", "time_blind"} {
+		if features[name] == "" {
+			t.Errorf("Expected feature %s in %v", name, features)
+		}
+	}
+	// 畸形的输入不能让特征提取失败
+	for _, sql := range []string{"UPDATE- ", "UPDATE-%20", `INSERT "LIKE `, "SELECT * FROM (SELECT 1 ORDER)", "1 AND (SELECT"} {
+		ExtractFeatures(sql)
+	}
+}
@@ -0,0 +1,515 @@
+package Engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 候选注入点的来源
+const (
+	SOURCE_QUERY  = "query"
+	SOURCE_FORM   = "form"
+	SOURCE_JSON   = "json"
+	SOURCE_XML    = "xml"
+	SOURCE_COOKIE = "cookie"
+	SOURCE_HEADER = "header"
+	SOURCE_PATH   = "path"
+	SOURCE_BODY   = "body" // 无法识别格式的请求体
+)
+
+// Parameter 是请求中的一个候选注入点。
+type Parameter struct {
+	Source string `json:"source"`
+	Name   string `json:"name"` // JSON为字段路径，如 user.ids[0]；XML为元素路径，如 order/item/@id；路径段为序号
+	Value  string `json:"value"`
+}
+
+// ParameterVerdict 是对一个参数的检查结果。
+type ParameterVerdict struct {
+	Parameter
+	Normalized string `json:"normalized"`
+	Context    string `json:"context"` // 给出结论的注入上下文，见 injectionContexts
+	*Verdict
+}
+
+// injectionContexts 是参数值可能被拼接进SQL的位置：值本身是完整的SQL、数字上下文和单引号字符串上下文。
+// 字符串上下文只补开头的引号，注入的值通常自己闭合引号并注释掉结尾，如 admin'--。
+var injectionContexts = []struct {
+	name   string
+	prefix string
+}{
+	{"raw", ""},
+	{"numeric", "SELECT * FROM t WHERE id = "},
+	{"quoted", "SELECT * FROM t WHERE id = '"},
+}
+
+// checkValue 在每个注入上下文中检查参数值，返回最严重的结论。
+func (i *RequestInspector) checkValue(value string) (string, *Verdict) {
+	var context string
+	var worst *Verdict
+	for _, c := range injectionContexts {
+		verdict := i.Detector.Check(c.prefix + value)
+		if worst == nil || verdict.Block && !worst.Block || verdict.Block == worst.Block && verdict.Score > worst.Score {
+			context, worst = c.name, verdict
+		}
+	}
+	return context, worst
+}
+
+// InspectionReport 是对整个请求的检查结果。
+type InspectionReport struct {
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Block      bool                `json:"block"` // 任一参数被拦截时为true
+	Score      float64             `json:"score"` // 各参数分数的最大值
+	Parameters []*ParameterVerdict `json:"parameters"`
+}
+
+// skippedHeaders 是不检查的请求头：Cookie 单独按键值检查，其余的由客户端或代理生成，与注入无关。
+var skippedHeaders = map[string]bool{
+	"Cookie":            true,
+	"Authorization":     true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+}
+
+// RequestInspector 从HTTP请求中提取候选注入点，逐个用 Detector 检查。
+type RequestInspector struct {
+	Detector      *Detector
+	MaxBodySize   int64           // 读取的请求体上限，超出时返回 ErrBodyTooLarge
+	Normalization []NormalizePass // 参数值的规范化步骤，为nil时使用 DefaultNormalization
+}
+
+// ErrBodyTooLarge 表示请求体超过 MaxBodySize，超出的部分没有检查，调用方不应转发这样的请求。
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// NewRequestInspector 返回使用给定 Detector 的检查器，请求体上限为1MB。
+func NewRequestInspector(detector *Detector) *RequestInspector {
+	return &RequestInspector{Detector: detector, MaxBodySize: 1 << 20}
+}
+
+// Inspect 检查请求中的所有候选值。请求体被读取后会被替换为相同内容的新 Reader，
+// 因此检查后的请求仍然可以转发。
+func (i *RequestInspector) Inspect(r *http.Request) (*InspectionReport, error) {
+	parameters, err := i.Extract(r)
+	if err != nil {
+		return nil, err
+	}
+	return i.Report(r, parameters), nil
+}
+
+// Report 检查已经用 Extract 提取的参数，调用方可以在读取请求之后、加锁之后再检查。
+func (i *RequestInspector) Report(r *http.Request, parameters []Parameter) *InspectionReport {
+	report := &InspectionReport{Method: r.Method, Path: r.URL.Path, Parameters: []*ParameterVerdict{}}
+	for _, parameter := range parameters {
+		verdict := i.CheckParameter(parameter)
+		report.Parameters = append(report.Parameters, verdict)
+		report.Block = report.Block || verdict.Block
+		if verdict.Score > report.Score {
+			report.Score = verdict.Score
+		}
+	}
+	return report
+}
+
+// CheckParameter 规范化并检查一个参数值，也可以用于请求以外的来源，如日志中记录的绑定参数。
+func (i *RequestInspector) CheckParameter(parameter Parameter) *ParameterVerdict {
+	passes := i.Normalization
+	if passes == nil {
+		passes = DefaultNormalization
+	}
+	normalized := Normalize(parameter.Value, passes)
+	context, verdict := i.checkValue(normalized)
+	return &ParameterVerdict{Parameter: parameter, Normalized: normalized, Context: context, Verdict: verdict}
+}
+
+// InspectDump 检查原始的HTTP请求报文，如 httputil.DumpRequest 的输出或抓包得到的请求。
+func (i *RequestInspector) InspectDump(dump []byte) (*InspectionReport, error) {
+	r, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(dump)))
+	if err != nil {
+		return nil, err
+	}
+	return i.Inspect(r)
+}
+
+// Extract 提取请求中的候选注入点：路径段、查询参数、Cookie、请求头以及请求体中的值。
+func (i *RequestInspector) Extract(r *http.Request) ([]Parameter, error) {
+	var parameters []Parameter
+	for n, segment := range strings.Split(r.URL.Path, "/") {
+		if segment != "" {
+			if unescaped, err := url.PathUnescape(segment); err == nil {
+				segment = unescaped
+			}
+			parameters = append(parameters, Parameter{Source: SOURCE_PATH, Name: strconv.Itoa(n - 1), Value: segment})
+		}
+	}
+	parameters = appendValues(parameters, SOURCE_QUERY, parseValues(r.URL.RawQuery))
+	for _, cookie := range r.Cookies() {
+		parameters = append(parameters, Parameter{Source: SOURCE_COOKIE, Name: cookie.Name, Value: cookie.Value})
+	}
+	var headers []string
+	for name := range r.Header {
+		if !skippedHeaders[name] {
+			headers = append(headers, name)
+		}
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		for _, value := range r.Header[name] {
+			parameters = append(parameters, Parameter{Source: SOURCE_HEADER, Name: name, Value: value})
+		}
+	}
+
+	body, err := i.readBody(r)
+	if err != nil || len(body) == 0 {
+		return parameters, err
+	}
+	bodyParameters, err := extractBody(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		// 与声明的格式不符的请求体整体作为一个值检查
+		bodyParameters = []Parameter{{Source: SOURCE_BODY, Value: string(body)}}
+	}
+	return append(parameters, bodyParameters...), nil
+}
+
+// readBody 读取请求体并把 r.Body 替换为可以再次读取的副本。
+// 请求体超过上限时返回 ErrBodyTooLarge，r.Body 仍然保持完整。
+func (i *RequestInspector) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	limit := i.MaxBodySize
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+	// 多读一个字节以判断是否超出上限
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if int64(len(body)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return body, nil
+}
+
+// extractBody 按 Content-Type 解析请求体。
+func extractBody(contentType string, body []byte) ([]Parameter, error) {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return appendValues(nil, SOURCE_FORM, parseValues(string(body))), nil
+	case mediaType == "multipart/form-data":
+		return extractMultipart(body, params["boundary"])
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		return extractJSON(nil, "", value), nil
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return extractXML(body)
+	}
+	return []Parameter{{Source: SOURCE_BODY, Value: string(body)}}, nil
+}
+
+// parseValues 按 & 拆分查询字符串或表单。与 url.ParseQuery 不同，含有 ; 或无法解码的键值对不会被丢弃，
+// 无法解码的部分保留原文，因为上游应用可能按自己的方式解析它们。
+func parseValues(raw string) url.Values {
+	values := make(url.Values)
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		name, value = unescapeValue(name), unescapeValue(value)
+		values[name] = append(values[name], value)
+	}
+	return values
+}
+
+func unescapeValue(s string) string {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
+}
+
+func appendValues(parameters []Parameter, source string, values url.Values) []Parameter {
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range values[name] {
+			parameters = append(parameters, Parameter{Source: source, Name: name, Value: value})
+		}
+	}
+	return parameters
+}
+
+// extractMultipart 提取 multipart 表单的字段，文件只检查文件名。
+func extractMultipart(body []byte, boundary string) ([]Parameter, error) {
+	if boundary == "" {
+		return nil, fmt.Errorf("multipart body without boundary")
+	}
+	var parameters []Parameter
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parameters, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() != "" {
+			parameters = append(parameters, Parameter{Source: SOURCE_FORM, Name: part.FormName() + ".filename", Value: part.FileName()})
+			continue
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, Parameter{Source: SOURCE_FORM, Name: part.FormName(), Value: string(value)})
+	}
+}
+
+// extractJSON 提取JSON中所有字符串和数字叶子节点。
+func extractJSON(parameters []Parameter, path string, value interface{}) []Parameter {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var keys []string
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := key
+			if path != "" {
+				name = path + "." + key
+			}
+			parameters = extractJSON(parameters, name, v[key])
+		}
+	case []interface{}:
+		for i, item := range v {
+			parameters = extractJSON(parameters, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case string:
+		parameters = append(parameters, Parameter{Source: SOURCE_JSON, Name: path, Value: v})
+	case json.Number:
+		parameters = append(parameters, Parameter{Source: SOURCE_JSON, Name: path, Value: v.String()})
+	}
+	return parameters
+}
+
+// extractXML 提取XML中的文本内容和属性值。
+func extractXML(body []byte) ([]Parameter, error) {
+	var parameters []Parameter
+	var path []string
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return parameters, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			for _, attr := range t.Attr {
+				parameters = append(parameters, Parameter{Source: SOURCE_XML, Name: strings.Join(path, "/") + "/@" + attr.Name.Local, Value: attr.Value})
+			}
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" {
+				parameters = append(parameters, Parameter{Source: SOURCE_XML, Name: strings.Join(path, "/"), Value: text})
+			}
+		}
+	}
+}
+
+// NormalizePass 是参数值的一个规范化步骤。
+type NormalizePass int
+
+const (
+	NORMALIZE_URL  NormalizePass = iota // 最多三层URL解码，%2527 还原为单引号
+	NORMALIZE_HTML                      // 还原HTML实体
+	NORMALIZE_NULL                      // 去掉空字节
+)
+
+var normalizePassNames = []string{"url", "html", "null"}
+
+func (p NormalizePass) String() string {
+	if p >= 0 && int(p) < len(normalizePassNames) {
+		return normalizePassNames[p]
+	}
+	return fmt.Sprintf("NormalizePass(%d)", int(p))
+}
+
+func (p NormalizePass) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *NormalizePass) UnmarshalText(text []byte) error {
+	for i, name := range normalizePassNames {
+		if strings.EqualFold(string(text), name) {
+			*p = NormalizePass(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown normalization pass %q", text)
+}
+
+// DefaultNormalization 依次执行所有规范化步骤。
+var DefaultNormalization = []NormalizePass{NORMALIZE_URL, NORMALIZE_HTML, NORMALIZE_NULL}
+
+// Normalize 按顺序对参数值执行规范化步骤，最后去掉首尾空白。
+func Normalize(value string, passes []NormalizePass) string {
+	for _, pass := range passes {
+		switch pass {
+		case NORMALIZE_URL:
+			for i := 0; i < 3; i++ {
+				unescaped, err := url.QueryUnescape(value)
+				if err != nil || unescaped == value {
+					break
+				}
+				value = unescaped
+			}
+		case NORMALIZE_HTML:
+			value = html.UnescapeString(value)
+		case NORMALIZE_NULL:
+			value = strings.ReplaceAll(value, "\x00", "")
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// NormalizeValue 还原参数值中常见的编码：多重URL编码、HTML实体和空字节，
+// 例如 %2527 会被还原为单引号。
+func NormalizeValue(value string) string {
+	return Normalize(value, DefaultNormalization)
+}
+
+func TestRequestInspector(t *testing.T) {
+	inspector := NewRequestInspector(&Detector{Rules: DefaultRuleSet(), Policy: DefaultPolicy})
+
+	check := func(name string, r *http.Request, expected []string) {
+		report, err := inspector.Inspect(r)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			return
+		}
+		var got []string
+		for _, p := range report.Parameters {
+			entry := p.Source + ":" + p.Name + "=" + p.Normalized
+			if p.Block {
+				entry += "!"
+			}
+			got = append(got, entry)
+		}
+		if strings.Join(got, " | ") != strings.Join(expected, " | ") {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, strings.Join(expected, " | "), strings.Join(got, " | "))
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/users/42%20OR%201=1/profile?id=1%2527%20UNION%20SELECT%20LOAD_FILE(0x2f6574632f706173737764)&q=books", nil)
+	r.Header.Set("User-Agent", "curl/8.0")
+	r.Header.Set("Cookie", "session=abc; theme=dark")
+	check("query", r, []string{
+		"path:0=users", "path:1=42 OR 1=1!", "path:2=profile",
+		"query:id=1' UNION SELECT LOAD_FILE(0x2f6574632f706173737764)!", "query:q=books",
+		"cookie:session=abc", "cookie:theme=dark",
+		"header:User-Agent=curl/8.0",
+	})
+
+	// 含有 ; 或无法解码的查询参数也要检查
+	check("semicolon", httptest.NewRequest("GET", "/?id=1;DROP%20TABLE%20users&q=100%zz", nil), []string{
+		"query:id=1;DROP TABLE users!", "query:q=100%zz",
+	})
+
+	r = httptest.NewRequest("POST", "/login", strings.NewReader("user=admin'--&password=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	check("form", r, []string{"path:0=login", "form:password=x", "form:user=admin'--"})
+	if body, _ := io.ReadAll(r.Body); string(body) != "user=admin'--&password=x" {
+		t.Errorf("Request body was not restored: %q", body)
+	}
+	r = httptest.NewRequest("POST", "/login", strings.NewReader("id=1;DROP TABLE users"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	check("form with semicolon", r, []string{"path:0=login", "form:id=1;DROP TABLE users!"})
+
+	r = httptest.NewRequest("POST", "/api", strings.NewReader(`{"user": {"name": "bob", "ids": [1, "2; DROP TABLE users"]}, "active": true}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	check("json", r, []string{"path:0=api", "json:user.ids[0]=1", "json:user.ids[1]=2; DROP TABLE users!", "json:user.name=bob"})
+
+	r = httptest.NewRequest("POST", "/soap", strings.NewReader(`<order id="7"><item>1 AND SLEEP(5)</item></order>`))
+	r.Header.Set("Content-Type", "text/xml")
+	check("xml", r, []string{"path:0=soap", "xml:order/@id=7", "xml:order/item=1 AND SLEEP(5)!"})
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("comment", "hello")
+	writer.CreateFormFile("upload", "a.txt")
+	writer.Close()
+	r = httptest.NewRequest("POST", "/upload", &buf)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	check("multipart", r, []string{"path:0=upload", "form:comment=hello", "form:upload.filename=a.txt"})
+
+	// 超出上限的请求体不检查也不截断，由调用方拒绝
+	limited := NewRequestInspector(inspector.Detector)
+	limited.MaxBodySize = 16
+	r = httptest.NewRequest("POST", "/login", strings.NewReader("pad=xxxxxxxxxxxx&user=admin'--"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := limited.Inspect(r); err != ErrBodyTooLarge {
+		t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != "pad=xxxxxxxxxxxx&user=admin'--" {
+		t.Errorf("Request body was not restored: %q", body)
+	}
+
+	report, err := inspector.InspectDump([]byte("GET /search?q=1+OR+1%3D1 HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	if err != nil || !report.Block || report.Method != "GET" || report.Path != "/search" {
+		t.Errorf("Unexpected report for dump: %+v, %v", report, err)
+	}
+
+	// 只做一层以外的处理时 %2527 不会被还原
+	inspector.Normalization = []NormalizePass{NORMALIZE_HTML}
+	if verdict := inspector.CheckParameter(Parameter{Value: "1%2527 OR &#39;a&#39;=&#39;a"}); verdict.Normalized != "1%2527 OR 'a'='a" {
+		t.Errorf("Unexpected normalization %q", verdict.Normalized)
+	}
+	var pass NormalizePass
+	if err := pass.UnmarshalText([]byte("URL")); err != nil || pass != NORMALIZE_URL || pass.UnmarshalText([]byte("base64")) == nil {
+		t.Errorf("Unexpected pass %v, %v", pass, err)
+	}
+}
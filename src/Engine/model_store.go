@@ -0,0 +1,541 @@
+package Engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Evaluation 是在标注数据上评估检测器的结果，攻击样本为正例。
+type Evaluation struct {
+	TruePositives  int     `json:"true_positives"`
+	FalsePositives int     `json:"false_positives"`
+	TrueNegatives  int     `json:"true_negatives"`
+	FalseNegatives int     `json:"false_negatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+	Accuracy       float64 `json:"accuracy"`
+}
+
+// Record 记录一个样本的判断结果。
+func (e *Evaluation) Record(attack, blocked bool) {
+	switch {
+	case attack && blocked:
+		e.TruePositives++
+	case attack:
+		e.FalseNegatives++
+	case blocked:
+		e.FalsePositives++
+	default:
+		e.TrueNegatives++
+	}
+}
+
+// Finish 根据混淆矩阵计算各项指标。
+func (e *Evaluation) Finish() {
+	if n := e.TruePositives + e.FalsePositives; n > 0 {
+		e.Precision = float64(e.TruePositives) / float64(n)
+	}
+	if n := e.TruePositives + e.FalseNegatives; n > 0 {
+		e.Recall = float64(e.TruePositives) / float64(n)
+	}
+	if e.Precision+e.Recall > 0 {
+		e.F1 = 2 * e.Precision * e.Recall / (e.Precision + e.Recall)
+	}
+	if n := e.TruePositives + e.FalsePositives + e.TrueNegatives + e.FalseNegatives; n > 0 {
+		e.Accuracy = float64(e.TruePositives+e.TrueNegatives) / float64(n)
+	}
+}
+
+// SnapshotInfo 是模型快照的元数据，与模型文件一起保存在快照目录中。
+type SnapshotInfo struct {
+	Version  int            `json:"version"`
+	Created  time.Time      `json:"created"`
+	Reason   string         `json:"reason,omitempty"` // 生成快照的原因，如 periodic、manual、import
+	Parent   int            `json:"parent,omitempty"` // 生成快照时处于激活状态的版本
+	Samples  int            `json:"samples"`
+	Classes  map[string]int `json:"classes"`
+	Features int            `json:"features"`
+	Values   int            `json:"values"`
+	SHA256   string         `json:"sha256"`
+	Metrics  *Evaluation    `json:"metrics,omitempty"`
+	Active   bool           `json:"active"` // 由 List 填充，不保存
+}
+
+// maxHistory 是回滚历史记录的版本数，更早的版本不能再回滚，可以被 Prune 删除。
+const maxHistory = 10
+
+// activeFile 是 active.json 的内容，History 是之前激活过的版本，用于回滚。
+type activeFile struct {
+	Version int   `json:"version"`
+	History []int `json:"history,omitempty"`
+}
+
+// ModelStore 管理一个目录中带版本号的模型快照：
+// 000001.gob 和 000001.json 是第1版的模型和元数据，active.json 记录当前激活的版本。
+type ModelStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenModelStore 打开快照目录，目录不存在时创建。
+func OpenModelStore(dir string) (*ModelStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &ModelStore{dir: dir}, nil
+}
+
+// Dir 返回快照目录。
+func (s *ModelStore) Dir() string { return s.dir }
+
+// ActivePath 返回 active.json 的路径，可以通过它的修改时间发现其他进程激活了别的版本。
+func (s *ModelStore) ActivePath() string { return ActiveSnapshotPath(s.dir) }
+
+// ActiveSnapshotPath 返回快照目录中记录激活版本的文件。
+func ActiveSnapshotPath(dir string) string { return filepath.Join(dir, "active.json") }
+
+func (s *ModelStore) path(version int, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%06d%s", version, ext))
+}
+
+// versions 返回目录中所有快照的版本号，从小到大。
+func (s *ModelStore) versions() ([]int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if version, err := strconv.Atoi(strings.TrimSuffix(name, ".json")); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+func (s *ModelStore) readActive() (*activeFile, error) {
+	data, err := os.ReadFile(s.ActivePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return &activeFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var active activeFile
+	if err := json.Unmarshal(data, &active); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.ActivePath(), err)
+	}
+	return &active, nil
+}
+
+func (s *ModelStore) writeActive(active *activeFile) error {
+	data, err := json.MarshalIndent(active, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.ActivePath(), append(data, '\n'))
+}
+
+// Save 把模型保存为新版本的快照，返回写入的元数据。快照不会自动激活。
+// metrics 可以为 nil。
+func (s *ModelStore) Save(model *NaiveBayes, reason string, metrics *Evaluation) (*SnapshotInfo, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(model); err != nil {
+		return nil, err
+	}
+	summary := model.Summary(0)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err := s.versions()
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.readActive()
+	if err != nil {
+		return nil, err
+	}
+	info := &SnapshotInfo{
+		Version:  1,
+		Created:  time.Now().UTC(),
+		Reason:   reason,
+		Parent:   active.Version,
+		Samples:  summary.Samples,
+		Classes:  summary.Classes,
+		Features: summary.Features,
+		Values:   summary.Values,
+		SHA256:   sha256Hex(buf.Bytes()),
+		Metrics:  metrics,
+	}
+	if len(versions) > 0 {
+		info.Version = versions[len(versions)-1] + 1
+	}
+	// 先写模型再写元数据，元数据存在即表示快照完整
+	if err := writeFileAtomic(s.path(info.Version, ".gob"), buf.Bytes()); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(s.path(info.Version, ".json"), append(data, '\n')); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *ModelStore) info(version int) (*SnapshotInfo, error) {
+	data, err := os.ReadFile(s.path(version, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no snapshot version %d", version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info SnapshotInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.path(version, ".json"), err)
+	}
+	return &info, nil
+}
+
+// List 返回所有快照的元数据，按版本号从小到大。
+func (s *ModelStore) List() ([]*SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err := s.versions()
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.readActive()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*SnapshotInfo, 0, len(versions))
+	for _, version := range versions {
+		info, err := s.info(version)
+		if err != nil {
+			return nil, err
+		}
+		info.Active = version == active.Version
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Load 读取指定版本的模型，并校验模型文件的 SHA256。
+func (s *ModelStore) Load(version int) (*NaiveBayes, *SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(version)
+}
+
+func (s *ModelStore) load(version int) (*NaiveBayes, *SnapshotInfo, error) {
+	info, err := s.info(version)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(s.path(version, ".gob"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if sum := sha256Hex(data); sum != info.SHA256 {
+		return nil, nil, fmt.Errorf("snapshot %d: checksum mismatch", version)
+	}
+	model := NewNaiveBayes()
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(model); err != nil {
+		return nil, nil, fmt.Errorf("snapshot %d: %v", version, err)
+	}
+	return model, info, nil
+}
+
+// Active 返回当前激活的版本号，没有激活的快照时返回0。
+func (s *ModelStore) Active() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, err := s.readActive()
+	if err != nil {
+		return 0, err
+	}
+	return active.Version, nil
+}
+
+// LoadActive 读取当前激活的模型，没有激活的快照时返回 nil。
+func (s *ModelStore) LoadActive() (*NaiveBayes, *SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, err := s.readActive()
+	if err != nil || active.Version == 0 {
+		return nil, nil, err
+	}
+	return s.load(active.Version)
+}
+
+// Activate 激活指定版本，之前激活的版本记入历史，可以用 Rollback 恢复，历史最多记录 maxHistory 个版本。
+func (s *ModelStore) Activate(version int) (*SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := s.info(version)
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.readActive()
+	if err != nil {
+		return nil, err
+	}
+	if active.Version == version {
+		return info, nil
+	}
+	if active.Version != 0 {
+		active.History = append(active.History, active.Version)
+	}
+	if len(active.History) > maxHistory {
+		active.History = active.History[len(active.History)-maxHistory:]
+	}
+	active.Version = version
+	return info, s.writeActive(active)
+}
+
+// Rollback 恢复上一个激活的版本。
+func (s *ModelStore) Rollback() (*SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, err := s.readActive()
+	if err != nil {
+		return nil, err
+	}
+	if len(active.History) == 0 {
+		return nil, errors.New("no previous version to roll back to")
+	}
+	previous := active.History[len(active.History)-1]
+	info, err := s.info(previous)
+	if err != nil {
+		return nil, err
+	}
+	active.Version, active.History = previous, active.History[:len(active.History)-1]
+	return info, s.writeActive(active)
+}
+
+// Prune 删除最旧的快照，只保留最新的 keep 个，激活的版本和回滚历史中的版本不会删除。
+// keep 不大于0时不删除任何快照。
+func (s *ModelStore) Prune(keep int) ([]int, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err := s.versions()
+	if err != nil || len(versions) <= keep {
+		return nil, err
+	}
+	active, err := s.readActive()
+	if err != nil {
+		return nil, err
+	}
+	kept := map[int]bool{active.Version: true}
+	for _, version := range active.History {
+		kept[version] = true
+	}
+	var removed []int
+	for _, version := range versions[:len(versions)-keep] {
+		if kept[version] {
+			continue
+		}
+		// 先删元数据，中途失败时只会留下无用的模型文件
+		if err := os.Remove(s.path(version, ".json")); err != nil {
+			return removed, err
+		}
+		os.Remove(s.path(version, ".gob"))
+		removed = append(removed, version)
+	}
+	return removed, nil
+}
+
+// FeatureChange 是一个特征值在两个模型之间的变化。
+type FeatureChange struct {
+	Feature string  `json:"feature"`
+	Value   string  `json:"value"`
+	Before  float64 `json:"before"` // 旧模型中的对数几率，新增的特征值为0
+	After   float64 `json:"after"`  // 新模型中的对数几率，删除的特征值为0
+	Black   int     `json:"black"`  // 攻击样本计数的变化
+	White   int     `json:"white"`  // 正常样本计数的变化
+}
+
+// ModelDiff 是两个模型的差异，用于在激活快照前检查训练带来的变化。
+type ModelDiff struct {
+	Samples int             `json:"samples"`
+	Classes map[string]int  `json:"classes"`
+	Added   int             `json:"added"`   // 只在新模型中出现的特征值数量
+	Removed int             `json:"removed"` // 只在旧模型中出现的特征值数量
+	Changes []FeatureChange `json:"changes"` // 对数几率变化最大的特征值
+}
+
+// DiffModels 比较两个模型，Changes 按对数几率变化的绝对值从大到小列出 top 个。
+func DiffModels(before, after *NaiveBayes, top int) *ModelDiff {
+	diff := &ModelDiff{Samples: after.totalSamples - before.totalSamples, Classes: make(map[string]int)}
+	for class, count := range after.classCounts {
+		diff.Classes[class] = count - before.classCounts[class]
+	}
+	for class, count := range before.classCounts {
+		if _, ok := after.classCounts[class]; !ok {
+			diff.Classes[class] = -count
+		}
+	}
+
+	old, weights := before.weights(), after.weights()
+	var changes []FeatureChange
+	for key, weight := range weights {
+		previous, ok := old[key]
+		if !ok {
+			diff.Added++
+		}
+		changes = append(changes, FeatureChange{weight.Feature, weight.Value, previous.LogOdds, weight.LogOdds, weight.Black - previous.Black, weight.White - previous.White})
+	}
+	for key, weight := range old {
+		if _, ok := weights[key]; !ok {
+			diff.Removed++
+			changes = append(changes, FeatureChange{weight.Feature, weight.Value, weight.LogOdds, 0, -weight.Black, -weight.White})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := math.Abs(changes[i].After-changes[i].Before), math.Abs(changes[j].After-changes[j].Before)
+		if a != b {
+			return a > b
+		}
+		return changes[i].Feature+"="+changes[i].Value < changes[j].Feature+"="+changes[j].Value
+	})
+	for i := 0; i < len(changes) && i < top && changes[i].After != changes[i].Before; i++ {
+		diff.Changes = append(diff.Changes, changes[i])
+	}
+	return diff
+}
+
+// writeFileAtomic 先写临时文件再改名，写入过程中不会留下不完整的文件。
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestModelStore(t *testing.T) {
+	store, err := OpenModelStore(filepath.Join(t.TempDir(), "models"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model, _, err := store.LoadActive(); model != nil || err != nil {
+		t.Fatalf("Expected no active model in an empty store, got %v, %v", model, err)
+	}
+
+	nb := NewNaiveBayes()
+	nb.Train(ExtractFeatures("SELECT name FROM users WHERE id = 1"), "White")
+	nb.Train(ExtractFeatures("SELECT * FROM users WHERE id = 1 OR 1=1"), "Black")
+	first, err := store.Save(nb, "manual", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Activate(first.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	trained := nb.Clone()
+	trained.Train(ExtractFeatures("SELECT 1 UNION SELECT password FROM admins"), "Black")
+	if nb.totalSamples != 2 {
+		t.Errorf("Training a clone changed the original model")
+	}
+	second, err := store.Save(trained, "periodic", &Evaluation{TruePositives: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != 1 || second.Version != 2 || second.Parent != 1 || second.Samples != 3 {
+		t.Errorf("Unexpected snapshots %+v and %+v", first, second)
+	}
+	if _, err := store.Activate(second.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	model, info, err := store.LoadActive()
+	if err != nil || info.Version != 2 || info.Metrics == nil || info.Metrics.TruePositives != 1 {
+		t.Fatalf("Expected version 2 to be active, got %+v, %v", info, err)
+	}
+	features := ExtractFeatures("SELECT 1 UNION SELECT 2")
+	if got, expected := model.PredictProbability(features), trained.PredictProbability(features); got != expected {
+		t.Errorf("Expected probability %v from the loaded snapshot but got %v", expected, got)
+	}
+
+	diff := DiffModels(nb, trained, 5)
+	if diff.Samples != 1 || diff.Classes["Black"] != 1 || diff.Classes["White"] != 0 || diff.Removed != 0 || len(diff.Changes) == 0 || len(diff.Changes) > 5 {
+		t.Errorf("Unexpected diff %+v", diff)
+	}
+
+	if info, err := store.Rollback(); err != nil || info.Version != 1 {
+		t.Errorf("Expected rollback to version 1, got %+v, %v", info, err)
+	}
+	if _, err := store.Rollback(); err == nil {
+		t.Errorf("Expected an error rolling back without history")
+	}
+	if _, err := store.Activate(3); err == nil {
+		t.Errorf("Expected an error activating a missing version")
+	}
+
+	if removed, err := store.Prune(0); err != nil || len(removed) != 0 {
+		t.Errorf("Expected keep 0 not to prune, got %v, %v", removed, err)
+	}
+	// 激活版本3后版本1在回滚历史中，只删除版本2，之后仍然可以回滚到版本1
+	third, err := store.Save(trained, "periodic", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Activate(third.Version); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := store.Prune(1); err != nil || len(removed) != 1 || removed[0] != 2 {
+		t.Errorf("Expected to prune version 2, got %v, %v", removed, err)
+	}
+	if info, err := store.Rollback(); err != nil || info.Version != 1 {
+		t.Errorf("Expected rollback to version 1 after pruning, got %+v, %v", info, err)
+	}
+	infos, err := store.List()
+	if err != nil || len(infos) != 2 || infos[0].Version != 1 || !infos[0].Active || infos[1].Version != 3 {
+		t.Errorf("Unexpected snapshots after pruning %+v, %v", infos, err)
+	}
+
+	os.WriteFile(store.path(1, ".gob"), []byte("corrupted"), 0o644)
+	if _, _, err := store.Load(1); err == nil {
+		t.Errorf("Expected a checksum error for a corrupted snapshot")
+	}
+}
@@ -0,0 +1,447 @@
+package Engine
+
+import (
+	"HawkEye-Go/src/PythonSqlPaser"
+	"HawkEye-Go/src/SqlPaser"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// EntryKind 表示目录项匹配的对象种类。
+type EntryKind int
+
+const (
+	FUNCTION_ENTRY EntryKind = iota // 函数调用，如 SLEEP(5)、UTL_HTTP.REQUEST(...)
+	OBJECT_ENTRY                    // 表、库或包，如 information_schema、mysql.user
+	VARIABLE_ENTRY                  // 系统变量，如 @@version
+	PHRASE_ENTRY                    // 连续的关键字，如 WAITFOR DELAY、INTO OUTFILE
+)
+
+// 风险类别
+const (
+	TIME_DELAY       = "time_delay"       // 时间盲注
+	FILE_ACCESS      = "file_access"      // 读写服务器文件
+	COMMAND_EXEC     = "command_exec"     // 执行系统命令
+	OUT_OF_BAND      = "out_of_band"      // 通过网络或DNS外带数据
+	ERROR_BASED      = "error_based"      // 报错注入
+	SCHEMA_DISCOVERY = "schema_discovery" // 枚举库表结构和账号
+	FINGERPRINT      = "fingerprint"      // 探测数据库版本、用户等信息
+	BLIND_EXTRACTION = "blind_extraction" // 逐字符提取数据，正常查询中也常见
+)
+
+// RiskWeights 是每个风险类别的权重，取值在0到1之间。
+var RiskWeights = map[string]float64{
+	TIME_DELAY:       0.9,
+	FILE_ACCESS:      1.0,
+	COMMAND_EXEC:     1.0,
+	OUT_OF_BAND:      0.9,
+	ERROR_BASED:      0.7,
+	SCHEMA_DISCOVERY: 0.6,
+	FINGERPRINT:      0.4,
+	BLIND_EXTRACTION: 0.2,
+}
+
+// CatalogEntry 是目录中的一个危险函数或对象，名称为小写。
+type CatalogEntry struct {
+	Name     string
+	Kind     EntryKind
+	Category string
+	Dialects []SqlPaser.Dialect // 为空时适用于所有方言
+}
+
+// 目录条目的方言限制
+var (
+	onlyMySQL      = []SqlPaser.Dialect{SqlPaser.DIALECT_MYSQL}
+	onlyPostgreSQL = []SqlPaser.Dialect{SqlPaser.DIALECT_POSTGRESQL}
+	onlySQLServer  = []SqlPaser.Dialect{SqlPaser.DIALECT_SQLSERVER}
+	onlyOracle     = []SqlPaser.Dialect{SqlPaser.DIALECT_ORACLE}
+	onlySQLite     = []SqlPaser.Dialect{SqlPaser.DIALECT_SQLITE}
+)
+
+// catalogEntries 是内置的危险函数和对象目录。
+var catalogEntries = []CatalogEntry{
+	{"sleep", FUNCTION_ENTRY, TIME_DELAY, onlyMySQL},
+	{"benchmark", FUNCTION_ENTRY, TIME_DELAY, onlyMySQL},
+	{"pg_sleep", FUNCTION_ENTRY, TIME_DELAY, onlyPostgreSQL},
+	{"pg_sleep_for", FUNCTION_ENTRY, TIME_DELAY, onlyPostgreSQL},
+	{"pg_sleep_until", FUNCTION_ENTRY, TIME_DELAY, onlyPostgreSQL},
+	{"waitfor delay", PHRASE_ENTRY, TIME_DELAY, onlySQLServer},
+	{"waitfor time", PHRASE_ENTRY, TIME_DELAY, onlySQLServer},
+	{"dbms_lock", OBJECT_ENTRY, TIME_DELAY, onlyOracle},
+	{"dbms_pipe", OBJECT_ENTRY, TIME_DELAY, onlyOracle},
+	{"randomblob", FUNCTION_ENTRY, TIME_DELAY, onlySQLite},
+
+	{"load_file", FUNCTION_ENTRY, FILE_ACCESS, onlyMySQL},
+	{"into outfile", PHRASE_ENTRY, FILE_ACCESS, onlyMySQL},
+	{"into dumpfile", PHRASE_ENTRY, FILE_ACCESS, onlyMySQL},
+	{"load data", PHRASE_ENTRY, FILE_ACCESS, onlyMySQL},
+	{"pg_read_file", FUNCTION_ENTRY, FILE_ACCESS, onlyPostgreSQL},
+	{"pg_read_binary_file", FUNCTION_ENTRY, FILE_ACCESS, onlyPostgreSQL},
+	{"pg_ls_dir", FUNCTION_ENTRY, FILE_ACCESS, onlyPostgreSQL},
+	{"lo_import", FUNCTION_ENTRY, FILE_ACCESS, onlyPostgreSQL},
+	{"lo_export", FUNCTION_ENTRY, FILE_ACCESS, onlyPostgreSQL},
+	{"openrowset", FUNCTION_ENTRY, FILE_ACCESS, onlySQLServer},
+	{"bulk insert", PHRASE_ENTRY, FILE_ACCESS, onlySQLServer},
+	{"utl_file", OBJECT_ENTRY, FILE_ACCESS, onlyOracle},
+
+	{"xp_cmdshell", OBJECT_ENTRY, COMMAND_EXEC, onlySQLServer},
+	{"sp_oacreate", OBJECT_ENTRY, COMMAND_EXEC, onlySQLServer},
+	{"sp_execute_external_script", OBJECT_ENTRY, COMMAND_EXEC, onlySQLServer},
+	{"sys_exec", FUNCTION_ENTRY, COMMAND_EXEC, onlyMySQL},
+	{"sys_eval", FUNCTION_ENTRY, COMMAND_EXEC, onlyMySQL},
+	{"from program", PHRASE_ENTRY, COMMAND_EXEC, onlyPostgreSQL},
+	{"dbms_scheduler", OBJECT_ENTRY, COMMAND_EXEC, onlyOracle},
+	{"dbms_java", OBJECT_ENTRY, COMMAND_EXEC, onlyOracle},
+	{"load_extension", FUNCTION_ENTRY, COMMAND_EXEC, onlySQLite},
+
+	{"utl_http", OBJECT_ENTRY, OUT_OF_BAND, onlyOracle},
+	{"utl_inaddr", OBJECT_ENTRY, OUT_OF_BAND, onlyOracle},
+	{"utl_tcp", OBJECT_ENTRY, OUT_OF_BAND, onlyOracle},
+	{"httpuritype", OBJECT_ENTRY, OUT_OF_BAND, onlyOracle},
+	{"dbms_ldap", OBJECT_ENTRY, OUT_OF_BAND, onlyOracle},
+	{"xp_dirtree", OBJECT_ENTRY, OUT_OF_BAND, onlySQLServer},
+	{"xp_fileexist", OBJECT_ENTRY, OUT_OF_BAND, onlySQLServer},
+	{"dblink", FUNCTION_ENTRY, OUT_OF_BAND, onlyPostgreSQL},
+	{"dblink_connect", FUNCTION_ENTRY, OUT_OF_BAND, onlyPostgreSQL},
+
+	{"extractvalue", FUNCTION_ENTRY, ERROR_BASED, onlyMySQL},
+	{"updatexml", FUNCTION_ENTRY, ERROR_BASED, onlyMySQL},
+	{"gtid_subset", FUNCTION_ENTRY, ERROR_BASED, onlyMySQL},
+	{"name_const", FUNCTION_ENTRY, ERROR_BASED, onlyMySQL},
+	{"xmltype", FUNCTION_ENTRY, ERROR_BASED, onlyOracle},
+	{"ctxsys", OBJECT_ENTRY, ERROR_BASED, onlyOracle},
+
+	{"information_schema", OBJECT_ENTRY, SCHEMA_DISCOVERY, nil},
+	{"mysql.user", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyMySQL},
+	{"mysql.db", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyMySQL},
+	{"performance_schema", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyMySQL},
+	{"pg_catalog", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyPostgreSQL},
+	{"pg_shadow", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyPostgreSQL},
+	{"pg_user", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyPostgreSQL},
+	{"pg_authid", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyPostgreSQL},
+	{"pg_database", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyPostgreSQL},
+	{"sysobjects", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlySQLServer},
+	{"syscolumns", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlySQLServer},
+	{"sysdatabases", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlySQLServer},
+	{"sys.tables", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlySQLServer},
+	{"sys.sql_logins", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlySQLServer},
+	{"all_tables", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyOracle},
+	{"all_tab_columns", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyOracle},
+	{"user_tables", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyOracle},
+	{"dba_users", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlyOracle},
+	{"sqlite_master", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlySQLite},
+	{"sqlite_schema", OBJECT_ENTRY, SCHEMA_DISCOVERY, onlySQLite},
+
+	{"@@version", VARIABLE_ENTRY, FINGERPRINT, nil},
+	{"@@hostname", VARIABLE_ENTRY, FINGERPRINT, onlyMySQL},
+	{"@@datadir", VARIABLE_ENTRY, FINGERPRINT, onlyMySQL},
+	{"@@basedir", VARIABLE_ENTRY, FINGERPRINT, onlyMySQL},
+	{"@@servername", VARIABLE_ENTRY, FINGERPRINT, onlySQLServer},
+	{"version", FUNCTION_ENTRY, FINGERPRINT, nil},
+	{"user", FUNCTION_ENTRY, FINGERPRINT, onlyMySQL},
+	{"current_user", FUNCTION_ENTRY, FINGERPRINT, nil},
+	{"system_user", FUNCTION_ENTRY, FINGERPRINT, nil},
+	{"session_user", FUNCTION_ENTRY, FINGERPRINT, nil},
+	{"database", FUNCTION_ENTRY, FINGERPRINT, onlyMySQL},
+	{"current_database", FUNCTION_ENTRY, FINGERPRINT, onlyPostgreSQL},
+	{"db_name", FUNCTION_ENTRY, FINGERPRINT, onlySQLServer},
+	{"sqlite_version", FUNCTION_ENTRY, FINGERPRINT, onlySQLite},
+
+	{"ascii", FUNCTION_ENTRY, BLIND_EXTRACTION, nil},
+	{"ord", FUNCTION_ENTRY, BLIND_EXTRACTION, onlyMySQL},
+	{"substr", FUNCTION_ENTRY, BLIND_EXTRACTION, nil},
+	{"substring", FUNCTION_ENTRY, BLIND_EXTRACTION, nil},
+	{"mid", FUNCTION_ENTRY, BLIND_EXTRACTION, onlyMySQL},
+	{"char", FUNCTION_ENTRY, BLIND_EXTRACTION, nil},
+	{"chr", FUNCTION_ENTRY, BLIND_EXTRACTION, nil},
+	{"hex", FUNCTION_ENTRY, BLIND_EXTRACTION, nil},
+	{"unhex", FUNCTION_ENTRY, BLIND_EXTRACTION, onlyMySQL},
+}
+
+// RiskCatalog 是按方言筛选后的危险函数和对象目录。
+type RiskCatalog struct {
+	entries []CatalogEntry
+}
+
+// NewRiskCatalog 返回适用于给定方言的目录，不指定方言时包含所有条目。
+// 后端数据库未知时应使用全部方言，攻击者会针对实际的数据库构造载荷。
+func NewRiskCatalog(dialects ...SqlPaser.Dialect) *RiskCatalog {
+	catalog := &RiskCatalog{}
+	for _, entry := range catalogEntries {
+		if len(dialects) == 0 || len(entry.Dialects) == 0 || hasDialect(entry.Dialects, dialects) {
+			catalog.entries = append(catalog.entries, entry)
+		}
+	}
+	return catalog
+}
+
+func hasDialect(entryDialects, dialects []SqlPaser.Dialect) bool {
+	for _, d := range entryDialects {
+		for _, want := range dialects {
+			if d == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DefaultCatalog 包含所有方言的条目，ExtractFeatures 使用它。
+var DefaultCatalog = NewRiskCatalog()
+
+// CatalogMatch 是SQL中命中的一个目录项。
+type CatalogMatch struct {
+	Entry CatalogEntry
+	Text  string // 命中的原文，如 utl_http.request
+}
+
+// reference 是SQL中出现的一个名称，call 表示它后面紧跟参数列表。
+type reference struct {
+	name string
+	call bool
+}
+
+// match 用名称和单词序列匹配目录，同一条目只返回一次。
+func (c *RiskCatalog) match(references []reference, words []string) []CatalogMatch {
+	var matches []CatalogMatch
+	seen := make(map[string]bool)
+	add := func(entry CatalogEntry, text string) {
+		if !seen[entry.Name] {
+			seen[entry.Name] = true
+			matches = append(matches, CatalogMatch{Entry: entry, Text: text})
+		}
+	}
+
+	for _, entry := range c.entries {
+		switch entry.Kind {
+		case PHRASE_ENTRY:
+			phrase := strings.Fields(entry.Name)
+			for i := 0; i+len(phrase) <= len(words); i++ {
+				if equalWords(words[i:i+len(phrase)], phrase) {
+					add(entry, strings.Join(words[i:i+len(phrase)], " "))
+					break
+				}
+			}
+		default:
+			for _, ref := range references {
+				name := strings.ToLower(ref.name)
+				switch {
+				case entry.Kind == VARIABLE_ENTRY && name == entry.Name:
+					add(entry, ref.name)
+				case entry.Kind == FUNCTION_ENTRY && ref.call && lastNamePart(name) == entry.Name:
+					add(entry, ref.name)
+				case entry.Kind == OBJECT_ENTRY && containsParts(name, entry.Name):
+					add(entry, ref.name)
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// lastNamePart 返回点分名称的最后一部分。
+func lastNamePart(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// containsParts 判断点分名称是否包含 object 的各部分，如 mysql.user 包含在 mysql.user.host 中。
+// master..xp_cmdshell 这样的空部分被忽略。
+func containsParts(name, object string) bool {
+	var parts []string
+	for _, part := range strings.Split(name, ".") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	objectParts := strings.Split(object, ".")
+	for i := 0; i+len(objectParts) <= len(parts); i++ {
+		if equalWords(parts[i:i+len(objectParts)], objectParts) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalWords(a, b []string) bool {
+	for i := range b {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchAST 在AST中匹配目录：函数调用、标识符以及语句访问的表。
+// 无法解析的语法（如 WAITFOR DELAY）需要用 MatchTokens 或 Match 匹配。
+func (c *RiskCatalog) MatchAST(node SqlPaser.ASTNode) []CatalogMatch {
+	var references []reference
+	SqlPaser.Inspect(node, func(n SqlPaser.ASTNode) bool {
+		switch n := n.(type) {
+		case *SqlPaser.FunctionCall:
+			references = append(references, reference{name: n.Name, call: true})
+		case *SqlPaser.Identifier:
+			references = append(references, reference{name: n.Name})
+		}
+		return true
+	})
+	analysis := SqlPaser.Analyze(node, nil)
+	for _, table := range append(analysis.Reads, analysis.Writes...) {
+		references = append(references, reference{name: table})
+	}
+	return c.match(references, nil)
+}
+
+// MatchTokens 在 PythonSqlPaser 的令牌流中匹配目录。以点号连接的名称（如 utl_http.request）
+// 和 @@ 变量会先合并为一个名称，关键字和名称按顺序组成单词序列用于匹配 WAITFOR DELAY 之类的短语。
+func (c *RiskCatalog) MatchTokens(tokens []PythonSqlPaser.ParsedToken) []CatalogMatch {
+	var references []reference
+	var words []string
+	var name strings.Builder
+	flush := func(call bool) {
+		if name.Len() > 0 {
+			references = append(references, reference{name: name.String(), call: call})
+			name.Reset()
+		}
+	}
+
+	for _, token := range tokens {
+		tokenType := token.Type.String()
+		isWord := strings.HasPrefix(tokenType, PythonSqlPaser.Name.String()) || strings.HasPrefix(tokenType, PythonSqlPaser.Keyword.String())
+		switch {
+		case isWord:
+			words = append(words, token.Value)
+			name.WriteString(token.Value)
+		case token.Value == "." || (token.Value == "@" || token.Value == "@@") && name.Len() == 0:
+			name.WriteString(token.Value)
+		case tokenType == PythonSqlPaser.Whitespace.String() || tokenType == PythonSqlPaser.Newline.String():
+			// 空白不中断名称，如 SLEEP (5)
+			if name.Len() > 0 && !strings.HasSuffix(name.String(), ".") {
+				flush(false)
+			}
+		default:
+			flush(token.Value == "(")
+			if !strings.HasPrefix(tokenType, PythonSqlPaser.Comment.String()) {
+				words = append(words, token.Value)
+			}
+		}
+	}
+	flush(false)
+	return c.match(references, words)
+}
+
+// Match 同时使用AST和 PythonSqlPaser 的令牌流匹配SQL，令牌流可以覆盖解析失败的语句。
+func (c *RiskCatalog) Match(sql string) []CatalogMatch {
+	tokens, _ := PythonSqlPaser.GetTokens(sql)
+	return c.MatchParsed(SqlPaser.ParseScript(sql), tokens)
+}
+
+// MatchParsed 与 Match 相同，但使用调用者已经解析好的语句和令牌流，tokens 为nil时只匹配AST。
+func (c *RiskCatalog) MatchParsed(script *SqlPaser.Script, tokens []PythonSqlPaser.ParsedToken) []CatalogMatch {
+	matches := c.MatchAST(script)
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		seen[m.Entry.Name] = true
+	}
+	for _, m := range c.MatchTokens(tokens) {
+		if !seen[m.Entry.Name] {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// RiskScore 按命中的风险类别计算0到1之间的风险分数，同一类别只计一次，
+// 各类别视为独立的证据：score = 1 - ∏(1 - weight)。
+func RiskScore(matches []CatalogMatch) float64 {
+	safe := 1.0
+	for category := range matchedCategories(matches) {
+		safe *= 1 - RiskWeights[category]
+	}
+	return 1 - safe
+}
+
+func matchedCategories(matches []CatalogMatch) map[string]bool {
+	categories := make(map[string]bool)
+	for _, m := range matches {
+		categories[m.Entry.Category] = true
+	}
+	return categories
+}
+
+// CatalogFeatures 把命中结果转换为分类器特征：每个命中的类别一个 "catalog:<类别>" 特征，
+// 以及按风险分数分档的 "catalog_risk"。
+func CatalogFeatures(matches []CatalogMatch) map[string]string {
+	features := make(map[string]string)
+	for category := range matchedCategories(matches) {
+		features["catalog:"+category] = "true"
+	}
+	score := RiskScore(matches)
+	switch {
+	case score == 0:
+		features["catalog_risk"] = "none"
+	case score < 0.5:
+		features["catalog_risk"] = "low"
+	case score < 0.9:
+		features["catalog_risk"] = "medium"
+	default:
+		features["catalog_risk"] = "high"
+	}
+	return features
+}
+
+func TestRiskCatalog(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected []string // 命中的条目名
+	}{
+		{"SELECT name FROM users WHERE id = 1", nil},
+		{"SELECT name FROM users WHERE id = 1 AND SLEEP(5)", []string{"sleep"}},
+		{"SELECT @@version, LOAD_FILE('/etc/passwd')", []string{"load_file", "@@version"}},
+		{"SELECT table_name FROM information_schema.tables", []string{"information_schema"}},
+		{"SELECT user, authentication_string FROM mysql.user", []string{"mysql.user"}},
+		{"1; WAITFOR DELAY '0:0:5'--", []string{"waitfor delay"}},
+		{"SELECT utl_http.request('http://x') FROM dual", []string{"utl_http"}},
+		{"exec master..xp_cmdshell 'dir'", []string{"xp_cmdshell"}},
+		{"SELECT a INTO OUTFILE '/tmp/x' FROM t", []string{"into outfile"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, m := range DefaultCatalog.Match(tt.sql) {
+			got = append(got, m.Entry.Name)
+		}
+		sort.Strings(got)
+		sort.Strings(tt.expected)
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("For %q, expected %v but got %v", tt.sql, tt.expected, got)
+		}
+	}
+
+	// AST中 UNION 的每个分支访问的表都要匹配
+	for _, sql := range []string{
+		"SELECT name FROM users WHERE id = 1 UNION ALL SELECT user,password FROM mysql.user",
+		"SELECT name FROM users WHERE id = 1 UNION SELECT table_name FROM information_schema.tables",
+	} {
+		if matches := DefaultCatalog.MatchAST(SqlPaser.ParseScript(sql)); len(matches) != 1 {
+			t.Errorf("For %q, expected a system table match from the AST, got %v", sql, matches)
+		}
+	}
+
+	// 只匹配MySQL时不包含SQL Server的条目
+	if matches := NewRiskCatalog(SqlPaser.DIALECT_MYSQL).Match("WAITFOR DELAY '0:0:5'"); len(matches) != 0 {
+		t.Errorf("Expected no MySQL matches, got %v", matches)
+	}
+
+	matches := DefaultCatalog.Match("SELECT SLEEP(5) FROM information_schema.tables")
+	if score := RiskScore(matches); math.Abs(score-(1-0.1*0.4)) > 1e-9 {
+		t.Errorf("Unexpected risk score %v", score)
+	}
+	features := CatalogFeatures(matches)
+	expected := map[string]string{"catalog:time_delay": "true", "catalog:schema_discovery": "true", "catalog_risk": "high"}
+	if fmt.Sprint(features) != fmt.Sprint(expected) {
+		t.Errorf("Expected features %v, got %v", expected, features)
+	}
+}
@@ -0,0 +1,839 @@
+package Engine
+
+import (
+	"HawkEye-Go/src/PythonSqlPaser"
+	"HawkEye-Go/src/SqlPaser"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity 是规则的严重程度。
+type Severity int
+
+const (
+	SEVERITY_INFO Severity = iota
+	SEVERITY_LOW
+	SEVERITY_MEDIUM
+	SEVERITY_HIGH
+	SEVERITY_CRITICAL
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+// severityScores 是各严重程度的规则命中在综合评分中的分数。
+var severityScores = map[Severity]float64{
+	SEVERITY_INFO:     0,
+	SEVERITY_LOW:      0.3,
+	SEVERITY_MEDIUM:   0.6,
+	SEVERITY_HIGH:     0.9,
+	SEVERITY_CRITICAL: 1,
+}
+
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severityNames {
+		if strings.EqualFold(string(text), name) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Rule 是一条签名规则。All 中的条件都满足、且 Any 为空或其中至少一个条件满足时规则命中。
+type Rule struct {
+	ID          string             `yaml:"id" json:"id"`
+	Description string             `yaml:"description" json:"description"`
+	Severity    Severity           `yaml:"severity" json:"severity"`
+	Disabled    bool               `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	Dialects    []SqlPaser.Dialect `yaml:"dialects,omitempty" json:"dialects,omitempty"` // 为空时适用于所有方言，见 RuleSet.ForDialects
+	All         []Condition        `yaml:"all,omitempty" json:"all,omitempty"`
+	Any         []Condition        `yaml:"any,omitempty" json:"any,omitempty"`
+}
+
+// Condition 是规则的一个条件，设置了多项时要求全部满足，Not 对结果取反。
+type Condition struct {
+	// Tokens 匹配 PythonSqlPaser.GetTokens 输出中连续的令牌（忽略空白和注释）。
+	// 每项为 "值" 或 "类型:值"，类型是去掉 "Token." 前缀的令牌类型，匹配该类型及其子类型，
+	// 如 "Keyword:UNION"、"Name:sleep"、"Literal.Number:*"。值不区分大小写，"*" 匹配任意值。
+	Tokens []string `yaml:"tokens,omitempty" json:"tokens,omitempty"`
+	// AST 匹配SQL中的任一AST节点。
+	AST *ASTPattern `yaml:"ast,omitempty" json:"ast,omitempty"`
+	// Regex 匹配规范化后的SQL，见 NormalizePayload。
+	Regex string `yaml:"regex,omitempty" json:"regex,omitempty"`
+	// Features 要求 ExtractFeatures 的特征取给定的值，值为 "*" 时只要求特征存在。
+	Features map[string]string `yaml:"features,omitempty" json:"features,omitempty"`
+	Not      bool              `yaml:"not,omitempty" json:"not,omitempty"`
+}
+
+// ASTPattern 匹配一个AST节点：Node 为节点类型名，如 "FunctionCall"；
+// Fields 的键为节点JSON格式中的字段名（见 SqlPaser.UnmarshalNode），值为匹配字段值的正则表达式。
+type ASTPattern struct {
+	Node   string            `yaml:"node" json:"node"`
+	Fields map[string]string `yaml:"fields,omitempty" json:"fields,omitempty"`
+}
+
+// RuleFile 是规则文件的格式，可以是YAML或JSON。
+type RuleFile struct {
+	Rules []*Rule `yaml:"rules" json:"rules"`
+}
+
+//go:embed rules.yaml
+var defaultRules []byte
+
+// DefaultRuleSet 返回内置的规则。
+func DefaultRuleSet() *RuleSet {
+	rules, err := ParseRules(defaultRules, "rules.yaml")
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// RuleSet 是编译后的一组规则，可以被多个goroutine同时使用。
+type RuleSet struct {
+	rules []*compiledRule
+}
+
+type compiledRule struct {
+	*Rule
+	all []*compiledCondition
+	any []*compiledCondition
+}
+
+type compiledCondition struct {
+	*Condition
+	tokens []tokenPattern
+	regex  *regexp.Regexp
+	fields map[string]*regexp.Regexp
+}
+
+type tokenPattern struct {
+	tokenType string // 完整的类型名，如 Token.Keyword，为空时匹配任意类型
+	value     string // 为空时匹配任意值
+}
+
+// LoadRules 从文件加载规则，扩展名为 .json 时按JSON解析，否则按YAML解析。
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(data, path)
+}
+
+// ParseRules 解析并编译规则，name 用于判断格式和错误信息。
+func ParseRules(data []byte, name string) (*RuleSet, error) {
+	var file RuleFile
+	var err error
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return CompileRules(file.Rules)
+}
+
+// CompileRules 检查并编译规则，规则ID必须唯一，每条规则至少有一个条件。
+func CompileRules(rules []*Rule) (*RuleSet, error) {
+	set := &RuleSet{}
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("rule without id")
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("duplicate rule id %s", rule.ID)
+		}
+		seen[rule.ID] = true
+		if len(rule.All) == 0 && len(rule.Any) == 0 {
+			return nil, fmt.Errorf("rule %s has no conditions", rule.ID)
+		}
+		compiled := &compiledRule{Rule: rule}
+		for i := range rule.All {
+			c, err := compileCondition(&rule.All[i])
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %v", rule.ID, err)
+			}
+			compiled.all = append(compiled.all, c)
+		}
+		for i := range rule.Any {
+			c, err := compileCondition(&rule.Any[i])
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %v", rule.ID, err)
+			}
+			compiled.any = append(compiled.any, c)
+		}
+		if !rule.Disabled {
+			set.rules = append(set.rules, compiled)
+		}
+	}
+	return set, nil
+}
+
+func compileCondition(condition *Condition) (*compiledCondition, error) {
+	c := &compiledCondition{Condition: condition}
+	if len(condition.Tokens) == 0 && condition.AST == nil && condition.Regex == "" && len(condition.Features) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	for _, pattern := range condition.Tokens {
+		c.tokens = append(c.tokens, parseTokenPattern(pattern))
+	}
+	if condition.Regex != "" {
+		re, err := regexp.Compile(condition.Regex)
+		if err != nil {
+			return nil, err
+		}
+		c.regex = re
+	}
+	if condition.AST != nil {
+		if condition.AST.Node == "" {
+			return nil, fmt.Errorf("ast condition without node type")
+		}
+		c.fields = make(map[string]*regexp.Regexp)
+		for field, expr := range condition.AST.Fields {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("ast field %s: %v", field, err)
+			}
+			c.fields[field] = re
+		}
+	}
+	return c, nil
+}
+
+// parseTokenPattern 解析 "类型:值" 形式的令牌模式，冒号前不是令牌类型时整个字符串作为值。
+func parseTokenPattern(pattern string) tokenPattern {
+	p := tokenPattern{value: pattern}
+	if i := strings.Index(pattern, ":"); i > 0 {
+		tokenType := PythonSqlPaser.Token.String() + "." + pattern[:i]
+		if isTokenTypeName(tokenType) {
+			p = tokenPattern{tokenType: tokenType, value: pattern[i+1:]}
+		}
+	}
+	if p.value == "*" {
+		p.value = ""
+	}
+	return p
+}
+
+// isTokenTypeName 判断名称是否是 PythonSqlPaser 的令牌类型或其前缀。
+func isTokenTypeName(name string) bool {
+	for _, tokenType := range []*PythonSqlPaser.TokenType{
+		PythonSqlPaser.Text, PythonSqlPaser.Whitespace, PythonSqlPaser.Error, PythonSqlPaser.Keyword, PythonSqlPaser.Name,
+		PythonSqlPaser.Literal, PythonSqlPaser.Punctuation, PythonSqlPaser.Operator, PythonSqlPaser.Wildcard,
+		PythonSqlPaser.Comment, PythonSqlPaser.Assignment, PythonSqlPaser.Generic,
+	} {
+		if name == tokenType.String() || strings.HasPrefix(name, tokenType.String()+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p tokenPattern) matches(token PythonSqlPaser.ParsedToken) bool {
+	if p.tokenType != "" {
+		tokenType := token.Type.String()
+		if tokenType != p.tokenType && !strings.HasPrefix(tokenType, p.tokenType+".") {
+			return false
+		}
+	}
+	return p.value == "" || strings.EqualFold(token.Value, p.value)
+}
+
+// Rules 返回规则集中启用的规则。
+func (s *RuleSet) Rules() []*Rule {
+	var rules []*Rule
+	for _, rule := range s.rules {
+		rules = append(rules, rule.Rule)
+	}
+	return rules
+}
+
+// RuleHit 是一条命中的规则。
+type RuleHit struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	Severity    Severity `json:"severity"`
+}
+
+// payload 缓存一条SQL的各种表示，只在规则需要时计算。
+type payload struct {
+	sql        string
+	raw        []PythonSqlPaser.ParsedToken
+	rawDone    bool
+	tokens     []PythonSqlPaser.ParsedToken
+	tokensDone bool
+	script     *SqlPaser.Script
+	normalized *string
+	features   map[string]string
+}
+
+// rawTokens 返回包括空白和注释在内的完整令牌流，分词失败时返回nil。
+func (p *payload) rawTokens() []PythonSqlPaser.ParsedToken {
+	if !p.rawDone {
+		p.rawDone = true
+		p.raw, _ = PythonSqlPaser.GetTokens(p.sql)
+	}
+	return p.raw
+}
+
+func (p *payload) significantTokens() []PythonSqlPaser.ParsedToken {
+	if !p.tokensDone {
+		p.tokensDone = true
+		for _, token := range p.rawTokens() {
+			tokenType := token.Type.String()
+			if !strings.HasPrefix(tokenType, PythonSqlPaser.Whitespace.String()) && !strings.HasPrefix(tokenType, PythonSqlPaser.Comment.String()) {
+				p.tokens = append(p.tokens, token)
+			}
+		}
+	}
+	return p.tokens
+}
+
+func (p *payload) ast() *SqlPaser.Script {
+	if p.script == nil {
+		p.script = SqlPaser.ParseScript(p.sql)
+	}
+	return p.script
+}
+
+func (p *payload) normalizedSQL() string {
+	if p.normalized == nil {
+		normalized := NormalizePayload(p.sql)
+		p.normalized = &normalized
+	}
+	return *p.normalized
+}
+
+// featureValues 在缓存的AST和令牌流上提取分类器特征。特征提取处理的是不可信的输入，
+// 分析过程中的panic不会传到调用者，已经提取的特征保留，并加上 error:analysis 特征。
+func (p *payload) featureValues() map[string]string {
+	if p.features == nil {
+		p.features = make(map[string]string)
+		defer func() {
+			if r := recover(); r != nil {
+				p.features["error:analysis"] = "true"
+			}
+		}()
+		extractFeatures(p.features, p.ast(), p.rawTokens())
+	}
+	return p.features
+}
+
+// NormalizePayload 规范化SQL供规则的正则表达式匹配：去掉注释（MySQL的 /*! */ 中的内容保留），
+// 关键字和标识符转为小写，令牌之间用一个空格分隔，字符串保留单引号。
+// 这样 `UNION/**/SELECT`、`union  select` 都规范化为 `union select`。
+func NormalizePayload(sql string) string {
+	var parts []string
+	for _, token := range SqlPaser.Tokenize(sql) {
+		if token.Type == SqlPaser.STRING {
+			parts = append(parts, "'"+strings.ReplaceAll(token.Value, "'", "''")+"'")
+		} else {
+			parts = append(parts, strings.ToLower(token.Value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ForDialects 返回只包含适用于给定方言的规则的新规则集，不指定方言时返回原规则集。
+func (s *RuleSet) ForDialects(dialects ...SqlPaser.Dialect) *RuleSet {
+	if len(dialects) == 0 {
+		return s
+	}
+	set := &RuleSet{}
+	for _, rule := range s.rules {
+		if len(rule.Dialects) == 0 || hasDialect(rule.Dialects, dialects) {
+			set.rules = append(set.rules, rule)
+		}
+	}
+	return set
+}
+
+// Evaluate 返回SQL命中的规则，按严重程度从高到低排列。
+func (s *RuleSet) Evaluate(sql string) []RuleHit {
+	p := &payload{sql: sql}
+	var hits []RuleHit
+	for _, rule := range s.rules {
+		if rule.matches(p) {
+			hits = append(hits, RuleHit{ID: rule.ID, Description: rule.Description, Severity: rule.Severity})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Severity > hits[j].Severity })
+	return hits
+}
+
+func (r *compiledRule) matches(p *payload) bool {
+	for _, c := range r.all {
+		if !c.matches(p) {
+			return false
+		}
+	}
+	if len(r.any) == 0 {
+		return true
+	}
+	for _, c := range r.any {
+		if c.matches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compiledCondition) matches(p *payload) bool {
+	return c.matchesAll(p) != c.Not
+}
+
+func (c *compiledCondition) matchesAll(p *payload) bool {
+	if c.regex != nil && !c.regex.MatchString(p.normalizedSQL()) {
+		return false
+	}
+	for name, value := range c.Features {
+		actual, ok := p.featureValues()[name]
+		if !ok || (value != "*" && actual != value) {
+			return false
+		}
+	}
+	if len(c.tokens) > 0 && !c.matchTokens(p.significantTokens()) {
+		return false
+	}
+	if c.AST != nil && !c.matchAST(p.ast()) {
+		return false
+	}
+	return true
+}
+
+func (c *compiledCondition) matchTokens(tokens []PythonSqlPaser.ParsedToken) bool {
+	for i := 0; i+len(c.tokens) <= len(tokens); i++ {
+		matched := true
+		for j, pattern := range c.tokens {
+			if !pattern.matches(tokens[i+j]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compiledCondition) matchAST(script *SqlPaser.Script) bool {
+	found := false
+	SqlPaser.Inspect(script, func(node SqlPaser.ASTNode) bool {
+		if found || node == nil {
+			return false
+		}
+		if reflect.TypeOf(node).Elem().Name() == c.AST.Node && c.matchFields(node) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// matchFields 用节点的JSON格式取字段值，这样规则中的字段名与 parse --json 的输出一致。
+func (c *compiledCondition) matchFields(node SqlPaser.ASTNode) bool {
+	if len(c.fields) == 0 {
+		return true
+	}
+	data, err := json.Marshal(node)
+	if err != nil {
+		return false
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	for name, re := range c.fields {
+		value := ""
+		switch v := fields[name].(type) {
+		case nil:
+		case string:
+			value = v
+		case float64, bool:
+			value = fmt.Sprint(v)
+		default: // 子节点和列表不参与匹配
+			return false
+		}
+		if !re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// RuleEngine 持有当前的规则集，并在规则文件修改后自动重新加载。
+// 重新加载失败时继续使用原来的规则。
+type RuleEngine struct {
+	path     string
+	rules    atomic.Pointer[RuleSet]
+	modTime  time.Time
+	mu       sync.Mutex // 保护 modTime 和 lastErr
+	lastErr  error
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// OnReload 在每次尝试重新加载后被调用，err 不为nil时表示加载失败。
+	OnReload func(rules *RuleSet, err error)
+}
+
+// NewRuleEngine 从文件加载规则。
+func NewRuleEngine(path string) (*RuleEngine, error) {
+	e := &RuleEngine{path: path, stop: make(chan struct{})}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	e.rules.Store(rules)
+	e.modTime = info.ModTime()
+	return e, nil
+}
+
+// Rules 返回当前的规则集。
+func (e *RuleEngine) Rules() *RuleSet {
+	return e.rules.Load()
+}
+
+// Evaluate 用当前的规则集检查SQL。
+func (e *RuleEngine) Evaluate(sql string) []RuleHit {
+	return e.Rules().Evaluate(sql)
+}
+
+// Err 返回最近一次重新加载的错误，成功时为nil。
+func (e *RuleEngine) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastErr
+}
+
+// Reload 在规则文件的修改时间变化时重新加载，返回是否加载了新的规则。
+func (e *RuleEngine) Reload() (bool, error) {
+	e.mu.Lock()
+	info, err := os.Stat(e.path)
+	if err != nil || info.ModTime().Equal(e.modTime) {
+		e.mu.Unlock()
+		return false, err
+	}
+	e.modTime = info.ModTime()
+	rules, err := LoadRules(e.path)
+	if err == nil {
+		e.rules.Store(rules)
+	}
+	e.lastErr = err
+	e.mu.Unlock()
+
+	if e.OnReload != nil {
+		e.OnReload(rules, err)
+	}
+	return err == nil, err
+}
+
+// Watch 每隔 interval 检查一次规则文件，直到调用 Close。
+func (e *RuleEngine) Watch(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.Reload()
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止 Watch。
+func (e *RuleEngine) Close() {
+	e.stopOnce.Do(func() { close(e.stop) })
+}
+
+// PolicyMode 决定规则和模型如何共同给出结论。
+type PolicyMode int
+
+const (
+	POLICY_EITHER     PolicyMode = iota // 规则达到 BlockSeverity 或模型概率达到 Threshold 时拦截
+	POLICY_RULES_ONLY                   // 只看规则
+	POLICY_MODEL_ONLY                   // 只看模型
+	POLICY_SCORE                        // 规则分数与模型概率合成一个分数，达到 Threshold 时拦截
+)
+
+var policyModeNames = []string{"either", "rules", "model", "score"}
+
+func (m PolicyMode) String() string {
+	if m >= 0 && int(m) < len(policyModeNames) {
+		return policyModeNames[m]
+	}
+	return fmt.Sprintf("PolicyMode(%d)", int(m))
+}
+
+func (m PolicyMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *PolicyMode) UnmarshalText(text []byte) error {
+	for i, name := range policyModeNames {
+		if strings.EqualFold(string(text), name) {
+			*m = PolicyMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown policy mode %q", text)
+}
+
+// Policy 配置规则命中与 PredictProbability 的组合方式。
+type Policy struct {
+	Mode          PolicyMode `yaml:"mode" json:"mode"`
+	BlockSeverity Severity   `yaml:"block_severity" json:"block_severity"` // 规则命中达到该级别时拦截
+	Threshold     float64    `yaml:"threshold" json:"threshold"`           // 模型概率或综合分数的拦截阈值
+}
+
+// DefaultPolicy 在高危规则命中或模型概率超过0.9时拦截。
+var DefaultPolicy = Policy{Mode: POLICY_EITHER, BlockSeverity: SEVERITY_HIGH, Threshold: 0.9}
+
+// Verdict 是对一条SQL的最终结论。
+type Verdict struct {
+	Block       bool      `json:"block"`
+	Score       float64   `json:"score"`          // 用于与阈值比较的分数，在0到1之间
+	Probability float64   `json:"probability"`    // 模型给出的攻击概率，没有模型时为0
+	Hits        []RuleHit `json:"hits,omitempty"` // 命中的规则，按严重程度从高到低排列
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// RuleEvaluator 是可以检查SQL的规则，*RuleSet 和 *RuleEngine 都实现了它。
+type RuleEvaluator interface {
+	Evaluate(sql string) []RuleHit
+}
+
+// Detector 组合规则和朴素贝叶斯模型。Rules 和 Model 都可以为nil。
+type Detector struct {
+	Rules  RuleEvaluator
+	Model  *NaiveBayes
+	Policy Policy
+}
+
+// Check 检查一条SQL。
+func (d *Detector) Check(sql string) *Verdict {
+	verdict := &Verdict{}
+	if d.Rules != nil && d.Policy.Mode != POLICY_MODEL_ONLY {
+		verdict.Hits = d.Rules.Evaluate(sql)
+	}
+	if d.Model != nil && d.Policy.Mode != POLICY_RULES_ONLY {
+		verdict.Probability = d.Model.PredictProbability(ExtractFeatures(sql))
+		if math.IsNaN(verdict.Probability) { // 没有训练数据
+			verdict.Probability = 0
+		}
+	}
+	d.Policy.decide(verdict)
+	return verdict
+}
+
+// decide 根据规则命中和模型概率给出结论。
+func (p Policy) decide(v *Verdict) {
+	var top *RuleHit
+	ruleScore := 0.0
+	if len(v.Hits) > 0 {
+		top = &v.Hits[0]
+		ruleScore = severityScores[top.Severity]
+	}
+	ruleBlock := top != nil && top.Severity >= p.BlockSeverity
+	modelBlock := v.Probability >= p.Threshold
+
+	switch p.Mode {
+	case POLICY_RULES_ONLY:
+		v.Block, v.Score = ruleBlock, ruleScore
+	case POLICY_MODEL_ONLY:
+		v.Block, v.Score = modelBlock, v.Probability
+	case POLICY_SCORE:
+		// 规则和模型视为独立的证据
+		v.Score = 1 - (1-ruleScore)*(1-v.Probability)
+		v.Block = v.Score >= p.Threshold
+	default:
+		v.Block, v.Score = ruleBlock || modelBlock, math.Max(ruleScore, v.Probability)
+	}
+
+	switch {
+	case !v.Block:
+	case ruleBlock && p.Mode != POLICY_MODEL_ONLY && p.Mode != POLICY_SCORE:
+		v.Reason = fmt.Sprintf("rule %s (%s): %s", top.ID, top.Severity, top.Description)
+	case p.Mode == POLICY_SCORE:
+		v.Reason = fmt.Sprintf("score %.2f reached threshold %.2f", v.Score, p.Threshold)
+	default:
+		v.Reason = fmt.Sprintf("model probability %.2f reached threshold %.2f", v.Probability, p.Threshold)
+	}
+}
+
+func TestRuleEngine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	writeRules := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	writeRules(`
+rules:
+  - id: T-001
+    description: UNION ALL SELECT
+    severity: high
+    all:
+      - tokens: ["Keyword:UNION ALL", "Keyword.DML:SELECT"]
+  - id: T-002
+    description: UNION SELECT without ALL
+    severity: medium
+    all:
+      - regex: 'union\s+select'
+  - id: T-003
+    description: load_file call
+    severity: critical
+    all:
+      - ast: {node: FunctionCall, fields: {name: '(?i)^load_file$'}}
+  - id: T-004
+    description: tautology in WHERE
+    severity: low
+    any:
+      - features: {"condition:tautology": "true"}
+      - tokens: ["OR", "Literal.Number:*", "=", "Literal.Number:*"]
+`, time.Unix(1000, 0))
+
+	engine, err := NewRuleEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{"SELECT name FROM users WHERE id = 1", ""},
+		{"SELECT name FROM users WHERE id = 1 UNION/**/SELECT password FROM admins", "T-002"},
+		{"SELECT name FROM users WHERE id = 1 UNION ALL SELECT password FROM admins", "T-001"},
+		{"SELECT LOAD_FILE('/etc/passwd')", "T-003"},
+		{"SELECT name FROM users WHERE id = 1 OR 1 = 1", "T-004"},
+	}
+	for _, tt := range tests {
+		var ids []string
+		for _, hit := range engine.Evaluate(tt.sql) {
+			ids = append(ids, hit.ID)
+		}
+		if strings.Join(ids, ",") != tt.expected {
+			t.Errorf("For %q, expected %q but got %v", tt.sql, tt.expected, ids)
+		}
+	}
+
+	// 没有修改时不重新加载
+	if reloaded, err := engine.Reload(); reloaded || err != nil {
+		t.Errorf("Unexpected reload %v %v", reloaded, err)
+	}
+	// 加载失败时保留原来的规则
+	writeRules("rules: [{id: broken, all: [{regex: '('}]}]", time.Unix(2000, 0))
+	if _, err := engine.Reload(); err == nil || engine.Err() == nil || len(engine.Rules().Rules()) != 4 {
+		t.Errorf("Expected reload error and old rules, got %v", err)
+	}
+	writeRules("rules: [{id: N-001, severity: info, all: [{tokens: [DROP]}]}]", time.Unix(3000, 0))
+	if reloaded, err := engine.Reload(); !reloaded || err != nil || len(engine.Rules().Rules()) != 1 {
+		t.Errorf("Expected new rules, got %v %v", reloaded, err)
+	}
+
+	if _, err := ParseRules([]byte(`{"rules": [{"id": "J-001", "severity": "bogus", "all": [{"regex": "x"}]}]}`), "rules.json"); err == nil {
+		t.Errorf("Expected error for unknown severity")
+	}
+
+	// 方言
+	dialectRules, err := ParseRules([]byte(`
+rules:
+  - {id: D-001, severity: high, dialects: [postgresql], all: [{tokens: ["Name:pg_sleep"]}]}
+  - {id: D-002, severity: high, all: [{tokens: [DROP]}]}
+`), "dialects.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(dialectRules.ForDialects(SqlPaser.DIALECT_MYSQL).Rules()); n != 1 {
+		t.Errorf("Expected 1 rule for mysql, got %d", n)
+	}
+	if n := len(dialectRules.ForDialects(SqlPaser.DIALECT_MYSQL, SqlPaser.DIALECT_POSTGRESQL).Rules()); n != 2 {
+		t.Errorf("Expected 2 rules for mysql and postgresql, got %d", n)
+	}
+
+	// 策略
+	hits := []RuleHit{{ID: "R", Severity: SEVERITY_MEDIUM}}
+	policies := []struct {
+		policy      Policy
+		hits        []RuleHit
+		probability float64
+		block       bool
+	}{
+		{DefaultPolicy, hits, 0.5, false},
+		{DefaultPolicy, hits, 0.95, true},
+		{Policy{Mode: POLICY_EITHER, BlockSeverity: SEVERITY_MEDIUM, Threshold: 0.9}, hits, 0, true},
+		{Policy{Mode: POLICY_RULES_ONLY, BlockSeverity: SEVERITY_HIGH}, hits, 1, false},
+		{Policy{Mode: POLICY_MODEL_ONLY, Threshold: 0.5}, hits, 0.6, true},
+		{Policy{Mode: POLICY_SCORE, Threshold: 0.8}, hits, 0.5, true}, // 1 - 0.4 * 0.5
+		{Policy{Mode: POLICY_SCORE, Threshold: 0.8}, nil, 0.5, false},
+	}
+	for i, tt := range policies {
+		verdict := &Verdict{Hits: tt.hits, Probability: tt.probability}
+		tt.policy.decide(verdict)
+		if verdict.Block != tt.block {
+			t.Errorf("Policy %d: expected block %t, got %+v", i, tt.block, verdict)
+		}
+	}
+
+	detector := &Detector{Rules: engine, Policy: Policy{Mode: POLICY_RULES_ONLY, BlockSeverity: SEVERITY_INFO}}
+	if verdict := detector.Check("DROP TABLE users"); !verdict.Block || verdict.Reason == "" {
+		t.Errorf("Expected DROP to be blocked, got %+v", verdict)
+	}
+
+	if rules := DefaultRuleSet().Evaluate("SELECT 1 UNION SELECT @@version"); len(rules) == 0 {
+		t.Errorf("Expected default rules to match UNION SELECT")
+	}
+
+	// 词法分析器不认识的字符不能截断载荷，加上这些字符的载荷与原来的载荷命中相同的规则
+	if normalized := NormalizePayload("1|0 UNION SELECT password FROM mysql.user"); normalized != "1 | 0 union select password from mysql.user" {
+		t.Errorf("Unexpected normalized payload %q", normalized)
+	}
+	defaults := &Detector{Rules: DefaultRuleSet(), Policy: DefaultPolicy}
+	for _, pair := range [][2]string{
+		{"1 UNION SELECT password FROM mysql.user", "1|0 UNION SELECT password FROM mysql.user"},
+		{"1; DROP TABLE users", "1 &1; DROP TABLE users"},
+	} {
+		plain, evasive := defaults.Check(pair[0]), defaults.Check(pair[1])
+		if !plain.Block || !evasive.Block || evasive.Hits[0].ID != plain.Hits[0].ID {
+			t.Errorf("Expected %q to be blocked like %q, got %+v and %+v", pair[1], pair[0], evasive, plain)
+		}
+	}
+}
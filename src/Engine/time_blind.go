@@ -0,0 +1,482 @@
+package Engine
+
+import (
+	"HawkEye-Go/src/PythonSqlPaser"
+	"HawkEye-Go/src/SqlPaser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TimeBlindFinding 是一个时间盲注的延时原语。
+type TimeBlindFinding struct {
+	Primitive   string  // 延时原语，如 sleep、benchmark、waitfor delay、heavy query
+	Text        string  // 命中的SQL
+	Seconds     float64 // 估计的延时秒数，无法估计时为0
+	Conditional bool    // 位于CASE或IF的分支中，只在条件成立时延时，通常用于逐位提取数据
+	Subquery    bool    // 位于子查询中
+}
+
+// delayArg 是延时函数的一个参数。
+type delayArg struct {
+	value    interface{} // 常量参数的值，见 SqlPaser.EvalConstant，不是常量时为nil
+	function string      // 参数是函数调用时的函数名，小写
+}
+
+// delayFunction 描述一个延时函数以及如何根据参数估计延时。
+type delayFunction struct {
+	primitive string
+	estimate  func(args []delayArg) float64
+}
+
+// delayFunctions 是各数据库中可以用来制造延时的函数，键为小写的函数名。
+var delayFunctions = map[string]delayFunction{
+	"sleep":                     {"sleep", secondsArg(0)},
+	"pg_sleep":                  {"pg_sleep", secondsArg(0)},
+	"pg_sleep_for":              {"pg_sleep", intervalArg(0)},
+	"pg_sleep_until":            {"pg_sleep", nil},
+	"dbms_lock.sleep":           {"dbms_lock.sleep", secondsArg(0)},
+	"dbms_session.sleep":        {"dbms_lock.sleep", secondsArg(0)},
+	"dbms_pipe.receive_message": {"dbms_pipe.receive_message", secondsArg(1)},
+	"benchmark":                 {"benchmark", benchmarkDelay},
+	"randomblob":                {"randomblob", randomblobDelay},
+}
+
+// benchmarkCosts 是 BENCHMARK 中常见表达式每次执行的大致耗时（秒）。
+var benchmarkCosts = map[string]float64{
+	"md5":         2.5e-7,
+	"sha":         3e-7,
+	"sha1":        3e-7,
+	"sha2":        6e-7,
+	"encode":      1e-6,
+	"aes_encrypt": 1e-6,
+}
+
+// defaultBenchmarkCost 是其他表达式每次执行的耗时。
+const defaultBenchmarkCost = 1e-7
+
+func secondsArg(i int) func([]delayArg) float64 {
+	return func(args []delayArg) float64 {
+		if i < len(args) {
+			return argNumber(args[i])
+		}
+		return 0
+	}
+}
+
+func intervalArg(i int) func([]delayArg) float64 {
+	return func(args []delayArg) float64 {
+		if i < len(args) {
+			if text, ok := args[i].value.(string); ok {
+				return parseInterval(text)
+			}
+		}
+		return 0
+	}
+}
+
+// benchmarkDelay 估计 BENCHMARK(count, expr) 的耗时，如 BENCHMARK(1e7, MD5(1)) 约为2.5秒。
+func benchmarkDelay(args []delayArg) float64 {
+	if len(args) < 2 {
+		return 0
+	}
+	cost, ok := benchmarkCosts[args[1].function]
+	if !ok {
+		cost = defaultBenchmarkCost
+	}
+	return argNumber(args[0]) * cost
+}
+
+// randomblobDelay 估计SQLite中 RANDOMBLOB(n) 的耗时，生成1亿字节约需1秒。
+func randomblobDelay(args []delayArg) float64 {
+	if len(args) == 0 {
+		return 0
+	}
+	return argNumber(args[0]) * 1e-8
+}
+
+func argNumber(arg delayArg) float64 {
+	switch v := arg.value.(type) {
+	case float64:
+		return v
+	case string:
+		number, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number
+	}
+	return 0
+}
+
+// parseInterval 解析PostgreSQL的时间间隔，如 '5 seconds'、'1 minute'、'00:00:05'。
+func parseInterval(text string) float64 {
+	if strings.Contains(text, ":") {
+		return parseClock(text)
+	}
+	units := map[string]float64{"ms": 0.001, "millisecond": 0.001, "s": 1, "sec": 1, "second": 1, "min": 60, "minute": 60, "h": 3600, "hour": 3600}
+	var seconds float64
+	fields := strings.Fields(strings.ToLower(text))
+	for i := 0; i < len(fields); i++ {
+		number, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			continue
+		}
+		unit := 1.0
+		if i+1 < len(fields) {
+			if u, ok := units[strings.TrimSuffix(fields[i+1], "s")]; ok {
+				unit = u
+				i++
+			} else if u, ok := units[fields[i+1]]; ok {
+				unit = u
+				i++
+			}
+		}
+		seconds += number * unit
+	}
+	return seconds
+}
+
+// parseClock 解析 hh:mm:ss[.fff] 格式的时长，WAITFOR DELAY 使用这种格式。
+func parseClock(text string) float64 {
+	var seconds float64
+	for _, part := range strings.Split(strings.TrimSpace(text), ":") {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + number
+	}
+	return seconds
+}
+
+// lookupDelayFunction 按函数名查找延时函数，带库名的调用如 pg_catalog.pg_sleep 按函数名匹配。
+func lookupDelayFunction(name string) (delayFunction, bool) {
+	name = strings.ToLower(name)
+	if fn, ok := delayFunctions[name]; ok {
+		return fn, true
+	}
+	fn, ok := delayFunctions[lastNamePart(name)]
+	return fn, ok && !strings.Contains(fn.primitive, ".")
+}
+
+// DetectTimeBlind 检测SQL中的时间盲注延时原语，包括嵌套在CASE、IF和子查询中的。
+// 能解析的语句在AST上检测，无法解析的部分（如 WAITFOR DELAY 或注入片段）由令牌流补充。
+func DetectTimeBlind(sql string) []*TimeBlindFinding {
+	tokens, _ := PythonSqlPaser.GetTokens(sql)
+	return DetectTimeBlindParsed(SqlPaser.ParseScript(sql), tokens)
+}
+
+// DetectTimeBlindParsed 与 DetectTimeBlind 相同，但使用调用者已经解析好的语句和令牌流，tokens 为nil时只检测AST。
+func DetectTimeBlindParsed(script *SqlPaser.Script, tokens []PythonSqlPaser.ParsedToken) []*TimeBlindFinding {
+	findings := DetectTimeBlindAST(script)
+	// 令牌流中的结果与AST重复时，只保留AST的结果，它有更准确的上下文
+	found := make(map[string]int)
+	for _, finding := range findings {
+		found[finding.Primitive]++
+	}
+	for _, finding := range DetectTimeBlindTokens(tokens) {
+		if found[finding.Primitive] > 0 {
+			found[finding.Primitive]--
+			continue
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// DetectTimeBlindAST 在AST中检测延时函数和笛卡尔积之类的重查询。
+func DetectTimeBlindAST(node SqlPaser.ASTNode) []*TimeBlindFinding {
+	v := &delayVisitor{findings: new([]*TimeBlindFinding)}
+	SqlPaser.Walk(v, node)
+	return *v.findings
+}
+
+// delayVisitor 在遍历AST时记录当前节点是否位于条件分支或子查询中。
+type delayVisitor struct {
+	findings    *[]*TimeBlindFinding
+	conditional bool
+	subquery    bool
+}
+
+func (v *delayVisitor) Visit(node SqlPaser.ASTNode) SqlPaser.Visitor {
+	switch n := node.(type) {
+	case *SqlPaser.CaseExpr:
+		// 条件本身总会执行，只有分支的结果和ELSE是有条件的
+		branch := &delayVisitor{findings: v.findings, conditional: true, subquery: v.subquery}
+		SqlPaser.Walk(v, n.Expr)
+		for _, b := range n.Branches {
+			SqlPaser.Walk(v, b.Condition)
+			SqlPaser.Walk(branch, b.Result)
+		}
+		SqlPaser.Walk(branch, n.Default)
+		return nil
+	case *SqlPaser.Subquery, *SqlPaser.NestedSubQuery:
+		return &delayVisitor{findings: v.findings, conditional: v.conditional, subquery: true}
+	case *SqlPaser.FunctionCall:
+		if fn, ok := lookupDelayFunction(n.Name); ok {
+			var args []delayArg
+			for _, arg := range n.Args {
+				args = append(args, astDelayArg(arg))
+			}
+			v.add(fn.primitive, n.String(), estimate(fn, args))
+		}
+	case *SqlPaser.SelectStatement:
+		if isHeavyQuery(n) {
+			v.add("heavy query", n.From.String(), 0)
+		}
+	}
+	return v
+}
+
+func (v *delayVisitor) add(primitive, text string, seconds float64) {
+	*v.findings = append(*v.findings, &TimeBlindFinding{
+		Primitive:   primitive,
+		Text:        text,
+		Seconds:     seconds,
+		Conditional: v.conditional,
+		Subquery:    v.subquery,
+	})
+}
+
+func estimate(fn delayFunction, args []delayArg) float64 {
+	if fn.estimate == nil {
+		return 0
+	}
+	return fn.estimate(args)
+}
+
+func astDelayArg(node SqlPaser.ASTNode) delayArg {
+	arg := delayArg{}
+	if value, ok := SqlPaser.EvalConstant(node, SqlPaser.FoldOptions{}); ok {
+		arg.value = value
+	}
+	if call, ok := node.(*SqlPaser.FunctionCall); ok {
+		arg.function = strings.ToLower(call.Name)
+	}
+	return arg
+}
+
+// isHeavyQuery 判断查询是否是没有连接条件的多表笛卡尔积，如
+// information_schema.columns A, information_schema.columns B, information_schema.columns C，
+// 它们不调用延时函数，同样可以让查询执行数秒。
+func isHeavyQuery(stmt *SqlPaser.SelectStatement) bool {
+	if stmt.From == nil {
+		return false
+	}
+	sources := []string{stmt.From.TableName}
+	for _, join := range stmt.From.Joins {
+		if join.On != nil || join.Using != nil || (join.Type != SqlPaser.COMMA && join.Type != SqlPaser.CROSS) {
+			continue
+		}
+		if join.Table != nil {
+			sources = append(sources, join.Table.Name)
+		} else {
+			sources = append(sources, "")
+		}
+	}
+	if len(sources) < 3 {
+		return false
+	}
+	if stmt.Where == nil {
+		return true
+	}
+	// 系统表通常很大，即使有WHERE条件，它们的笛卡尔积也很慢
+	for _, source := range sources {
+		for _, m := range DefaultCatalog.match([]reference{{name: source}}, nil) {
+			if m.Entry.Category == SCHEMA_DISCOVERY {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DetectTimeBlindTokens 在 PythonSqlPaser 的令牌流中检测延时函数和 WAITFOR，
+// 用于无法解析的语句，结果不区分条件分支和子查询。
+func DetectTimeBlindTokens(tokens []PythonSqlPaser.ParsedToken) []*TimeBlindFinding {
+	var significant []PythonSqlPaser.ParsedToken
+	for _, token := range tokens {
+		tokenType := token.Type.String()
+		if !strings.HasPrefix(tokenType, PythonSqlPaser.Whitespace.String()) && !strings.HasPrefix(tokenType, PythonSqlPaser.Comment.String()) {
+			significant = append(significant, token)
+		}
+	}
+
+	var findings []*TimeBlindFinding
+	for i := 0; i < len(significant); i++ {
+		if !isWordToken(significant[i]) {
+			continue
+		}
+		// WAITFOR DELAY '0:0:5' 和 WAITFOR TIME '23:59'
+		if strings.EqualFold(significant[i].Value, "WAITFOR") && i+1 < len(significant) {
+			mode := strings.ToUpper(significant[i+1].Value)
+			if mode == "DELAY" || mode == "TIME" {
+				finding := &TimeBlindFinding{Primitive: "waitfor " + strings.ToLower(mode), Text: "WAITFOR " + mode}
+				if i+2 < len(significant) && isStringToken(significant[i+2]) {
+					finding.Text += " " + significant[i+2].Value
+					if mode == "DELAY" {
+						finding.Seconds = parseClock(unquote(significant[i+2].Value))
+					}
+				}
+				findings = append(findings, finding)
+				continue
+			}
+		}
+
+		// 以点号连接的函数名，如 DBMS_LOCK.SLEEP
+		start, name := i, significant[i].Value
+		for i+2 < len(significant) && significant[i+1].Value == "." && isWordToken(significant[i+2]) {
+			name += "." + significant[i+2].Value
+			i += 2
+		}
+		if i+1 >= len(significant) || significant[i+1].Value != "(" {
+			continue
+		}
+		fn, ok := lookupDelayFunction(name)
+		if !ok {
+			continue
+		}
+		args, end := tokenDelayArgs(significant, i+1)
+		var text strings.Builder
+		for _, token := range significant[start:end] {
+			text.WriteString(token.Value)
+		}
+		findings = append(findings, &TimeBlindFinding{Primitive: fn.primitive, Text: text.String(), Seconds: estimate(fn, args)})
+	}
+	return findings
+}
+
+// tokenDelayArgs 解析从左括号 open 开始的参数列表，返回参数和右括号之后的位置。
+func tokenDelayArgs(tokens []PythonSqlPaser.ParsedToken, open int) ([]delayArg, int) {
+	var args []delayArg
+	var current []PythonSqlPaser.ParsedToken
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].Value {
+		case "(":
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ")":
+			depth--
+			if depth == 0 {
+				if len(current) > 0 {
+					args = append(args, tokenDelayArg(current))
+				}
+				return args, i + 1
+			}
+		case ",":
+			if depth == 1 {
+				args = append(args, tokenDelayArg(current))
+				current = nil
+				continue
+			}
+		}
+		current = append(current, tokens[i])
+	}
+	return args, len(tokens)
+}
+
+func tokenDelayArg(tokens []PythonSqlPaser.ParsedToken) delayArg {
+	arg := delayArg{}
+	if len(tokens) == 1 {
+		if isStringToken(tokens[0]) {
+			arg.value = unquote(tokens[0].Value)
+		} else if strings.HasPrefix(tokens[0].Type.String(), PythonSqlPaser.Number.String()) {
+			if number, err := strconv.ParseFloat(tokens[0].Value, 64); err == nil {
+				arg.value = number
+			}
+		}
+	}
+	if len(tokens) > 1 && isWordToken(tokens[0]) && tokens[1].Value == "(" {
+		arg.function = strings.ToLower(tokens[0].Value)
+	}
+	return arg
+}
+
+func isWordToken(token PythonSqlPaser.ParsedToken) bool {
+	tokenType := token.Type.String()
+	return strings.HasPrefix(tokenType, PythonSqlPaser.Name.String()) || strings.HasPrefix(tokenType, PythonSqlPaser.Keyword.String())
+}
+
+func isStringToken(token PythonSqlPaser.ParsedToken) bool {
+	return strings.HasPrefix(token.Type.String(), PythonSqlPaser.String.String()) && strings.HasPrefix(token.Value, "'")
+}
+
+// unquote 去掉单引号字符串的引号，并把两个连续的单引号还原为一个。
+func unquote(value string) string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "'"), "'")
+	return strings.ReplaceAll(value, "''", "'")
+}
+
+// TimeBlindFeatures 把时间盲注的检测结果转换为分类器特征。
+func TimeBlindFeatures(findings []*TimeBlindFinding) map[string]string {
+	features := make(map[string]string)
+	if len(findings) == 0 {
+		return features
+	}
+	features["time_blind"] = "true"
+	var seconds float64
+	for _, finding := range findings {
+		if finding.Conditional {
+			features["time_blind:conditional"] = "true"
+		}
+		if finding.Seconds > seconds {
+			seconds = finding.Seconds
+		}
+	}
+	switch {
+	case seconds == 0:
+		features["time_blind_delay"] = "unknown"
+	case seconds < 1:
+		features["time_blind_delay"] = "short"
+	case seconds < 10:
+		features["time_blind_delay"] = "medium"
+	default:
+		features["time_blind_delay"] = "long"
+	}
+	return features
+}
+
+func TestDetectTimeBlind(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string // 原语:秒数，条件分支中的带 ?，子查询中的带 ^，多个结果以空格分隔
+	}{
+		{"SELECT name FROM users WHERE id = 1", ""},
+		{"SELECT name FROM users WHERE id = 1 AND SLEEP(5)", "sleep:5"},
+		{"SELECT name FROM users WHERE id = 1 AND BENCHMARK(1e7, MD5(1))", "benchmark:2.5"},
+		{"SELECT pg_sleep(0.5)", "pg_sleep:0.5"},
+		{"SELECT pg_sleep_for('1 minute 30 seconds')", "pg_sleep:90"},
+		{"SELECT * FROM t WHERE IF(ASCII(SUBSTR(database(),1,1))>100, SLEEP(3), 0)", "sleep:3?"},
+		{"SELECT * FROM t WHERE CASE WHEN 1=1 THEN SLEEP(2*5) ELSE 0 END", "sleep:10?"},
+		{"SELECT * FROM t WHERE id IN (SELECT SLEEP(5))", "sleep:5^"},
+		{"SELECT * FROM t WHERE DBMS_LOCK.SLEEP(5) = 1", "dbms_lock.sleep:5"},
+		{"1; WAITFOR DELAY '0:0:5'--", "waitfor delay:5"},
+		{"1 AND SLEEP(5)-- -", "sleep:5"},
+		{"x AND DBMS_PIPE.RECEIVE_MESSAGE('a', 10) = 1", "dbms_pipe.receive_message:10"},
+		{"SELECT COUNT(*) FROM information_schema.columns A, information_schema.columns B, information_schema.columns C", "heavy query:0"},
+		{"SELECT * FROM users u, orders o, items i WHERE u.id = o.user_id AND o.id = i.order_id", ""},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, finding := range DetectTimeBlind(tt.sql) {
+			s := finding.Primitive + ":" + strconv.FormatFloat(finding.Seconds, 'g', -1, 64)
+			if finding.Conditional {
+				s += "?"
+			}
+			if finding.Subquery {
+				s += "^"
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, " ") != tt.expected {
+			t.Errorf("For %q, expected %q but got %q", tt.sql, tt.expected, strings.Join(got, " "))
+		}
+	}
+
+	features := TimeBlindFeatures(DetectTimeBlind("SELECT IF(1=1, SLEEP(5), 0)"))
+	if features["time_blind"] != "true" || features["time_blind:conditional"] != "true" || features["time_blind_delay"] != "medium" {
+		t.Errorf("Unexpected features %v", features)
+	}
+}
@@ -0,0 +1,9 @@
+package LogScan
+
+import "testing"
+
+// TestLogScan 运行与被测代码写在同一文件中的测试函数。
+func TestLogScan(t *testing.T) {
+	t.Run("ParseLog", TestParseLog)
+	t.Run("Scanner", TestScanner)
+}
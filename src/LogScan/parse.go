@@ -0,0 +1,456 @@
+package LogScan
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Format 是日志的格式。
+type Format int
+
+const (
+	FORMAT_AUTO          Format = iota // 根据开头的内容识别
+	FORMAT_MYSQL_GENERAL               // MySQL general query log
+	FORMAT_MYSQL_SLOW                  // MySQL slow query log
+	FORMAT_POSTGRES                    // PostgreSQL log_statement 输出
+	FORMAT_ACCESS                      // Apache/Nginx combined 访问日志
+)
+
+var formatNames = []string{"auto", "mysql-general", "mysql-slow", "postgres", "access"}
+
+func (f Format) String() string {
+	if f >= 0 && int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+func (f Format) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *Format) UnmarshalText(text []byte) error {
+	for i, name := range formatNames {
+		if strings.EqualFold(string(text), name) {
+			*f = Format(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log format %q", text)
+}
+
+// EntryKind 是日志条目的类型。
+type EntryKind int
+
+const (
+	ENTRY_STATEMENT EntryKind = iota // 完整的SQL语句
+	ENTRY_PARAMETER                  // 预编译语句的绑定参数
+	ENTRY_REQUEST                    // HTTP请求
+)
+
+// Entry 是从日志中解析出的一个待检查条目。
+type Entry struct {
+	File   string
+	Line   int // 条目开始的行号，从1开始
+	Time   time.Time
+	Format Format
+	Kind   EntryKind
+	Text   string // 语句、参数值或请求URI
+	Name   string // 参数名，如 $1
+	Method string // 请求方法
+}
+
+// ParseLog 逐条解析日志并调用emit，emit返回错误时停止解析。
+// 多行语句合并为一个条目，行号为第一行。
+func ParseLog(r io.Reader, file string, format Format, emit func(*Entry) error) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	if format == FORMAT_AUTO {
+		head, _ := reader.Peek(16 * 1024)
+		if format = DetectFormat(head); format == FORMAT_AUTO {
+			return fmt.Errorf("%s: unrecognized log format", file)
+		}
+	}
+	p := &logParser{file: file, format: format, emit: emit}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		p.line++
+		var err error
+		switch format {
+		case FORMAT_MYSQL_GENERAL:
+			err = p.mysqlGeneral(scanner.Text())
+		case FORMAT_MYSQL_SLOW:
+			err = p.mysqlSlow(scanner.Text())
+		case FORMAT_POSTGRES:
+			err = p.postgres(scanner.Text())
+		case FORMAT_ACCESS:
+			err = p.access(scanner.Text())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s:%d: %v", file, p.line, err)
+	}
+	return p.flush()
+}
+
+// DetectFormat 根据日志开头的内容识别格式，无法识别时返回 FORMAT_AUTO。
+func DetectFormat(head []byte) Format {
+	lines := bytes.Split(head, []byte("\n"))
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1] // 最后一行可能不完整
+	}
+	for _, line := range lines {
+		text := string(line)
+		switch {
+		case strings.HasPrefix(text, "# Time: ") || strings.HasPrefix(text, "# User@Host: "):
+			return FORMAT_MYSQL_SLOW
+		case mysqlGeneralLine.MatchString(text):
+			return FORMAT_MYSQL_GENERAL
+		case postgresMessage.MatchString(text):
+			return FORMAT_POSTGRES
+		case accessLine.MatchString(text):
+			return FORMAT_ACCESS
+		}
+	}
+	return FORMAT_AUTO
+}
+
+// logParser 保存解析多行语句时的状态。
+type logParser struct {
+	file   string
+	format Format
+	emit   func(*Entry) error
+	line   int
+	time   time.Time
+
+	pending   *Entry // 还可能有后续行的语句
+	statement strings.Builder
+}
+
+// begin 开始一条新语句，之前的语句被提交。
+func (p *logParser) begin(text string) error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	p.pending = &Entry{File: p.file, Line: p.line, Time: p.time, Format: p.format, Kind: ENTRY_STATEMENT}
+	p.statement.WriteString(text)
+	return nil
+}
+
+// continueLine 把一行追加到当前语句，没有语句时忽略。
+func (p *logParser) continueLine(text string) {
+	if p.pending != nil {
+		p.statement.WriteByte('\n')
+		p.statement.WriteString(text)
+	}
+}
+
+// flush 提交当前语句。
+func (p *logParser) flush() error {
+	if p.pending == nil {
+		return nil
+	}
+	entry := p.pending
+	entry.Text = strings.TrimSpace(p.statement.String())
+	p.pending = nil
+	p.statement.Reset()
+	if entry.Text == "" {
+		return nil
+	}
+	return p.emit(entry)
+}
+
+// mysqlGeneralLine 匹配general log的一条记录，5.7以后时间为ISO格式，之前为 YYMMDD HH:MM:SS，同一秒内的记录省略时间。
+var mysqlGeneralLine = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})?|\d{6}\s+\d{1,2}:\d{2}:\d{2})?\s+(\d+)\s([A-Z][a-z]+(?: [A-Z]?[a-z]+)?)\t(.*)$`)
+
+// mysqlServerHeader 匹配MySQL重启时写入日志的文件头。
+var mysqlServerHeader = regexp.MustCompile(`^(?:\S+, Version: |Tcp port: |Time\s+Id\s+Command\s+Argument)`)
+
+func (p *logParser) mysqlGeneral(line string) error {
+	if mysqlServerHeader.MatchString(line) {
+		return p.flush()
+	}
+	match := mysqlGeneralLine.FindStringSubmatch(line)
+	if match == nil {
+		p.continueLine(line)
+		return nil
+	}
+	if match[1] != "" {
+		p.time = parseTime(match[1])
+	}
+	if err := p.flush(); err != nil {
+		return err
+	}
+	switch match[3] {
+	case "Query", "Execute":
+		return p.begin(match[4])
+	}
+	return nil
+}
+
+var slowTimestamp = regexp.MustCompile(`^SET timestamp=(\d+);$`)
+
+func (p *logParser) mysqlSlow(line string) error {
+	switch {
+	case strings.HasPrefix(line, "# Time: "):
+		p.time = parseTime(strings.TrimSpace(line[len("# Time: "):]))
+		return p.flush()
+	case strings.HasPrefix(line, "#"), mysqlServerHeader.MatchString(line):
+		return p.flush()
+	}
+	if p.pending == nil {
+		if match := slowTimestamp.FindStringSubmatch(line); match != nil {
+			seconds, _ := strconv.ParseInt(match[1], 10, 64)
+			p.time = time.Unix(seconds, 0).UTC()
+			return nil
+		}
+		if strings.HasPrefix(strings.ToLower(line), "use ") && strings.HasSuffix(line, ";") {
+			return nil
+		}
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		if err := p.begin(line); err != nil {
+			return err
+		}
+	} else {
+		p.continueLine(line)
+	}
+	// 语句以行尾的分号结束
+	if strings.HasSuffix(strings.TrimSpace(line), ";") {
+		return p.flush()
+	}
+	return nil
+}
+
+// postgresMessage 匹配PostgreSQL日志中的一条消息，行首的 log_line_prefix 可以自定义，只从中取出时间。
+var postgresMessage = regexp.MustCompile(`\b(LOG|ERROR|STATEMENT|DETAIL|WARNING|FATAL|NOTICE|HINT|CONTEXT):\s+(.*)$`)
+
+var postgresTime = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?(?: [A-Z]{2,5}| [+-]\d{2}(?::?\d{2})?)?)`)
+
+var postgresStatement = regexp.MustCompile(`^(?:duration: [\d.]+ ms\s+)?(?:statement|execute [^:]*|bind [^:]*): ([\s\S]*)$`)
+
+var postgresParameter = regexp.MustCompile(`\$(\d+) = (NULL|'(?:[^']|'')*')`)
+
+func (p *logParser) postgres(line string) error {
+	if strings.HasPrefix(line, "\t") {
+		p.continueLine(line[1:])
+		return nil
+	}
+	match := postgresMessage.FindStringSubmatch(line)
+	if match == nil {
+		p.continueLine(line)
+		return nil
+	}
+	if err := p.flush(); err != nil {
+		return err
+	}
+	if t := postgresTime.FindString(line); t != "" {
+		p.time = parseTime(t)
+	}
+	message := match[2]
+	switch match[1] {
+	case "LOG":
+		if statement := postgresStatement.FindStringSubmatch(message); statement != nil {
+			return p.begin(statement[1])
+		}
+	case "STATEMENT":
+		return p.begin(message)
+	case "DETAIL":
+		if strings.HasPrefix(message, "parameters: ") {
+			for _, parameter := range postgresParameter.FindAllStringSubmatch(message, -1) {
+				if parameter[2] == "NULL" {
+					continue
+				}
+				value := strings.ReplaceAll(parameter[2][1:len(parameter[2])-1], "''", "'")
+				entry := &Entry{File: p.file, Line: p.line, Time: p.time, Format: p.format, Kind: ENTRY_PARAMETER, Name: "$" + parameter[1], Text: value}
+				if err := p.emit(entry); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// accessLine 匹配combined或common格式的访问日志。
+var accessLine = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)(?: [^"]*)?" \d{3} \S+`)
+
+func (p *logParser) access(line string) error {
+	match := accessLine.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	p.time = parseTime(match[1])
+	return p.emit(&Entry{File: p.file, Line: p.line, Time: p.time, Format: p.format, Kind: ENTRY_REQUEST, Method: match[2], Text: unescapeLogString(match[3])})
+}
+
+// unescapeLogString 还原Nginx和Apache在日志中转义的字节，如 \x22。
+func unescapeLogString(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if n, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// timeLayouts 是日志中常见的时间格式，解析时秒后面的小数部分可以省略。
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"060102 15:04:05",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -07",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"02/Jan/2006:15:04:05 -0700",
+}
+
+// parseTime 解析日志中的时间，无法解析时返回零值。
+func parseTime(value string) time.Time {
+	// MySQL 5.6 的小时不足两位时用空格补齐，如 240115  9:00:00
+	if fields := strings.Fields(value); len(fields) == 2 && len(fields[0]) == 6 && len(fields[1]) == 7 {
+		value = fields[0] + " 0" + fields[1]
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func TestParseLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		input    string
+		expected []Entry // 只比较 Line、Kind、Text、Name、Method 和时间是否为零
+	}{
+		{
+			"mysql general",
+			FORMAT_MYSQL_GENERAL,
+			"/usr/sbin/mysqld, Version: 8.0.36 (MySQL Community Server - GPL). started with:\n" +
+				"Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock\n" +
+				"Time                 Id Command    Argument\n" +
+				"2024-01-15T10:00:00.123456Z\t    8 Connect\troot@localhost on shop using Socket\n" +
+				"2024-01-15T10:00:01.000000Z\t    8 Query\tSELECT name FROM users WHERE id = 1\n" +
+				"2024-01-15T10:00:02.000000Z\t    8 Query\tSELECT *\n" +
+				"FROM orders\n" +
+				"WHERE id = 2 OR 1=1\n" +
+				"2024-01-15T10:00:03.000000Z\t    8 Quit\t\n",
+			[]Entry{
+				{Line: 5, Kind: ENTRY_STATEMENT, Text: "SELECT name FROM users WHERE id = 1"},
+				{Line: 6, Kind: ENTRY_STATEMENT, Text: "SELECT *\nFROM orders\nWHERE id = 2 OR 1=1"},
+			},
+		},
+		{
+			"mysql general 5.6",
+			FORMAT_AUTO,
+			"240115  9:00:00\t    3 Query\tSELECT 1\n" +
+				"\t\t    3 Query\tSELECT SLEEP(5)\n",
+			[]Entry{
+				{Line: 1, Kind: ENTRY_STATEMENT, Text: "SELECT 1"},
+				{Line: 2, Kind: ENTRY_STATEMENT, Text: "SELECT SLEEP(5)"},
+			},
+		},
+		{
+			"mysql slow",
+			FORMAT_AUTO,
+			"# Time: 2024-01-15T10:00:00.123456Z\n" +
+				"# User@Host: app[app] @ localhost []  Id:     8\n" +
+				"# Query_time: 5.000321  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 0\n" +
+				"use shop;\n" +
+				"SET timestamp=1705312800;\n" +
+				"SELECT name FROM users\n" +
+				"WHERE id = 1 AND SLEEP(5);\n" +
+				"# Time: 2024-01-15T10:00:07.000000Z\n" +
+				"# Query_time: 2.1  Lock_time: 0.0 Rows_sent: 0  Rows_examined: 100\n" +
+				"SET timestamp=1705312807;\n" +
+				"SELECT COUNT(*) FROM orders;\n",
+			[]Entry{
+				{Line: 6, Kind: ENTRY_STATEMENT, Text: "SELECT name FROM users\nWHERE id = 1 AND SLEEP(5);"},
+				{Line: 11, Kind: ENTRY_STATEMENT, Text: "SELECT COUNT(*) FROM orders;"},
+			},
+		},
+		{
+			"postgres",
+			FORMAT_AUTO,
+			"2024-01-15 10:00:00.123 UTC [1234] app@shop LOG:  statement: SELECT name FROM users WHERE id = 1\n" +
+				"2024-01-15 10:00:01.456 UTC [1234] app@shop LOG:  duration: 0.512 ms  statement: SELECT *\n" +
+				"\tFROM orders WHERE note = 'a'' OR ''1''=''1'\n" +
+				"2024-01-15 10:00:02 UTC [1234] app@shop LOG:  execute <unnamed>: SELECT * FROM users WHERE name = $1 AND id = $2\n" +
+				"2024-01-15 10:00:02 UTC [1234] app@shop DETAIL:  parameters: $1 = 'x'' UNION SELECT usename FROM pg_user--', $2 = NULL\n" +
+				"2024-01-15 10:00:03 UTC [1234] app@shop ERROR:  syntax error at or near \"'\"\n" +
+				"2024-01-15 10:00:03 UTC [1234] app@shop STATEMENT:  SELECT * FROM users WHERE id = 1'\n",
+			[]Entry{
+				{Line: 1, Kind: ENTRY_STATEMENT, Text: "SELECT name FROM users WHERE id = 1"},
+				{Line: 2, Kind: ENTRY_STATEMENT, Text: "SELECT *\nFROM orders WHERE note = 'a'' OR ''1''=''1'"},
+				{Line: 4, Kind: ENTRY_STATEMENT, Text: "SELECT * FROM users WHERE name = $1 AND id = $2"},
+				{Line: 5, Kind: ENTRY_PARAMETER, Name: "$1", Text: "x' UNION SELECT usename FROM pg_user--"},
+				{Line: 7, Kind: ENTRY_STATEMENT, Text: "SELECT * FROM users WHERE id = 1'"},
+			},
+		},
+		{
+			"access",
+			FORMAT_AUTO,
+			`203.0.113.9 - - [15/Jan/2024:10:00:00 +0000] "GET /items?id=1 HTTP/1.1" 200 512 "-" "curl/8.0"` + "\n" +
+				`203.0.113.9 - frank [15/Jan/2024:10:00:01 +0000] "GET /items?id=1%27%20OR%20%271%27=%271 HTTP/1.1" 200 9000 "-" "sqlmap/1.7"` + "\n" +
+				`203.0.113.9 - - [15/Jan/2024:10:00:02 +0000] "GET /search?q=\x22abc HTTP/1.1" 400 0 "-" "-"` + "\n" +
+				"garbage line\n",
+			[]Entry{
+				{Line: 1, Kind: ENTRY_REQUEST, Method: "GET", Text: "/items?id=1"},
+				{Line: 2, Kind: ENTRY_REQUEST, Method: "GET", Text: "/items?id=1%27%20OR%20%271%27=%271"},
+				{Line: 3, Kind: ENTRY_REQUEST, Method: "GET", Text: `/search?q="abc`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var entries []*Entry
+		err := ParseLog(strings.NewReader(tt.input), tt.name, tt.format, func(e *Entry) error {
+			entries = append(entries, e)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(entries) != len(tt.expected) {
+			t.Errorf("%s: expected %d entries but got %d", tt.name, len(tt.expected), len(entries))
+			for _, e := range entries {
+				t.Logf("%+v", *e)
+			}
+			continue
+		}
+		for i, e := range entries {
+			want := tt.expected[i]
+			if e.Line != want.Line || e.Kind != want.Kind || e.Text != want.Text || e.Name != want.Name || e.Method != want.Method || e.Time.IsZero() {
+				t.Errorf("%s: entry %d expected %+v but got %+v", tt.name, i, want, *e)
+			}
+		}
+	}
+
+	if err := ParseLog(strings.NewReader("hello\nworld\n"), "unknown", FORMAT_AUTO, func(*Entry) error { return nil }); err == nil {
+		t.Errorf("Expected error for unrecognized format")
+	}
+}
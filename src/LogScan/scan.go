@@ -0,0 +1,196 @@
+package LogScan
+
+import (
+	"HawkEye-Go/src/Engine"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Finding 是一个语句或参数的检查结论。
+type Finding struct {
+	Parameter   string           `json:"parameter,omitempty"` // 来源:名称，语句本身的结论没有
+	Value       string           `json:"value,omitempty"`
+	Probability float64          `json:"probability"`
+	Rules       []Engine.RuleHit `json:"rules,omitempty"`
+	Reason      string           `json:"reason,omitempty"`
+}
+
+// Result 是一个日志条目的扫描结果，以NDJSON输出。
+type Result struct {
+	Timestamp string    `json:"timestamp,omitempty"` // RFC 3339，日志中没有时间时为空
+	File      string    `json:"file"`
+	Line      int       `json:"line"`
+	Format    Format    `json:"format"`
+	Text      string    `json:"text"` // 语句、参数值或 "方法 URI"
+	Score     float64   `json:"score"`
+	Block     bool      `json:"block"`
+	Findings  []Finding `json:"findings,omitempty"`
+	Error     string    `json:"error,omitempty"` // 检查这个条目失败的原因
+}
+
+// Flagged 判断结果是否值得报告：被拦截、命中了规则或者检查失败。
+func (r *Result) Flagged() bool {
+	return r.Block || len(r.Findings) > 0 || r.Error != ""
+}
+
+// Scanner 用 Workers 个goroutine并发检查日志条目。
+type Scanner struct {
+	Inspector *Engine.RequestInspector
+	Workers   int // 不大于0时使用CPU数量
+}
+
+// NewScanner 返回使用给定 Detector 的扫描器。
+func NewScanner(detector *Engine.Detector, workers int) *Scanner {
+	return &Scanner{Inspector: Engine.NewRequestInspector(detector), Workers: workers}
+}
+
+// Scan 检查entries中的每个条目并把结果发送到results，entries关闭且全部检查完后关闭results。
+// 结果的顺序与条目的顺序不一定相同。
+func (s *Scanner) Scan(entries <-chan *Entry, results chan<- *Result) {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				results <- s.Check(entry)
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+}
+
+// Check 检查一个日志条目。检查过程中的panic不会终止扫描，这个条目作为检查失败的结果返回。
+func (s *Scanner) Check(entry *Entry) (result *Result) {
+	result = &Result{File: entry.File, Line: entry.Line, Format: entry.Format, Text: entry.Text}
+	if !entry.Time.IsZero() {
+		result.Timestamp = entry.Time.Format(time.RFC3339Nano)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprintf("check entry: %v", r)
+		}
+	}()
+	switch entry.Kind {
+	case ENTRY_STATEMENT:
+		verdict := s.Inspector.Detector.Check(entry.Text)
+		result.Score, result.Block = verdict.Score, verdict.Block
+		if verdict.Block || len(verdict.Hits) > 0 {
+			result.Findings = append(result.Findings, Finding{Probability: verdict.Probability, Rules: verdict.Hits, Reason: verdict.Reason})
+		}
+	case ENTRY_PARAMETER:
+		s.addParameter(result, s.Inspector.CheckParameter(Engine.Parameter{Source: "bind", Name: entry.Name, Value: entry.Text}))
+	case ENTRY_REQUEST:
+		result.Text = entry.Method + " " + entry.Text
+		r, err := http.NewRequest(entry.Method, entry.Text, nil)
+		if err != nil {
+			// 无法解析的URI作为一个整体检查
+			s.addParameter(result, s.Inspector.CheckParameter(Engine.Parameter{Source: Engine.SOURCE_PATH, Name: "uri", Value: entry.Text}))
+			break
+		}
+		parameters, _ := s.Inspector.Extract(r)
+		for _, parameter := range parameters {
+			s.addParameter(result, s.Inspector.CheckParameter(parameter))
+		}
+	}
+	return result
+}
+
+func (s *Scanner) addParameter(result *Result, verdict *Engine.ParameterVerdict) {
+	if verdict.Score > result.Score {
+		result.Score = verdict.Score
+	}
+	result.Block = result.Block || verdict.Block
+	if verdict.Block || len(verdict.Hits) > 0 {
+		result.Findings = append(result.Findings, Finding{
+			Parameter:   verdict.Source + ":" + verdict.Name,
+			Value:       verdict.Value,
+			Probability: verdict.Probability,
+			Rules:       verdict.Hits,
+			Reason:      verdict.Reason,
+		})
+	}
+}
+
+func TestScanner(t *testing.T) {
+	logs := map[string]string{
+		"general.log": "2024-01-15T10:00:01.000000Z\t    8 Query\tSELECT name FROM users WHERE id = 1\n" +
+			"2024-01-15T10:00:02.000000Z\t    8 Query\tSELECT name FROM users WHERE id = 1 OR 1=1\n",
+		"access.log": `203.0.113.9 - - [15/Jan/2024:10:00:00 +0000] "GET /items?id=7 HTTP/1.1" 200 512 "-" "-"` + "\n" +
+			`203.0.113.9 - - [15/Jan/2024:10:00:01 +0000] "GET /items?id=7%20AND%20SLEEP(5)--%20- HTTP/1.1" 200 512 "-" "-"` + "\n",
+		"postgres.log": "2024-01-15 10:00:02 UTC [1234] app@shop DETAIL:  parameters: $1 = 'x'' UNION SELECT table_name FROM information_schema.tables--'\n",
+	}
+
+	entries := make(chan *Entry)
+	results := make(chan *Result)
+	go func() {
+		for name, log := range logs {
+			if err := ParseLog(strings.NewReader(log), name, FORMAT_AUTO, func(e *Entry) error {
+				entries <- e
+				return nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}
+		close(entries)
+	}()
+	detector := &Engine.Detector{Rules: Engine.DefaultRuleSet(), Policy: Engine.DefaultPolicy}
+	go NewScanner(detector, 3).Scan(entries, results)
+
+	flagged := make(map[string]*Result)
+	total := 0
+	for result := range results {
+		total++
+		if result.Flagged() {
+			flagged[result.File+":"+result.Text] = result
+		}
+	}
+	if total != 5 {
+		t.Errorf("Expected 5 results, got %d", total)
+	}
+	expected := map[string]string{
+		"general.log:SELECT name FROM users WHERE id = 1 OR 1=1":                   "",
+		"access.log:GET /items?id=7%20AND%20SLEEP(5)--%20-":                        "query:id",
+		"postgres.log:x' UNION SELECT table_name FROM information_schema.tables--": "bind:$1",
+	}
+	for key, parameter := range expected {
+		result := flagged[key]
+		if result == nil || !result.Block || result.Findings[0].Parameter != parameter || result.Timestamp == "" {
+			t.Errorf("Expected %s to be blocked with finding for %q, got %+v", key, parameter, result)
+		}
+	}
+	if len(flagged) != len(expected) {
+		t.Errorf("Expected %d flagged results, got %d", len(expected), len(flagged))
+	}
+
+	// 检查条目时的panic只影响这个条目
+	panicking := NewScanner(&Engine.Detector{Rules: panicRules{}, Policy: Engine.DefaultPolicy}, 1)
+	if result := panicking.Check(&Entry{Kind: ENTRY_REQUEST, Method: "GET", Text: "/item?id=UPDATE-%20"}); result.Error == "" || !result.Flagged() {
+		t.Errorf("Expected an error result, got %+v", result)
+	}
+
+	// 输出为每行一个JSON对象
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(flagged["access.log:GET /items?id=7%20AND%20SLEEP(5)--%20-"])
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded["format"] != "access" || decoded["line"] != 2.0 {
+		t.Errorf("Unexpected JSON %s", buf.String())
+	}
+}
+
+// panicRules 是检查时总是panic的规则，用于测试扫描器的恢复。
+type panicRules struct{}
+
+func (panicRules) Evaluate(string) []Engine.RuleHit { panic("evaluate") }
@@ -1,13 +1,17 @@
 package PythonSqlPaser
 
 import (
+	"HawkEye-Go/src/SqlPaser"
 	"fmt"
 	"strings"
 )
 
+// cleanAndSplitTokens 去掉注释并按分号把令牌切分为多条语句。
+// 与 SqlPaser.SplitStatements 一样，BEGIN ... END 块中的分号不会切分语句。
 func cleanAndSplitTokens(tokens []ParsedToken) ([][]ParsedToken, error) {
 	var statements [][]ParsedToken
 	var currentStatement []ParsedToken
+	var blocks SqlPaser.BlockTracker
 	isInSQLStatement := false
 
 	for _, token := range tokens {
@@ -23,6 +27,14 @@ func cleanAndSplitTokens(tokens []ParsedToken) ([][]ParsedToken, error) {
 
 		// 如果当前处于SQL语句中，则处理当前Token
 		if isInSQLStatement {
+			switch {
+			case token.Type.String() == Keyword.String() || token.Type.String() == Name.String() ||
+				token.Type.String() == DML.String() || token.Type.String() == DDL.String():
+				blocks.Word(token.Value)
+			case token.Value != ";" && strings.TrimSpace(token.Value) != "":
+				blocks.Symbol(token.Value)
+			}
+
 			// 合并连续的文本Token，例如Identifier和String
 			if len(currentStatement) > 0 &&
 				(token.Type.String() == Name.String() || token.Type.String() == String.String() ||
@@ -37,7 +49,7 @@ func cleanAndSplitTokens(tokens []ParsedToken) ([][]ParsedToken, error) {
 			}
 
 			// 如果遇到语句结束标记，则将currentStatement添加到statements，并重置currentStatement
-			if token.Type.String() == Punctuation.String() && token.Value == ";" {
+			if token.Type.String() == Punctuation.String() && token.Value == ";" && blocks.Semicolon() {
 				statements = append(statements, currentStatement)
 				currentStatement = []ParsedToken{}
 				isInSQLStatement = false
@@ -35,7 +35,7 @@ var SQL_REGEX = []RegexRule{
 	{`(?<=\.)[A-ZÀ-Ü]\w*`, Name},
 	{`[A-ZÀ-Ü]\w*(?=\()`, Name},
 	{`-?0x[\dA-F]+`, Hexadecimal},
-	{`-?\d+(\.\d+)?E-?\d+`, Float},
+	{`-?\d+(\.\d+)?[Ee]-?\d+`, Float},
 	{`(?![_A-ZÀ-Ü])-?(\d+(\.\d*)|\.\d+)(?![_A-ZÀ-Ü])`, Float},
 	{`(?![_A-ZÀ-Ü])-?\d+(?![_A-ZÀ-Ü])`, Integer},
 	{`'(''|\\'|[^'])*'`, Single},
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/dlclark/regexp2"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -43,20 +44,49 @@ func IsKeyword(value string) TokenType {
 	return *Name
 }
 
-func GetTokens(text string) ([]ParsedToken, error) {
-	var tokens []ParsedToken
-	pos := 0
+// compiledRule 是编译后的 SQL_REGEX 规则。
+type compiledRule struct {
+	regex *regexp2.Regexp
+	Token *TokenType
+}
 
-	for pos < len(text) {
-		matched := false
+var (
+	compileOnce  sync.Once
+	compiled     []compiledRule
+	compileError error
+)
 
+// compiledRules 只编译一次 SQL_REGEX。每个表达式前加上 \G，只在当前位置尝试匹配，
+// 否则匹配失败时会一直搜索到输入的结尾。
+func compiledRules() ([]compiledRule, error) {
+	compileOnce.Do(func() {
 		for _, rule := range SQL_REGEX {
-			rexMatch, err := regexp2.Compile(rule.Regex, regexp2.None)
+			regex, err := regexp2.Compile(`\G(?:`+rule.Regex+`)`, regexp2.None)
 			if err != nil {
-				return nil, fmt.Errorf("failed to compile regex: %v", err)
+				compileError = fmt.Errorf("failed to compile regex: %v", err)
+				return
 			}
+			compiled = append(compiled, compiledRule{regex: regex, Token: rule.Token})
+		}
+	})
+	return compiled, compileError
+}
+
+func GetTokens(text string) ([]ParsedToken, error) {
+	rules, err := compiledRules()
+	if err != nil {
+		return nil, err
+	}
+	var tokens []ParsedToken
+	// regexp2 返回的位置按字符（rune）计算，不是字节
+	runes := []rune(text)
+	pos := 0
+
+	for pos < len(runes) {
+		matched := false
 
-			matches, err := rexMatch.FindStringMatchStartingAt(text, pos)
+		for _, rule := range rules {
+			matches, err := rule.regex.FindRunesMatchStartingAt(runes, pos)
 			if err != nil {
 				return nil, err
 			}
@@ -69,7 +99,7 @@ func GetTokens(text string) ([]ParsedToken, error) {
 					tokens = append(tokens, ParsedToken{Type: *rule.Token, Value: matches.String()})
 				}
 
-				pos = matches.Index + len(matches.String())
+				pos = matches.Index + matches.Length
 				matched = true
 				break
 			}
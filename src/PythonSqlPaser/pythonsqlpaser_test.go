@@ -0,0 +1,8 @@
+package PythonSqlPaser
+
+import "testing"
+
+// TestPythonSqlPaser 运行与被测代码写在同一文件中的测试函数。
+func TestPythonSqlPaser(t *testing.T) {
+	t.Run("SQLProcessing", TestSQLProcessing)
+}
@@ -0,0 +1,362 @@
+package SqlGuard
+
+import (
+	"HawkEye-Go/src/Engine"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// Guard 在语句发往数据库之前比较它的查询结构和基线，结构发生变化的语句会被拒绝。
+// 应用自己的查询只有参数会变化，结构不在基线中通常意味着注入已经改变了语句。
+// 基线处于学习模式时语句会被加入基线，报告模式时只调用 OnReport。
+type Guard struct {
+	Baseline *Engine.Baseline
+	OnReject func(query string, err error)         // 拒绝语句时调用，可以用来记录日志
+	OnReport func(finding *Engine.BaselineFinding) // 报告模式下发现异常结构时调用
+}
+
+// RejectedError 表示语句因为结构不在基线中被拒绝。
+type RejectedError struct {
+	Query    string
+	Template string
+	Reason   string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("sqlguard: rejected %q: %s", e.Query, e.Reason)
+}
+
+// Check 判断语句是否可以执行，不可以时返回 *RejectedError。
+func (g *Guard) Check(query string) error {
+	if g.Baseline == nil {
+		return g.reject(&RejectedError{Query: query, Reason: "no baseline"})
+	}
+	finding := g.Baseline.Observe(query)
+	if finding.Block {
+		return g.reject(&RejectedError{Query: query, Template: finding.Template, Reason: finding.Reason})
+	}
+	if !finding.Known && g.OnReport != nil {
+		g.OnReport(finding)
+	}
+	return nil
+}
+
+func (g *Guard) reject(err *RejectedError) error {
+	if g.OnReject != nil {
+		g.OnReject(err.Query, err)
+	}
+	return err
+}
+
+// Wrap 返回检查语句结构的驱动，可以用 sql.Register 注册：
+//
+//	sql.Register("mysql-guarded", SqlGuard.Wrap(&mysql.MySQLDriver{}, guard))
+func Wrap(d driver.Driver, g *Guard) driver.Driver {
+	return &guardedDriver{Driver: d, guard: g}
+}
+
+// WrapConnector 返回检查语句结构的 driver.Connector，用于 sql.OpenDB。
+func WrapConnector(c driver.Connector, g *Guard) driver.Connector {
+	return &connector{Connector: c, guard: g}
+}
+
+// OpenDB 使用包装后的驱动打开数据库，不需要注册驱动名。
+func OpenDB(d driver.Driver, dsn string, g *Guard) (*sql.DB, error) {
+	var c driver.Connector = dsnConnector{dsn: dsn, driver: d}
+	if dc, ok := d.(driver.DriverContext); ok {
+		var err error
+		if c, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(WrapConnector(c, g)), nil
+}
+
+type guardedDriver struct {
+	driver.Driver
+	guard *Guard
+}
+
+func (d *guardedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, guard: d.guard}, nil
+}
+
+type connector struct {
+	driver.Connector
+	guard *Guard
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	inner, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: inner, guard: c.guard}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return Wrap(c.Connector.Driver(), c.guard)
+}
+
+// dsnConnector 用于没有实现 driver.DriverContext 的驱动。
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+// conn 在 Prepare、Query 和 Exec 之前检查语句，其余调用原样交给底层连接。
+// 底层连接没有实现的可选接口返回 driver.ErrSkip，由 database/sql 回退到 Prepare。
+type conn struct {
+	driver.Conn
+	guard *Guard
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	if err := c.guard.Check(query); err != nil {
+		return nil, err
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.guard.Check(query); err != nil {
+		return nil, err
+	}
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return pc.PrepareContext(ctx, query)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.guard.Check(query); err != nil {
+		return nil, err
+	}
+	if qc, ok := c.Conn.(driver.QueryerContext); ok {
+		return qc.QueryContext(ctx, query, args)
+	}
+	if q, ok := c.Conn.(driver.Queryer); ok {
+		values, err := namedValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return q.Query(query, values)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.guard.Check(query); err != nil {
+		return nil, err
+	}
+	if ec, ok := c.Conn.(driver.ExecerContext); ok {
+		return ec.ExecContext(ctx, query, args)
+	}
+	if e, ok := c.Conn.(driver.Execer); ok {
+		values, err := namedValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return e.Exec(query, values)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errors.New("sqlguard: driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("sqlguard: driver does not support read-only transactions")
+	}
+	return c.Conn.Begin() // 底层驱动只实现了旧接口
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// namedValues 把参数转换为旧接口使用的 []driver.Value，旧接口不支持命名参数。
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sqlguard: driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// fakeDriver 是测试用的驱动，记录收到的语句。
+type fakeDriver struct{ queries []string }
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{driver: d}, nil }
+
+// fakeConn 只实现 ExecerContext，查询走 Prepare 路径。
+type fakeConn struct{ driver *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.driver.queries = append(c.driver.queries, query)
+	return driver.RowsAffected(1), nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.conn.driver.queries = append(s.conn.driver.queries, s.query)
+	return driver.RowsAffected(1), nil
+}
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.conn.driver.queries = append(s.conn.driver.queries, s.query)
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"name"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = "alice"
+	return nil
+}
+
+func TestGuardedDriver(t *testing.T) {
+	baseline := Engine.NewBaseline(Engine.BASELINE_LEARN)
+	for _, query := range []string{
+		"SELECT name FROM users WHERE id = ?",
+		"UPDATE users SET name = ? WHERE id = ?",
+	} {
+		baseline.Learn(query)
+	}
+	baseline.SetMode(Engine.BASELINE_ENFORCE)
+	rejected := 0
+	guard := &Guard{Baseline: baseline, OnReject: func(string, error) { rejected++ }}
+	fake := &fakeDriver{}
+	db, err := OpenDB(fake, "", guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 模板相同的语句可以执行，不论参数是绑定的还是拼接的
+	for _, query := range []string{"SELECT name FROM users WHERE id = ?", "SELECT name FROM users WHERE id = 42"} {
+		var name string
+		if err := db.QueryRow(query, 1).Scan(&name); err != nil || name != "alice" {
+			t.Errorf("Query %q: got %q, %v", query, name, err)
+		}
+	}
+	if _, err := db.Exec("UPDATE users SET name = 'bob' WHERE id = 7"); err != nil {
+		t.Errorf("Exec: %v", err)
+	}
+
+	injected := []string{
+		"SELECT name FROM users WHERE id = 1 OR 1 = 1",
+		"UPDATE users SET name = 'x' WHERE id = 1; DROP TABLE users",
+		"SELECT name FROM users WHERE id = 1 UNION SELECT password FROM admins",
+		"SELECT name FROM users WHERE id = 1|0 UNION SELECT password FROM mysql.user",
+	}
+	for _, query := range injected {
+		var target *RejectedError
+		if _, err := db.Query(query); !errors.As(err, &target) {
+			t.Errorf("Query %q: expected RejectedError, got %v", query, err)
+		}
+		if _, err := db.Exec(query); !errors.As(err, &target) {
+			t.Errorf("Exec %q: expected RejectedError, got %v", query, err)
+		}
+		if _, err := db.Prepare(query); !errors.As(err, &target) {
+			t.Errorf("Prepare %q: expected RejectedError, got %v", query, err)
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(injected[0]); err == nil {
+		t.Errorf("Expected statement in transaction to be rejected")
+	}
+	tx.Rollback()
+
+	if rejected != len(injected)*3+1 {
+		t.Errorf("Expected %d rejections, got %d", len(injected)*3+1, rejected)
+	}
+	for _, query := range fake.queries {
+		for _, bad := range injected {
+			if query == bad {
+				t.Errorf("Rejected statement %q reached the driver", query)
+			}
+		}
+	}
+	if len(fake.queries) != 3 {
+		t.Errorf("Expected 3 statements to reach the driver, got %v", fake.queries)
+	}
+
+	// 报告模式下语句照常执行
+	reported := 0
+	guard.OnReport = func(*Engine.BaselineFinding) { reported++ }
+	baseline.SetMode(Engine.BASELINE_REPORT)
+	if _, err := db.Exec(injected[1]); err != nil || reported != 1 {
+		t.Errorf("Expected report mode to execute and report, got %v with %d reports", err, reported)
+	}
+}
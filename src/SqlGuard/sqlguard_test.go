@@ -0,0 +1,8 @@
+package SqlGuard
+
+import "testing"
+
+// TestSqlGuard 运行与被测代码写在同一文件中的测试函数。
+func TestSqlGuard(t *testing.T) {
+	t.Run("GuardedDriver", TestGuardedDriver)
+}
@@ -3,29 +3,63 @@ package SqlPaser
 import (
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
-	input  string  // 输入字符串
-	pos    int     // 当前位置
-	tokens []Token // 已解析的令牌列表
+	input          string  // 输入字符串
+	pos            int     // 当前位置
+	tokens         []Token // 已解析的令牌列表
+	versionComment bool    // 是否处于MySQL的 /*! ... */ 可执行注释中
+	start          int     // 当前令牌的起始位置
 }
 
 func NewLexer(input string) *Lexer {
 	return &Lexer{input: input}
 }
 
+// NextToken 返回下一个令牌，并记录它在输入中的字节范围。
 func (l *Lexer) NextToken() Token {
+	token := l.nextToken()
+	token.Pos, token.End = l.start, l.pos
+	return token
+}
+
+func (l *Lexer) nextToken() Token {
 	l.skipWhitespace() // 跳过空白
+	l.start = l.pos
 
 	if l.pos >= len(l.input) {
-		return Token{EOF, ""}
+		return Token{Type: EOF}
 	}
 
 	switch ch := l.input[l.pos]; {
-	case unicode.IsLetter(rune(ch)):
+	case ch == '-' && l.peek(1) == '-', ch == '#' && isHashComment(l.input, l.pos):
+		return l.lexLineComment()
+	case ch == '/' && l.peek(1) == '*' && l.peek(2) == '!':
+		// MySQL 会执行 /*!50000 ... */ 中的内容，因此只跳过注释标记，继续解析其中的令牌
+		l.pos += 3
+		for l.pos < len(l.input) && unicode.IsDigit(rune(l.input[l.pos])) {
+			l.pos++
+		}
+		l.versionComment = true
+		return l.nextToken()
+	case ch == '*' && l.peek(1) == '/' && l.versionComment:
+		l.pos += 2
+		l.versionComment = false
+		return l.nextToken()
+	case ch == '/' && l.peek(1) == '*':
+		return l.lexBlockComment()
+	case ch == '`': // MySQL 的反引号标识符
+		return Token{Type: IDENTIFIER, Value: l.lexIdentifierParts(l.lexQuoted('`'))}
+	case ch == '"':
+		return Token{Type: STRING, Value: l.lexQuoted('"')}
+	case ch == '$' && dollarQuoteTag(l.input, l.pos) != "":
+		return l.lexDollarQuoted()
+	case unicode.IsLetter(rune(ch)) || ch == '_':
 		token := l.lexKeywordOrIdentifier()
-		if l.input[l.pos] == '(' {
+		// 紧跟左括号的标识符视为函数名；IN(、EXISTS( 之类的关键字保持原样
+		if l.pos < len(l.input) && l.input[l.pos] == '(' && (token.Type == IDENTIFIER || functionKeywords[token.Type]) {
 			token.Type = FUNCTION
 		}
 		return token
@@ -33,49 +67,105 @@ func (l *Lexer) NextToken() Token {
 		return l.lexNumber()
 	case ch == ',':
 		l.pos++
-		return Token{COMMA, ","}
+		return Token{Type: COMMA, Value: ","}
 	case ch == ';':
 		l.pos++
-		return Token{SEMICOLON, ";"}
+		return Token{Type: SEMICOLON, Value: ";"}
+	case ch == '?':
+		l.pos++
+		return Token{Type: PLACEHOLDER, Value: "?"}
+	case ch == '$' && unicode.IsDigit(rune(l.peek(1))): // PostgreSQL 的 $1 参数
+		start := l.pos
+		l.pos++
+		l.skipDigits()
+		return Token{Type: PLACEHOLDER, Value: l.input[start:l.pos]}
+	case ch == '@' && (isIdentifierChar(l.peek(1)) || l.peek(1) == '@' && isIdentifierChar(l.peek(2))):
+		// 用户变量 @name 和系统变量 @@version、@@global.port
+		start := l.pos
+		l.pos++
+		if l.peek(0) == '@' {
+			l.pos++
+		}
+		for l.pos < len(l.input) && (isIdentifierChar(l.input[l.pos]) || l.input[l.pos] == '.') {
+			l.pos++
+		}
+		return Token{Type: IDENTIFIER, Value: l.input[start:l.pos]}
 	case ch == '(':
 		l.pos++
-		return Token{LEFT_PAREN, "("}
+		return Token{Type: LEFT_PAREN, Value: "("}
 	case ch == ')':
 		l.pos++
-		return Token{RIGHT_PAREN, ")"}
+		return Token{Type: RIGHT_PAREN, Value: ")"}
 	case ch == '=': // 处理字符串值
 		l.pos++
-		return Token{EQUALS, "="}
+		return Token{Type: EQUALS, Value: "="}
+	case ch == '+':
+		l.pos++
+		return Token{Type: PLUS, Value: "+"}
+	case ch == '-':
+		l.pos++
+		return Token{Type: MINUS, Value: "-"}
+	case ch == '*':
+		l.pos++
+		return Token{Type: MULTIPLY, Value: "*"}
+	case ch == '/':
+		l.pos++
+		return Token{Type: DIVIDE, Value: "/"}
 	case ch == '<':
 		l.pos++
-		if l.input[l.pos] == '=' {
+		if l.peek(0) == '=' {
 			l.pos++
-			return Token{LESS_EQUALS, "<="}
-		} else if l.input[l.pos] == '>' {
+			return Token{Type: LESS_EQUALS, Value: "<="}
+		} else if l.peek(0) == '>' {
 			l.pos++
-			return Token{NOT_EQUALS, "<>"}
+			return Token{Type: NOT_EQUALS, Value: "<>"}
 		}
-		return Token{LESS_THAN, "<"}
+		return Token{Type: LESS_THAN, Value: "<"}
 	case ch == '>':
 		l.pos++
-		if l.input[l.pos] == '=' {
+		if l.peek(0) == '=' {
 			l.pos++
-			return Token{GREATER_EQUALS, ">="}
+			return Token{Type: GREATER_EQUALS, Value: ">="}
 		}
-		return Token{GREATER_THAN, ">"}
+		return Token{Type: GREATER_THAN, Value: ">"}
 	case ch == '!':
 		l.pos++
-		if l.input[l.pos] == '=' {
+		if l.peek(0) == '=' {
 			l.pos++
-			return Token{NOT_EQUALS, "!="}
+			return Token{Type: NOT_EQUALS, Value: "!="}
 		}
+		l.pos--
+		return l.lexIllegal()
 	case ch == '\'': // 处理字符串值
 		return l.lexString()
 	default:
-		l.pos++
-		return Token{EOF, string(ch)}
+		return l.lexIllegal()
 	}
-	return Token{EOF, ""}
+}
+
+// operatorChars 是词法分析器不认识的运算符字符，连续出现时合并为一个令牌，如 || 和 &&。
+const operatorChars = "|&^~%!:"
+
+// lexIllegal 把不认识的字符解析为 ILLEGAL 令牌。令牌流不能在这里结束，
+// 否则 `1|0 UNION SELECT ...` 之类的输入在 | 之后的部分会被忽略。
+func (l *Lexer) lexIllegal() Token {
+	start := l.pos
+	_, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.pos += size
+	if strings.IndexByte(operatorChars, l.input[start]) >= 0 {
+		for l.pos < len(l.input) && strings.IndexByte(operatorChars, l.input[l.pos]) >= 0 {
+			l.pos++
+		}
+	}
+	return Token{Type: ILLEGAL, Value: l.input[start:l.pos]}
+}
+
+// functionKeywords 是同时也可以作为函数名使用的关键字，如 LEFT('abc', 1)。
+var functionKeywords = map[TokenType]bool{
+	LEFT:     true,
+	RIGHT:    true,
+	REPLACE:  true,
+	DATABASE: true, // MySQL 的 DATABASE()
 }
 
 // 解析关键字或标识符
@@ -88,31 +178,175 @@ func (l *Lexer) lexKeywordOrIdentifier() Token {
 
 	// 检查单词关键字
 	if keyword, ok := keywords[word]; ok {
-		return Token{keyword, word}
+		return Token{Type: keyword, Value: word}
 	}
-	return Token{IDENTIFIER, l.input[start:l.pos]}
+	if strings.HasSuffix(word, ".") && l.peek(0) == '`' { // 如 u.`select`
+		return Token{Type: IDENTIFIER, Value: l.lexIdentifierParts(l.input[start:l.pos] + l.lexQuoted('`'))}
+	}
+	return Token{Type: IDENTIFIER, Value: l.input[start:l.pos]}
+}
+
+// lexIdentifierParts 在反引号标识符之后继续解析以点号连接的部分，如 `db`.`users`。
+func (l *Lexer) lexIdentifierParts(value string) string {
+	for l.peek(0) == '.' {
+		l.pos++
+		if l.peek(0) == '`' {
+			value += "." + l.lexQuoted('`')
+			continue
+		}
+		start := l.pos
+		for l.pos < len(l.input) && isIdentifierChar(l.input[l.pos]) {
+			l.pos++
+		}
+		value += "." + l.input[start:l.pos]
+	}
+	return value
 }
 
 func (l *Lexer) lexString() Token {
-	pos := l.pos + 1 // 跳过开头的单引号
-	for {
-		if pos >= len(l.input) || l.input[pos] == '\'' {
+	return Token{Type: STRING, Value: l.lexQuoted('\'')}
+}
+
+// lexQuoted 解析由quote包围的字符串或标识符，返回去掉引号并处理转义后的值。
+// 支持连续两个引号表示引号本身，以及MySQL的反斜杠转义。
+func (l *Lexer) lexQuoted(quote byte) string {
+	var value strings.Builder
+	pos := l.pos + 1 // 跳过开头的引号
+	for pos < len(l.input) {
+		ch := l.input[pos]
+		if ch == '\\' && quote != '`' && pos+1 < len(l.input) {
+			value.WriteByte(l.input[pos+1])
+			pos += 2
+			continue
+		}
+		if ch == quote {
+			if pos+1 < len(l.input) && l.input[pos+1] == quote {
+				value.WriteByte(quote)
+				pos += 2
+				continue
+			}
 			break
 		}
+		value.WriteByte(ch)
 		pos++
 	}
-	val := l.input[l.pos+1 : pos] // 获取字符串值，不包括单引号
-	l.pos = pos + 1               // 更新位置
-	return Token{STRING, val}
+	l.pos = pos + 1 // 更新位置
+	if l.pos > len(l.input) {
+		l.pos = len(l.input)
+	}
+	return value.String()
 }
 
-// 解析数字
+// lexDollarQuoted 解析PostgreSQL的美元符号引用字符串，如 $$ ... $$ 或 $body$ ... $body$。
+func (l *Lexer) lexDollarQuoted() Token {
+	tag := dollarQuoteTag(l.input, l.pos)
+	start := l.pos + len(tag)
+	end := strings.Index(l.input[start:], tag)
+	if end < 0 {
+		l.pos = len(l.input)
+		return Token{Type: STRING, Value: l.input[start:]}
+	}
+	l.pos = start + end + len(tag)
+	return Token{Type: STRING, Value: l.input[start : start+end]}
+}
+
+// lexLineComment 解析 -- 或 # 开头的单行注释。
+func (l *Lexer) lexLineComment() Token {
+	start := l.pos
+	for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+		l.pos++
+	}
+	return Token{Type: COMMENT, Value: l.input[start:l.pos]}
+}
+
+// lexBlockComment 解析 /* ... */ 注释，未闭合时一直到输入结尾。
+func (l *Lexer) lexBlockComment() Token {
+	start := l.pos
+	end := strings.Index(l.input[start+2:], "*/")
+	if end < 0 {
+		l.pos = len(l.input)
+	} else {
+		l.pos = start + 2 + end + 2
+	}
+	return Token{Type: COMMENT, Value: l.input[start:l.pos]}
+}
+
+// isHashComment 判断pos处的 # 是否开始一个MySQL单行注释。
+// 紧跟字母或数字时视为SQL Server临时表名之类的标识符，如 #tmp。
+func isHashComment(input string, pos int) bool {
+	if pos+1 >= len(input) {
+		return true
+	}
+	next := rune(input[pos+1])
+	return !(unicode.IsLetter(next) || unicode.IsDigit(next) || next == '_' || next == '#')
+}
+
+// dollarQuoteTag 返回pos处的美元符号引用标记（如 $$ 或 $body$），不是引用开头时返回空字符串。
+func dollarQuoteTag(input string, pos int) string {
+	if pos > 0 && isIdentifierChar(input[pos-1]) {
+		return ""
+	}
+	end := pos + 1
+	if end < len(input) && unicode.IsDigit(rune(input[end])) { // $1 是参数占位符
+		return ""
+	}
+	for end < len(input) && isIdentifierChar(input[end]) {
+		end++
+	}
+	if end < len(input) && input[end] == '$' {
+		return input[pos : end+1]
+	}
+	return ""
+}
+
+// isIdentifierChar 判断字符是否可以出现在标识符中。
+func isIdentifierChar(ch byte) bool {
+	return unicode.IsLetter(rune(ch)) || unicode.IsDigit(rune(ch)) || ch == '_'
+}
+
+// Tokenize 把输入解析为令牌列表，丢弃注释，不包含结尾的EOF。
+// 不认识的字符作为 ILLEGAL 令牌保留，之后的输入继续解析。
+func Tokenize(input string) []Token {
+	lexer := NewLexer(input)
+	tokens := []Token{}
+	for {
+		token := lexer.NextToken()
+		if token.Type == EOF {
+			break
+		}
+		if token.Type == COMMENT {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// 解析数字，支持小数和指数，如 0.5、1e7
 func (l *Lexer) lexNumber() Token {
 	start := l.pos
+	l.skipDigits()
+	if l.peek(0) == '.' && unicode.IsDigit(rune(l.peek(1))) {
+		l.pos++
+		l.skipDigits()
+	}
+	if l.peek(0) == 'e' || l.peek(0) == 'E' {
+		exponent := 1
+		if l.peek(1) == '+' || l.peek(1) == '-' {
+			exponent = 2
+		}
+		if unicode.IsDigit(rune(l.peek(exponent))) {
+			l.pos += exponent
+			l.skipDigits()
+		}
+	}
+	return Token{Type: NUMBER, Value: l.input[start:l.pos]}
+}
+
+func (l *Lexer) skipDigits() {
 	for l.pos < len(l.input) && unicode.IsDigit(rune(l.input[l.pos])) {
 		l.pos++
 	}
-	return Token{NUMBER, l.input[start:l.pos]}
 }
 
 // 跳过空白
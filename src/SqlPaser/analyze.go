@@ -0,0 +1,513 @@
+package SqlPaser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Schema 描述已知的表及其列，用于检查列引用是否存在。键为表名，可以带库名，如 "shop.users"。
+// 表名和列名都不区分大小写。没有提供的表视为结构未知，不会对它报告未知列。
+type Schema map[string][]string
+
+// columns 返回表的列，表名可以带或不带库名。
+func (s Schema) columns(table string) ([]string, bool) {
+	for name, columns := range s {
+		name = strings.ToLower(name)
+		// 只有一方带库名时按表名匹配
+		if name == table || (lastPart(name) == lastPart(table) && (!strings.Contains(name, ".") || !strings.Contains(table, "."))) {
+			return columns, true
+		}
+	}
+	return nil, false
+}
+
+// ColumnRef 是一个已解析的列引用。
+type ColumnRef struct {
+	Node   ASTNode // 引用所在的节点，通常是 *Identifier
+	Table  string  // 列所属的表，派生表和CTE为其名称，无法确定时为空
+	Column string
+}
+
+// Analysis 是一条语句的语义分析结果，表名和列名均为小写。
+type Analysis struct {
+	Reads         []string     // 读取的表
+	Writes        []string     // 写入、修改或删除的表
+	Columns       []*ColumnRef // 列引用，别名已解析为表名
+	SystemObjects []string     // 访问的系统库或系统表，如 information_schema.tables
+	Problems      []string     // 歧义或未知的表、列引用
+}
+
+// systemSchemas 是各数据库的系统库，访问其中的表通常意味着在枚举数据库结构或账号。
+var systemSchemas = map[string]bool{
+	"information_schema": true,
+	"mysql":              true,
+	"performance_schema": true,
+	"sys":                true,
+	"pg_catalog":         true,
+	"master":             true,
+	"msdb":               true,
+}
+
+// systemTables 是不带库名也可以访问的系统表。
+var systemTables = map[string]bool{
+	"pg_shadow":     true,
+	"pg_user":       true,
+	"pg_authid":     true,
+	"pg_roles":      true,
+	"pg_database":   true,
+	"pg_tables":     true,
+	"sysobjects":    true,
+	"syscolumns":    true,
+	"sysdatabases":  true,
+	"sysusers":      true,
+	"sqlite_master": true,
+}
+
+// isSystemObject 判断表是否属于系统库或者是系统表。
+func isSystemObject(table string) bool {
+	if i := strings.Index(table, "."); i >= 0 && systemSchemas[table[:i]] {
+		return true
+	}
+	return systemTables[lastPart(table)]
+}
+
+// lastPart 返回点分名称的最后一部分，如 shop.users 为 users。
+func lastPart(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// source 是FROM子句中可以被列引用的一个表、派生表或CTE。
+type source struct {
+	name    string   // 别名，没有别名时为表名
+	table   string   // 实际的表名，派生表和CTE为其名称
+	columns []string // 已知的列，nil表示未知
+}
+
+// scope 是一层SELECT中可见的表，子查询可以引用外层的表。
+type scope struct {
+	parent  *scope
+	sources []*source
+	aliases map[string]bool // 选择列表中的别名，ORDER BY/HAVING 中可以引用
+	ctes    map[string]*source
+}
+
+func (s *scope) cte(name string) *source {
+	for ; s != nil; s = s.parent {
+		if cte, ok := s.ctes[name]; ok {
+			return cte
+		}
+	}
+	return nil
+}
+
+type analyzer struct {
+	schema   Schema
+	analysis *Analysis
+	seen     map[string]bool
+}
+
+// Analyze 分析一条语句访问的表和列。schema 可以为nil，此时只检查表和别名，不检查列。
+// 对于 *Script，结果合并所有语句；需要逐条语句的结果时使用 AnalyzeScript。
+func Analyze(node ASTNode, schema Schema) *Analysis {
+	a := &analyzer{schema: schema, analysis: &Analysis{}, seen: make(map[string]bool)}
+	a.statement(node)
+	return a.analysis
+}
+
+// AnalyzeScript 逐条分析脚本中的语句，无法解析的语句结果为空。
+func AnalyzeScript(script *Script, schema Schema) []*Analysis {
+	var results []*Analysis
+	for _, stmt := range script.Statements {
+		results = append(results, Analyze(stmt.Node, schema))
+	}
+	return results
+}
+
+func (a *analyzer) problemf(format string, args ...interface{}) {
+	a.analysis.Problems = append(a.analysis.Problems, fmt.Sprintf(format, args...))
+}
+
+// addTable 记录一次表访问，write 为true时记为写入。
+func (a *analyzer) addTable(table string, write bool) {
+	key := fmt.Sprintf("%t:%s", write, table)
+	if table == "" || a.seen[key] {
+		return
+	}
+	a.seen[key] = true
+	if write {
+		a.analysis.Writes = append(a.analysis.Writes, table)
+	} else {
+		a.analysis.Reads = append(a.analysis.Reads, table)
+	}
+	if isSystemObject(table) && !a.seen["system:"+table] {
+		a.seen["system:"+table] = true
+		a.analysis.SystemObjects = append(a.analysis.SystemObjects, table)
+	}
+}
+
+// tableSource 为表名创建一个source并记录读取，表名与CTE同名时引用的是CTE。
+func (a *analyzer) tableSource(name, alias string, parent *scope) *source {
+	table := strings.ToLower(name)
+	src := &source{name: strings.ToLower(alias), table: table}
+	if src.name == "" {
+		src.name = lastPart(table)
+	}
+	if cte := parent.cte(table); cte != nil {
+		src.columns = cte.columns
+		return src
+	}
+	a.addTable(table, false)
+	if columns, ok := a.schema.columns(table); ok {
+		src.columns = lowerAll(columns)
+	}
+	return src
+}
+
+func (a *analyzer) statement(node ASTNode) {
+	switch n := node.(type) {
+	case *Script:
+		for _, stmt := range n.Statements {
+			a.statement(stmt.Node)
+		}
+	case *ScriptStatement:
+		a.statement(n.Node)
+	case *SelectStatement:
+		a.selectStatement(n, nil)
+	case *InsertStatement:
+		table := strings.ToLower(n.TableName)
+		a.addTable(table, true)
+		target := &source{name: lastPart(table), table: table}
+		if columns, ok := a.schema.columns(table); ok {
+			target.columns = lowerAll(columns)
+		}
+		for _, column := range n.Columns {
+			a.resolve(&Identifier{Name: column}, &scope{sources: []*source{target}})
+		}
+		values := &scope{}
+		for _, row := range n.Values {
+			for _, value := range row {
+				a.expression(value, values)
+			}
+		}
+		if n.SelectStatement != nil {
+			a.selectStatement(n.SelectStatement, nil)
+		}
+	case *UpdateStatement:
+		table := strings.ToLower(n.TableName)
+		a.addTable(table, true)
+		s := &scope{sources: []*source{a.tableSource(table, "", nil)}}
+		for _, update := range n.Updates {
+			a.resolve(&Identifier{Name: update.Column}, s)
+			a.expression(update.Value, s)
+		}
+		if n.Where != nil {
+			a.expression(n.Where.Condition, s)
+		}
+	case *DeleteStatement:
+		table := strings.ToLower(n.TableName)
+		a.addTable(table, true)
+		s := &scope{sources: []*source{a.tableSource(table, "", nil)}}
+		if n.Where != nil {
+			a.expression(n.Where.Condition, s)
+		}
+	case *CreateTableStatement:
+		a.addTable(strings.ToLower(n.TableName), true)
+		for _, column := range n.Columns {
+			if column.References != nil {
+				a.addTable(strings.ToLower(column.References.TableName), false)
+			}
+		}
+		for _, constraint := range n.Constraints {
+			if constraint.References != nil {
+				a.addTable(strings.ToLower(constraint.References.TableName), false)
+			}
+		}
+	case *DropTableStatement:
+		a.addTable(strings.ToLower(n.TableName), true)
+	case *AlterTableStatement:
+		a.addTable(strings.ToLower(n.TableName), true)
+	case *CreateIndexStatement:
+		a.addTable(strings.ToLower(n.TableName), true)
+	case *DropIndexStatement:
+		a.addTable(strings.ToLower(n.TableName), true)
+	}
+}
+
+// selectStatement 分析一层SELECT，parent 是外层查询的作用域（相关子查询可以引用它）。
+func (a *analyzer) selectStatement(stmt *SelectStatement, parent *scope) {
+	if stmt == nil {
+		return
+	}
+	s := &scope{parent: parent, aliases: make(map[string]bool), ctes: make(map[string]*source)}
+
+	if stmt.With != nil {
+		for _, cte := range stmt.With.CTEs {
+			name := strings.ToLower(cte.Name)
+			columns := lowerAll(cte.Columns)
+			if columns == nil {
+				columns = outputColumns(cte.Select)
+			}
+			// 递归CTE可以引用自身，先登记再分析
+			s.ctes[name] = &source{name: name, table: name, columns: columns}
+			a.selectStatement(cte.Select, s)
+		}
+	}
+
+	if stmt.From != nil {
+		if stmt.From.Derived != nil {
+			s.sources = append(s.sources, a.derivedSource(stmt.From.Derived, s))
+		} else {
+			s.sources = append(s.sources, a.tableSource(stmt.From.TableName, stmt.From.Alias, s))
+		}
+		for _, join := range stmt.From.Joins {
+			if join.Derived != nil {
+				s.sources = append(s.sources, a.derivedSource(join.Derived, s))
+			} else if join.Table != nil {
+				alias := ""
+				if join.Alias != nil {
+					alias = join.Alias.Alias
+				}
+				s.sources = append(s.sources, a.tableSource(join.Table.Name, alias, s))
+			}
+		}
+		for _, join := range stmt.From.Joins {
+			for _, column := range join.Using {
+				a.resolve(&Identifier{Name: column}, &scope{sources: s.sources})
+			}
+			if join.On != nil {
+				a.expression(join.On.Condition, s)
+			}
+		}
+	}
+
+	for _, column := range stmt.Columns {
+		a.expression(column, s)
+		if aliased, ok := column.(*AliasedExpression); ok {
+			s.aliases[strings.ToLower(aliased.Alias)] = true
+		}
+	}
+	if stmt.Where != nil {
+		a.expression(stmt.Where.Condition, s)
+	}
+	if stmt.GroupBy != nil {
+		for _, column := range stmt.GroupBy.Columns {
+			a.expression(column, s)
+		}
+	}
+	if stmt.Having != nil {
+		a.expression(stmt.Having.Condition, s)
+	}
+	for _, op := range stmt.SetOperations {
+		// 每个分支有自己的FROM，只共享WITH中定义的CTE
+		a.selectStatement(op.Select, &scope{parent: parent, ctes: s.ctes})
+	}
+	if stmt.OrderBy != nil {
+		for _, order := range stmt.OrderBy.Columns {
+			a.expression(order.Column, s)
+		}
+	}
+}
+
+// derivedSource 分析派生表，它的列是子查询的输出列。
+func (a *analyzer) derivedSource(derived *NestedSubQuery, parent *scope) *source {
+	a.selectStatement(derived.Select, parent)
+	name := strings.ToLower(derived.Alias)
+	return &source{name: name, table: name, columns: outputColumns(derived.Select)}
+}
+
+// outputColumns 返回SELECT的输出列名，包含 * 或无法命名的表达式时返回nil。
+func outputColumns(stmt *SelectStatement) []string {
+	if stmt == nil {
+		return nil
+	}
+	var columns []string
+	for _, column := range stmt.Columns {
+		switch c := column.(type) {
+		case *AliasedExpression:
+			columns = append(columns, strings.ToLower(c.Alias))
+		case *Identifier:
+			columns = append(columns, lastPart(strings.ToLower(c.Name)))
+		default:
+			return nil
+		}
+	}
+	return columns
+}
+
+// expression 解析表达式中的列引用，子查询在新的作用域中分析。
+func (a *analyzer) expression(node ASTNode, s *scope) {
+	Inspect(node, func(n ASTNode) bool {
+		switch n := n.(type) {
+		case *Subquery:
+			a.selectStatement(n.Statement, s)
+			return false
+		case *SubQuery:
+			a.selectStatement(n.Select, s)
+			return false
+		case *Identifier:
+			a.resolve(n, s)
+		case *ColumnName:
+			name := n.Name
+			if n.Table != "" {
+				name = n.Table + "." + n.Name
+			}
+			a.resolve(&Identifier{Name: name}, s)
+		}
+		return true
+	})
+}
+
+// resolve 把列引用解析到作用域中的表，记录歧义和未知的引用。
+func (a *analyzer) resolve(ident *Identifier, s *scope) {
+	name := strings.ToLower(ident.Name)
+	column := lastPart(name)
+	if column == "*" || column == "" {
+		return
+	}
+
+	if qualifier := strings.TrimSuffix(name, "."+column); qualifier != name {
+		for current := s; current != nil; current = current.parent {
+			for _, src := range current.sources {
+				if src.name == qualifier || src.table == qualifier {
+					if src.columns != nil && !contains(src.columns, column) {
+						a.problemf("unknown column %s in %s", column, src.table)
+					}
+					a.addColumn(ident, src.table, column)
+					return
+				}
+			}
+		}
+		a.problemf("unknown table or alias %s in %s", qualifier, ident.Name)
+		a.addColumn(ident, "", column)
+		return
+	}
+
+	for current := s; current != nil; current = current.parent {
+		var matches []*source
+		unknown := 0
+		for _, src := range current.sources {
+			if src.columns == nil {
+				unknown++
+			} else if contains(src.columns, column) {
+				matches = append(matches, src)
+			}
+		}
+		switch {
+		case len(matches) > 1:
+			a.problemf("ambiguous column %s in %s and %s", column, matches[0].table, matches[1].table)
+			a.addColumn(ident, "", column)
+			return
+		case len(matches) == 1 && unknown == 0:
+			a.addColumn(ident, matches[0].table, column)
+			return
+		case len(matches) == 0 && current.aliases[column]:
+			return // 引用选择列表中的别名
+		case len(matches) == 0 && unknown == 1 && len(current.sources) == 1:
+			// 唯一的表结构未知，列只可能属于它
+			a.addColumn(ident, current.sources[0].table, column)
+			return
+		case unknown > 0:
+			// 有结构未知的表时无法确定列属于哪个表，也不能判定为未知列
+			a.addColumn(ident, "", column)
+			return
+		}
+	}
+	if s != nil && len(s.sources) > 0 {
+		a.problemf("unknown column %s", column)
+	}
+	a.addColumn(ident, "", column)
+}
+
+func (a *analyzer) addColumn(node ASTNode, table, column string) {
+	a.analysis.Columns = append(a.analysis.Columns, &ColumnRef{Node: node, Table: table, Column: column})
+}
+
+func lowerAll(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+	}
+	return lower
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAnalyze(t *testing.T) {
+	schema := Schema{
+		"users":  {"id", "name", "email"},
+		"orders": {"id", "user_id", "total"},
+	}
+
+	stmt, _ := parseSQL("SELECT u.name, total FROM users u JOIN orders o ON u.id = o.user_id WHERE o.id IN (SELECT id FROM admins WHERE admins.uid = u.id)")
+	analysis := Analyze(stmt, schema)
+	if strings.Join(analysis.Reads, ",") != "users,orders,admins" {
+		t.Errorf("Unexpected reads %v", analysis.Reads)
+	}
+	var resolved []string
+	for _, column := range analysis.Columns {
+		resolved = append(resolved, column.Table+"."+column.Column)
+	}
+	expected := "users.id,orders.user_id,users.name,orders.total,orders.id,admins.id,admins.uid,users.id"
+	if strings.Join(resolved, ",") != expected {
+		t.Errorf("Expected columns %s, got %s", expected, strings.Join(resolved, ","))
+	}
+	if len(analysis.Problems) > 0 {
+		t.Errorf("Unexpected problems %v", analysis.Problems)
+	}
+
+	tests := []struct {
+		input    string
+		problems string
+	}{
+		{"SELECT id FROM users, orders", "ambiguous column id in users and orders"},
+		{"SELECT x.id FROM users u", "unknown table or alias x in x.id"},
+		{"SELECT u.password FROM users u", "unknown column password in users"},
+		{"SELECT name AS n FROM users ORDER BY n", ""},
+		{"WITH t AS (SELECT id FROM users) SELECT t.id FROM t", ""},
+	}
+	for _, tt := range tests {
+		stmt, _ := parseSQL(tt.input)
+		if got := strings.Join(Analyze(stmt, schema).Problems, "; "); got != tt.problems {
+			t.Errorf("For input %q, expected problems %q but got %q", tt.input, tt.problems, got)
+		}
+	}
+
+	script := ParseScript("SELECT name FROM users WHERE id = 1; SELECT user, authentication_string FROM mysql.user; UPDATE users SET name = 'x'")
+	results := AnalyzeScript(script, nil)
+	if len(results) != 3 || strings.Join(results[1].SystemObjects, ",") != "mysql.user" || strings.Join(results[2].Writes, ",") != "users" {
+		t.Errorf("Unexpected script analysis %+v", results)
+	}
+	stmt, _ = parseSQL("SELECT table_name FROM information_schema.tables WHERE table_schema = database()")
+	if strings.Join(Analyze(stmt, nil).SystemObjects, ",") != "information_schema.tables" {
+		t.Errorf("Expected information_schema.tables to be flagged")
+	}
+
+	// UNION 的每个分支都要分析
+	unions := []struct {
+		input  string
+		reads  string
+		system string
+	}{
+		{"SELECT name FROM users WHERE id = 1 UNION ALL SELECT user,password FROM mysql.user", "users,mysql.user", "mysql.user"},
+		{"SELECT name FROM users WHERE id = 1 UNION SELECT table_name FROM information_schema.tables", "users,information_schema.tables", "information_schema.tables"},
+		{"WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n+1 FROM r) SELECT n FROM r EXCEPT SELECT id FROM orders", "orders", ""},
+	}
+	for _, tt := range unions {
+		stmt, _ := parseSQL(tt.input)
+		analysis := Analyze(stmt, schema)
+		if strings.Join(analysis.Reads, ",") != tt.reads || strings.Join(analysis.SystemObjects, ",") != tt.system || len(analysis.Problems) > 0 {
+			t.Errorf("For input %q, expected reads %s and system objects %q, got %+v", tt.input, tt.reads, tt.system, analysis)
+		}
+	}
+}
@@ -7,75 +7,123 @@ import (
 
 // ASTNode 代表抽象语法树中的一个节点。
 type ASTNode interface {
-	// 这个接口可以稍后扩展，例如添加一个方法来转换节点为字符串等。
+	Pos() int       // 节点在输入中的起始字节偏移
+	End() int       // 节点在输入中的结束字节偏移（不含）
+	String() string // 节点对应的SQL，见 Format
+}
+
+// span 记录节点在输入中的字节范围，嵌入到每个节点中实现 Pos 和 End。
+// 范围由解析器填写，改写或手工构造的节点为零值。
+type span struct {
+	pos int
+	end int
+}
+
+func (s *span) Pos() int { return s.pos }
+func (s *span) End() int { return s.end }
+
+// positioned 由嵌入了span的节点实现，解析器通过它填写节点的范围。
+type positioned interface {
+	setSpan(pos, end int)
+}
+
+func (s *span) setSpan(pos, end int) {
+	s.pos, s.end = pos, end
 }
 
 // AliasClause 表示别名子句。
 type AliasClause struct {
+	span
 	Alias string
 }
 
 // FromClause 代表FROM子句。
 type FromClause struct {
+	span
 	TableName string
-	Alias     string // 可选的表别名。
+	Alias     string          // 可选的表别名。
+	Derived   *NestedSubQuery // FROM (SELECT ...) t 时非空，此时TableName为空。
 	Joins     []*JoinClause
 }
 
-// JoinClause 代表JOIN子句。
+// JoinClause 代表JOIN子句，FROM中以逗号分隔的表也表示为Type为COMMA的JoinClause。
 type JoinClause struct {
-	Type  TokenType // 如 INNER, LEFT, RIGHT, FULL
-	Table *Identifier
-	Alias *AliasClause // 可选的表别名。
-	On    *WhereClause // JOIN的ON条件。
+	span
+	Type    TokenType // 如 INNER, LEFT, RIGHT, FULL, CROSS, COMMA
+	Natural bool      // NATURAL JOIN
+	Table   *Identifier
+	Derived *NestedSubQuery // 连接派生表时非空，此时Table为nil。
+	Alias   *AliasClause    // 可选的表别名。
+	On      *WhereClause    // JOIN的ON条件。
+	Using   []string        // JOIN的USING列。
 }
 
 // WhereClause 代表WHERE子句。
 type WhereClause struct {
+	span
 	Condition ASTNode
 }
 
 // GroupByClause 代表GROUP BY子句。
 type GroupByClause struct {
+	span
 	Columns []ASTNode
 }
 
 // HavingClause 代表HAVING子句。
 type HavingClause struct {
+	span
 	Condition ASTNode
 }
 
 // OrderByClause 代表ORDER BY子句。
 type OrderByClause struct {
+	span
 	Columns []*OrderByExpression
 }
 
 // OrderByExpression 代表一个ORDER BY中的表达式。
 type OrderByExpression struct {
+	span
 	Column    *Identifier
 	Direction TokenType // ASC 或 DESC
 }
 
-// LimitClause 代表LIMIT子句。
+// LimitClause 代表LIMIT子句，行数和偏移量通常是数字或占位符，也可以是表达式。
 type LimitClause struct {
-	Offset int
-	Count  int
+	span
+	Count  ASTNode
+	Offset ASTNode // 没有OFFSET时为nil。
 }
 
 // SelectStatement 代表一个SELECT语句。
 type SelectStatement struct {
+	span
+	With     *WithClause // 可选的WITH子句。
 	Distinct bool
 	Columns  []ASTNode // 这可以是`*`或特定的列。
 	From     *FromClause
 	Where    *WhereClause
 	GroupBy  *GroupByClause
 	Having   *HavingClause
-	OrderBy  *OrderByClause
-	Limit    *LimitClause
+	// 依次与前面的结果合并的 UNION/INTERSECT/EXCEPT 分支，
+	// 此时 OrderBy 和 Limit 作用于合并后的结果。
+	SetOperations []*SetOperation
+	OrderBy       *OrderByClause
+	Limit         *LimitClause
+}
+
+// SetOperation 代表 UNION [ALL]、INTERSECT 或 EXCEPT 以及它右侧的查询。
+type SetOperation struct {
+	span
+	Operator TokenType // UNION, INTERSECT 或 EXCEPT
+	All      bool
+	Select   *SelectStatement
 }
 
 // InsertStatement 代表一个INSERT语句。
 type InsertStatement struct {
+	span
 	TableName       string
 	Columns         []string    // 要插入的列名称。
 	Values          [][]ASTNode // 每个子数组代表一行的值。
@@ -84,6 +132,7 @@ type InsertStatement struct {
 
 // UpdateStatement 代表一个UPDATE语句。
 type UpdateStatement struct {
+	span
 	TableName string
 	Updates   []*UpdateExpression
 	Where     *WhereClause
@@ -91,76 +140,124 @@ type UpdateStatement struct {
 
 // UpdateExpression 代表UPDATE语句中的一个更新表达式。
 type UpdateExpression struct {
+	span
 	Column string
 	Value  ASTNode
 }
 
 // DeleteStatement 代表一个DELETE语句。
 type DeleteStatement struct {
+	span
 	TableName string
 	Where     *WhereClause
 }
 
 // CreateTableStatement 代表一个CREATE TABLE语句。
 type CreateTableStatement struct {
-	TableName string
-	Columns   []*ColumnDefinition
+	span
+	TableName   string
+	Columns     []*ColumnDefinition
+	Constraints []*TableConstraint
+	IfNotExists bool
+	Temporary   bool
 }
 
 // ColumnDefinition 代表CREATE TABLE语句中的列定义。
 type ColumnDefinition struct {
-	Name    string
-	Type    string
-	NotNull bool
-	// ... 其他属性，例如默认值、是否是主键等。
+	span
+	Name          string
+	Type          string
+	NotNull       bool
+	Default       ASTNode // DEFAULT 值，没有时为nil。
+	PrimaryKey    bool
+	Unique        bool
+	AutoIncrement bool
+	Check         ASTNode              // 列级CHECK约束。
+	References    *ForeignKeyReference // 列级REFERENCES约束。
+}
+
+// TableConstraint 代表CREATE TABLE中的表级约束，如 PRIMARY KEY (a, b)。
+type TableConstraint struct {
+	span
+	Name       string    // CONSTRAINT name，可选。
+	Type       TokenType // PRIMARY, UNIQUE, FOREIGN, CHECK 或 INDEX
+	Columns    []string
+	Check      ASTNode              // Type为CHECK时的条件。
+	References *ForeignKeyReference // Type为FOREIGN时引用的表。
+}
+
+// ForeignKeyReference 代表外键的 REFERENCES 部分。
+type ForeignKeyReference struct {
+	span
+	TableName string
+	Columns   []string
+	OnDelete  string // 如 CASCADE、SET NULL，未指定时为空。
+	OnUpdate  string
 }
 
 // DropTableStatement 代表一个DROP TABLE语句。
 type DropTableStatement struct {
+	span
 	TableName string
+	IfExists  bool
+	Cascade   bool
 }
 
 // CreateIndexStatement 代表一个CREATE INDEX语句。
 type CreateIndexStatement struct {
-	IndexName string
-	TableName string
-	Columns   []string
-	IsUnique  bool
+	span
+	IndexName   string
+	TableName   string
+	Columns     []string
+	IsUnique    bool
+	IfNotExists bool
 }
 
 // DropIndexStatement 代表一个DROP INDEX语句。
 type DropIndexStatement struct {
+	span
 	IndexName string
+	TableName string // MySQL 的 DROP INDEX name ON table。
+	IfExists  bool
 }
 
 // ... 更多的语句结构可以根据需要添加。
 type NumberLiteral struct {
+	span
 	Value string
 }
 
 type StringLiteral struct {
+	span
 	Value string
 }
 
 type BooleanLiteral struct {
+	span
 	Value bool
 }
 
 // 表示子查询
 type Subquery struct {
+	span
 	Statement *SelectStatement
 }
 
 // 表示NULL字面量
-type NullLiteral struct{}
+type NullLiteral struct{ span }
+
+// Placeholder 表示预编译语句的参数占位符 `?`，模板化时字面量也会被替换为它。
+type Placeholder struct{ span }
 
 // Identifier 代表一个标识符。
 type Identifier struct {
+	span
 	Name string
 }
 
 // BinaryExpr 表示两个值之间的二元表达式，如 `column1 = 'value'`。
 type BinaryExpr struct {
+	span
 	Left     ASTNode
 	Operator TokenType
 	Right    ASTNode
@@ -168,86 +265,122 @@ type BinaryExpr struct {
 
 // UnaryExpr 表示单目运算表达式，如 `NOT condition`。
 type UnaryExpr struct {
+	span
 	Operator TokenType
 	Operand  ASTNode
 }
 
 // LiteralValue 表示一个字面值，如数字、字符串等。
 type LiteralValue struct {
+	span
 	Type  TokenType
 	Value string
 }
 
 // ColumnName 表示一个列名，可能包括表名或别名，如 `table.column`。
 type ColumnName struct {
+	span
 	Table string
 	Name  string
 }
 
 type AliasedExpression struct {
+	span
 	Expr  ASTNode
 	Alias string
 }
 
 // FunctionCall 表示一个函数调用。
 type FunctionCall struct {
+	span
 	Name     string
 	Args     []ASTNode
 	Distinct bool
 }
 
 // Star 表示 `*`，用于SELECT语句中。
-type Star struct{}
+type Star struct{ span }
 
 // SubQuery 表示一个子查询。
 type SubQuery struct {
+	span
 	Select *SelectStatement
 }
 
 // BetweenExpr 表示BETWEEN表达式。
 type BetweenExpr struct {
+	span
 	Operand    ASTNode
 	LowerBound ASTNode
 	UpperBound ASTNode
+	Not        bool // NOT BETWEEN
 }
 
-// InExpr 表示IN表达式。
+// InExpr 表示IN表达式，右侧是值列表或子查询。
 type InExpr struct {
-	Value  ASTNode
-	Values []ASTNode
+	span
+	Value    ASTNode
+	Values   []ASTNode
+	Subquery *Subquery // IN (SELECT ...) 时非空，此时Values为空。
+	Not      bool      // NOT IN
 }
 
-// LikeExpr 表示LIKE表达式。
+// LikeExpr 表示LIKE表达式，也用于REGEXP/RLIKE。
 type LikeExpr struct {
-	Value   ASTNode
-	Pattern ASTNode
+	span
+	Value    ASTNode
+	Pattern  ASTNode
+	Escape   ASTNode   // 可选的ESCAPE字符。
+	Operator TokenType // LIKE 或 REGEXP
+	Not      bool      // NOT LIKE / NOT REGEXP
+}
+
+// IsNullExpr 表示 `expr IS [NOT] NULL`。
+type IsNullExpr struct {
+	span
+	Operand ASTNode
+	Not     bool
+}
+
+// ExistsExpr 表示 `EXISTS (subquery)`，NOT EXISTS 由外层的UnaryExpr表示。
+type ExistsExpr struct {
+	span
+	Subquery *Subquery
 }
 
-// CaseExpr 表示CASE表达式。
+// CaseExpr 表示CASE表达式。Expr为空时是搜索式CASE（CASE WHEN cond THEN ...），
+// 否则是简单CASE（CASE expr WHEN value THEN ...）。
 type CaseExpr struct {
-	Expr     ASTNode
-	Branches []*CaseBranch
-	Default  ASTNode
+	span
+	Expr       ASTNode
+	Branches   []*CaseBranch
+	Default    ASTNode
+	IfFunction bool // 由MySQL的 IF(cond, a, b) 转换而来。
 }
 
 // CaseBranch 表示CASE表达式中的一个分支。
 type CaseBranch struct {
+	span
 	Condition ASTNode
 	Result    ASTNode
 }
 
 // AlterTableStatement 代表一个ALTER TABLE语句。
 type AlterTableStatement struct {
+	span
 	TableName string
 	Actions   []*AlterAction
 }
 
 // AlterAction 代表ALTER TABLE中的一个动作。
+// DROP_COLUMN和RENAME_COLUMN只填写Column.Name，RENAME_TABLE只填写NewName。
 type AlterAction struct {
+	span
 	Type     AlterActionType
 	Column   *ColumnDefinition
 	NewName  string
-	Position int // 对于某些数据库，可能需要指定新列的位置。
+	Position int    // 对于某些数据库，可能需要指定新列的位置，1 表示 FIRST。
+	After    string // MySQL 的 AFTER column。
 }
 
 // AlterActionType 代表ALTER TABLE动作的类型。
@@ -258,24 +391,35 @@ const (
 	DROP_COLUMN
 	MODIFY_COLUMN
 	RENAME_COLUMN
+	RENAME_TABLE
 	// ... 其他可能的动作。
 )
 
 // TransactionStatement 代表事务相关的语句，如START TRANSACTION、COMMIT、ROLLBACK。
 type TransactionStatement struct {
+	span
 	Type TransactionType
 }
 
 // TransactionType 代表事务操作的类型。
 type TransactionType int
 
+const (
+	BEGIN_TRANSACTION TransactionType = iota
+	COMMIT_TRANSACTION
+	ROLLBACK_TRANSACTION
+)
+
 // WithClause 代表WITH子句，即公共表表达式。
 type WithClause struct {
-	CTEs []*CommonTableExpression
+	span
+	Recursive bool
+	CTEs      []*CommonTableExpression
 }
 
 // CommonTableExpression 代表公共表表达式。
 type CommonTableExpression struct {
+	span
 	Name    string
 	Columns []string
 	Select  *SelectStatement
@@ -283,12 +427,28 @@ type CommonTableExpression struct {
 
 // NestedSubQuery 代表嵌套的子查询。
 type NestedSubQuery struct {
+	span
 	Select *SelectStatement
 	Alias  string
 }
 
+// Script 代表由分号分隔的多条语句，如堆叠查询 `1; DROP TABLE users--`。
+type Script struct {
+	span
+	Statements []*ScriptStatement
+}
+
+// ScriptStatement 代表脚本中的一条语句及其在原始输入中的位置。
+type ScriptStatement struct {
+	span
+	Node   ASTNode // 无法识别的语句为nil。
+	Text   string  // 语句原文，不含结尾的分号。
+	Errors []string
+}
+
 func printAST(node ASTNode, indent int) {
-	if node == nil {
+	// 可选子句以带类型的nil指针传入，同样视为空节点
+	if isNilNode(node) {
 		return
 	}
 
@@ -297,6 +457,7 @@ func printAST(node ASTNode, indent int) {
 	switch n := node.(type) {
 	case *SelectStatement:
 		fmt.Println(prefix + "SelectStatement:")
+		printAST(n.With, indent+1)
 		if n.Distinct {
 			fmt.Println(prefix + "  Distinct: true")
 		}
@@ -307,20 +468,42 @@ func printAST(node ASTNode, indent int) {
 		printAST(n.Where, indent+1)
 		printAST(n.GroupBy, indent+1)
 		printAST(n.Having, indent+1)
+		for _, op := range n.SetOperations {
+			printAST(op, indent+1)
+		}
 		printAST(n.OrderBy, indent+1)
 		printAST(n.Limit, indent+1)
+	case *SetOperation:
+		fmt.Printf("%sSetOperation: %s All: %t\n", prefix, n.Operator, n.All)
+		printAST(n.Select, indent+1)
 	case *FromClause:
 		fmt.Printf("%sFromClause: %s Alias: %s\n", prefix, n.TableName, n.Alias)
+		printAST(n.Derived, indent+1)
 		for _, join := range n.Joins {
 			printAST(join, indent+1)
 		}
 	case *JoinClause:
-		fmt.Printf("%sJoinClause: %v\n", prefix, n.Type)
+		fmt.Printf("%sJoinClause: %s Natural: %t\n", prefix, n.Type, n.Natural)
 		printAST(n.Table, indent+1)
+		printAST(n.Derived, indent+1)
 		if n.Alias != nil {
 			fmt.Printf("%sAlias: %s\n", prefix+"  ", n.Alias.Alias)
 		}
 		printAST(n.On, indent+1)
+		if len(n.Using) > 0 {
+			fmt.Printf("%sUsing: %v\n", prefix+"  ", n.Using)
+		}
+	case *WithClause:
+		fmt.Printf("%sWithClause: Recursive: %t\n", prefix, n.Recursive)
+		for _, cte := range n.CTEs {
+			printAST(cte, indent+1)
+		}
+	case *CommonTableExpression:
+		fmt.Printf("%sCommonTableExpression: %s %v\n", prefix, n.Name, n.Columns)
+		printAST(n.Select, indent+1)
+	case *NestedSubQuery:
+		fmt.Printf("%sNestedSubQuery: Alias: %s\n", prefix, n.Alias)
+		printAST(n.Select, indent+1)
 	case *WhereClause:
 		fmt.Println(prefix + "WhereClause:")
 		printAST(n.Condition, indent+1)
@@ -335,22 +518,19 @@ func printAST(node ASTNode, indent int) {
 			printAST(expr, indent+1)
 		}
 	case *OrderByExpression:
-		for s, tokenType := range keywords {
-			if tokenType == n.Direction {
-				fmt.Printf("%sOrderByExpression: %s %s\n", prefix, n.Column.Name, s)
-			}
-		}
+		fmt.Printf("%sOrderByExpression: %s %s\n", prefix, n.Column.Name, n.Direction)
 
 	case *LimitClause:
-		fmt.Printf("%sLimitClause: Limit: %d Offset: %d\n", prefix, n.Count, n.Offset)
+		fmt.Println(prefix + "LimitClause:")
+		printAST(n.Count, indent+1)
+		if n.Offset != nil {
+			fmt.Println(prefix + "  Offset:")
+			printAST(n.Offset, indent+2)
+		}
 	case *Identifier:
 		fmt.Printf("%sIdentifier: %s\n", prefix, n.Name)
 	case *BinaryExpr:
-		for s, tokenType := range keywords {
-			if tokenType == n.Operator {
-				fmt.Printf("%sBinaryExpr: %s\n", prefix, s)
-			}
-		}
+		fmt.Printf("%sBinaryExpr: %s\n", prefix, n.Operator)
 		printAST(n.Left, indent+1)
 		printAST(n.Right, indent+1)
 	case *FunctionCall:
@@ -370,36 +550,109 @@ func printAST(node ASTNode, indent int) {
 		fmt.Printf("%sBooleanLiteral: %t\n", prefix, n.Value)
 	case *NullLiteral:
 		fmt.Println(prefix + "NullLiteral")
+	case *Placeholder:
+		fmt.Println(prefix + "Placeholder: ?")
 	case *Star:
 		fmt.Println(prefix + "Star: *")
 	case *Subquery:
 		fmt.Println(prefix + "Subquery:")
 		printAST(n.Statement, indent+1)
+	case *UnaryExpr:
+		fmt.Printf("%sUnaryExpr: %s\n", prefix, n.Operator)
+		printAST(n.Operand, indent+1)
 	case *BetweenExpr:
-		fmt.Println(prefix + "BetweenExpr:")
+		fmt.Printf("%sBetweenExpr: Not: %t\n", prefix, n.Not)
 		printAST(n.Operand, indent+1)
 		printAST(n.LowerBound, indent+1)
 		printAST(n.UpperBound, indent+1)
 	case *InExpr:
-		fmt.Println(prefix + "InExpr:")
+		fmt.Printf("%sInExpr: Not: %t\n", prefix, n.Not)
 		printAST(n.Value, indent+1)
 		for _, v := range n.Values {
 			printAST(v, indent+1)
 		}
+		printAST(n.Subquery, indent+1)
 	case *LikeExpr:
-		fmt.Println(prefix + "LikeExpr:")
+		fmt.Printf("%sLikeExpr: %s Not: %t\n", prefix, n.Operator, n.Not)
 		printAST(n.Value, indent+1)
 		printAST(n.Pattern, indent+1)
+		if n.Escape != nil {
+			fmt.Println(prefix + "  Escape:")
+			printAST(n.Escape, indent+2)
+		}
+	case *IsNullExpr:
+		fmt.Printf("%sIsNullExpr: Not: %t\n", prefix, n.Not)
+		printAST(n.Operand, indent+1)
+	case *ExistsExpr:
+		fmt.Println(prefix + "ExistsExpr:")
+		printAST(n.Subquery, indent+1)
 	case *CaseExpr:
-		fmt.Println(prefix + "CaseExpr:")
+		fmt.Printf("%sCaseExpr: IfFunction: %t\n", prefix, n.IfFunction)
+		printAST(n.Expr, indent+1)
 		for _, branch := range n.Branches {
 			printAST(branch, indent+1)
 		}
-		printAST(n.Default, indent+1)
+		if n.Default != nil {
+			fmt.Println(prefix + "  Else:")
+			printAST(n.Default, indent+2)
+		}
 	case *CaseBranch:
 		fmt.Println(prefix + "CaseBranch:")
 		printAST(n.Condition, indent+1)
 		printAST(n.Result, indent+1)
+	case *CreateTableStatement:
+		fmt.Printf("%sCreateTableStatement: %s IfNotExists: %t Temporary: %t\n", prefix, n.TableName, n.IfNotExists, n.Temporary)
+		for _, col := range n.Columns {
+			printAST(col, indent+1)
+		}
+		for _, constraint := range n.Constraints {
+			printAST(constraint, indent+1)
+		}
+	case *ColumnDefinition:
+		fmt.Printf("%sColumnDefinition: %s %s NotNull: %t PrimaryKey: %t Unique: %t AutoIncrement: %t\n",
+			prefix, n.Name, n.Type, n.NotNull, n.PrimaryKey, n.Unique, n.AutoIncrement)
+		if n.Default != nil {
+			fmt.Println(prefix + "  Default:")
+			printAST(n.Default, indent+2)
+		}
+		if n.Check != nil {
+			fmt.Println(prefix + "  Check:")
+			printAST(n.Check, indent+2)
+		}
+		printAST(n.References, indent+1)
+	case *TableConstraint:
+		fmt.Printf("%sTableConstraint: %s %s Columns: %v\n", prefix, n.Name, n.Type, n.Columns)
+		printAST(n.Check, indent+1)
+		printAST(n.References, indent+1)
+	case *ForeignKeyReference:
+		fmt.Printf("%sReferences: %s %v OnDelete: %s OnUpdate: %s\n", prefix, n.TableName, n.Columns, n.OnDelete, n.OnUpdate)
+	case *DropTableStatement:
+		fmt.Printf("%sDropTableStatement: %s IfExists: %t Cascade: %t\n", prefix, n.TableName, n.IfExists, n.Cascade)
+	case *CreateIndexStatement:
+		fmt.Printf("%sCreateIndexStatement: %s ON %s %v Unique: %t IfNotExists: %t\n", prefix, n.IndexName, n.TableName, n.Columns, n.IsUnique, n.IfNotExists)
+	case *DropIndexStatement:
+		fmt.Printf("%sDropIndexStatement: %s ON %s IfExists: %t\n", prefix, n.IndexName, n.TableName, n.IfExists)
+	case *AlterTableStatement:
+		fmt.Printf("%sAlterTableStatement: %s\n", prefix, n.TableName)
+		for _, action := range n.Actions {
+			printAST(action, indent+1)
+		}
+	case *AlterAction:
+		fmt.Printf("%sAlterAction: %d NewName: %s Position: %d After: %s\n", prefix, n.Type, n.NewName, n.Position, n.After)
+		printAST(n.Column, indent+1)
+	case *TransactionStatement:
+		fmt.Printf("%sTransactionStatement: %d\n", prefix, n.Type)
+	case *Script:
+		fmt.Printf("%sScript: %d statements\n", prefix, len(n.Statements))
+		for _, stmt := range n.Statements {
+			printAST(stmt, indent+1)
+		}
+	case *ScriptStatement:
+		fmt.Printf("%sScriptStatement: [%d:%d] %q\n", prefix, n.Pos(), n.End(), n.Text)
+		for _, err := range n.Errors {
+			fmt.Printf("%s  Error: %s\n", prefix, err)
+		}
+		printAST(n.Node, indent+1)
 	// Add more nodes as needed
 	default:
 		fmt.Printf("%sUnhandled type: %T\n", prefix, n)
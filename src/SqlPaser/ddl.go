@@ -0,0 +1,496 @@
+package SqlPaser
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseCreateStatement 解析CREATE语句，调用前CREATE已被消费。
+func (p *Parser) parseCreateStatement() ASTNode {
+	switch {
+	case p.match(TABLE):
+		return p.parseCreateTableStatement(false)
+	case p.matchWord("TEMPORARY"):
+		p.expect(TABLE)
+		return p.parseCreateTableStatement(true)
+	case p.match(UNIQUE):
+		p.expect(INDEX)
+		stmt := p.parseCreateIndexStatement()
+		stmt.IsUnique = true
+		return stmt
+	case p.match(INDEX):
+		return p.parseCreateIndexStatement()
+	default:
+		p.errorf("unsupported CREATE %q", p.peek().Value)
+		return nil
+	}
+}
+
+// parseDropStatement 解析DROP TABLE和DROP INDEX语句，调用前DROP已被消费。
+func (p *Parser) parseDropStatement() ASTNode {
+	switch {
+	case p.match(TABLE):
+		stmt := &DropTableStatement{}
+		stmt.IfExists = p.parseIfExists()
+		stmt.TableName = p.parseName()
+		if p.matchWord("CASCADE") {
+			stmt.Cascade = true
+		} else {
+			p.matchWord("RESTRICT")
+		}
+		return stmt
+	case p.match(INDEX):
+		stmt := &DropIndexStatement{}
+		stmt.IfExists = p.parseIfExists()
+		stmt.IndexName = p.parseName()
+		if p.match(ON) {
+			stmt.TableName = p.parseName()
+		}
+		return stmt
+	default:
+		p.errorf("unsupported DROP %q", p.peek().Value)
+		return nil
+	}
+}
+
+// parseCreateTableStatement 解析 CREATE TABLE 之后的部分。
+func (p *Parser) parseCreateTableStatement(temporary bool) *CreateTableStatement {
+	stmt := &CreateTableStatement{Temporary: temporary}
+	stmt.IfNotExists = p.parseIfNotExists()
+	stmt.TableName = p.parseName()
+
+	if !p.match(LEFT_PAREN) {
+		p.errorf("expected ( after CREATE TABLE %s, got %q", stmt.TableName, p.peek().Value)
+		return stmt
+	}
+	for {
+		start := p.mark()
+		if constraint := p.parseTableConstraint(); constraint != nil {
+			p.finish(constraint, start)
+			stmt.Constraints = append(stmt.Constraints, constraint)
+		} else {
+			stmt.Columns = append(stmt.Columns, p.parseColumnDefinition())
+		}
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	if !p.match(RIGHT_PAREN) {
+		p.errorf("expected ) to close CREATE TABLE, got %q", p.peek().Value)
+	}
+
+	// 跳过 ENGINE=InnoDB 之类的表选项
+	for p.peek().Type != EOF && p.peek().Type != SEMICOLON {
+		if token := p.advance(); token.Type == ILLEGAL {
+			p.errorf("unexpected %q in table options", token.Value)
+		}
+	}
+	return stmt
+}
+
+// parseColumnDefinition 解析列名、类型以及列级约束。
+func (p *Parser) parseColumnDefinition() *ColumnDefinition {
+	start := p.mark()
+	column := &ColumnDefinition{Name: p.parseName()}
+	defer p.finish(column, start)
+	column.Type = p.parseColumnType()
+
+	for {
+		switch {
+		case p.match(CONSTRAINT):
+			p.parseName() // 列级约束的名称不保留
+		case p.match(NOT):
+			p.expect(NULL)
+			column.NotNull = true
+		case p.match(NULL):
+			column.NotNull = false
+		case p.match(DEFAULT):
+			column.Default = p.parseTerm()
+		case p.match(PRIMARY):
+			p.matchWord("KEY")
+			column.PrimaryKey = true
+		case p.match(UNIQUE):
+			p.matchWord("KEY")
+			column.Unique = true
+		case p.matchWord("AUTO_INCREMENT"), p.matchWord("AUTOINCREMENT"):
+			column.AutoIncrement = true
+		case p.match(CHECK):
+			column.Check = p.parseParenExpression()
+		case p.match(REFERENCES):
+			column.References = p.parseForeignKeyReference()
+		case p.matchWord("COMMENT"):
+			p.expect(STRING)
+		case p.peek().Type == ON && p.peekAt(1).Type == UPDATE:
+			// MySQL 的 ON UPDATE CURRENT_TIMESTAMP，不影响结构，直接跳过
+			p.advance()
+			p.advance()
+			p.parseTerm()
+		default:
+			return column
+		}
+	}
+}
+
+// parseColumnType 解析列类型，如 INT、VARCHAR(255)、DECIMAL(10, 2) UNSIGNED。
+func (p *Parser) parseColumnType() string {
+	token := p.peek()
+	if token.Type != IDENTIFIER && token.Type != FUNCTION {
+		p.errorf("expected column type, got %q", token.Value)
+		return ""
+	}
+	p.advance()
+	columnType := strings.ToUpper(token.Value)
+
+	if p.match(LEFT_PAREN) {
+		var params []string
+		for p.peek().Type != RIGHT_PAREN && p.peek().Type != EOF {
+			switch token := p.advance(); token.Type {
+			case COMMA:
+			case ILLEGAL:
+				p.errorf("unexpected %q in column type", token.Value)
+			default:
+				params = append(params, token.Value)
+			}
+		}
+		p.expect(RIGHT_PAREN)
+		columnType += "(" + strings.Join(params, ", ") + ")"
+	}
+
+	for _, word := range []string{"UNSIGNED", "ZEROFILL", "PRECISION", "VARYING"} {
+		if p.matchWord(word) {
+			columnType += " " + word
+		}
+	}
+	return columnType
+}
+
+// parseTableConstraint 尝试解析一个表级约束，当前位置不是约束时返回nil且不消费令牌。
+func (p *Parser) parseTableConstraint() *TableConstraint {
+	constraint := &TableConstraint{}
+	if p.match(CONSTRAINT) {
+		if p.peek().Type == IDENTIFIER {
+			constraint.Name = p.advance().Value
+		}
+	}
+
+	switch {
+	case p.match(PRIMARY):
+		p.matchWord("KEY")
+		constraint.Type = PRIMARY
+		constraint.Columns = p.parseNameList()
+	case p.match(UNIQUE):
+		if !p.match(INDEX) {
+			p.matchWord("KEY")
+		}
+		constraint.Type = UNIQUE
+		if p.peek().Type == IDENTIFIER || p.peek().Type == FUNCTION {
+			constraint.Name = p.advance().Value
+		}
+		constraint.Columns = p.parseNameList()
+	case p.match(FOREIGN):
+		p.matchWord("KEY")
+		constraint.Type = FOREIGN
+		constraint.Columns = p.parseNameList()
+		if p.match(REFERENCES) {
+			constraint.References = p.parseForeignKeyReference()
+		} else {
+			p.errorf("expected REFERENCES in FOREIGN KEY, got %q", p.peek().Value)
+		}
+	case p.match(CHECK):
+		constraint.Type = CHECK
+		constraint.Check = p.parseParenExpression()
+	case p.match(INDEX), p.isIndexKey():
+		// MySQL 的 INDEX name (cols) / KEY name (cols)
+		constraint.Type = INDEX
+		if p.peek().Type == IDENTIFIER || p.peek().Type == FUNCTION {
+			constraint.Name = p.advance().Value
+		}
+		constraint.Columns = p.parseNameList()
+	default:
+		if constraint.Name != "" {
+			p.errorf("expected constraint after CONSTRAINT %s, got %q", constraint.Name, p.peek().Value)
+		}
+		return nil
+	}
+	return constraint
+}
+
+// parseForeignKeyReference 解析 REFERENCES 之后的表名、列名和 ON DELETE/ON UPDATE 动作。
+func (p *Parser) parseForeignKeyReference() *ForeignKeyReference {
+	start := p.getPreviousToken().Pos
+	reference := &ForeignKeyReference{TableName: p.parseName()}
+	defer p.finish(reference, start)
+	if p.peek().Type == LEFT_PAREN {
+		reference.Columns = p.parseNameList()
+	}
+	for p.match(ON) {
+		switch {
+		case p.match(DELETE):
+			reference.OnDelete = p.parseReferentialAction()
+		case p.match(UPDATE):
+			reference.OnUpdate = p.parseReferentialAction()
+		default:
+			p.errorf("expected DELETE or UPDATE after ON, got %q", p.peek().Value)
+			return reference
+		}
+	}
+	return reference
+}
+
+// parseReferentialAction 解析 CASCADE、RESTRICT、SET NULL、SET DEFAULT 或 NO ACTION。
+func (p *Parser) parseReferentialAction() string {
+	switch {
+	case p.matchWord("CASCADE"):
+		return "CASCADE"
+	case p.matchWord("RESTRICT"):
+		return "RESTRICT"
+	case p.match(SET):
+		if p.match(NULL) {
+			return "SET NULL"
+		}
+		p.expect(DEFAULT)
+		return "SET DEFAULT"
+	case p.matchWord("NO"):
+		p.matchWord("ACTION")
+		return "NO ACTION"
+	}
+	p.errorf("unsupported referential action %q", p.peek().Value)
+	return ""
+}
+
+// parseCreateIndexStatement 解析 CREATE [UNIQUE] INDEX 之后的部分。
+func (p *Parser) parseCreateIndexStatement() *CreateIndexStatement {
+	stmt := &CreateIndexStatement{}
+	stmt.IfNotExists = p.parseIfNotExists()
+	stmt.IndexName = p.parseName()
+	if !p.match(ON) {
+		p.errorf("expected ON in CREATE INDEX, got %q", p.peek().Value)
+		return stmt
+	}
+	stmt.TableName = p.parseName()
+	stmt.Columns = p.parseNameList()
+	return stmt
+}
+
+// parseAlterStatement 解析ALTER语句，目前只支持ALTER TABLE，调用前ALTER已被消费。
+func (p *Parser) parseAlterStatement() ASTNode {
+	if !p.match(TABLE) {
+		p.errorf("unsupported ALTER %q", p.peek().Value)
+		return nil
+	}
+	stmt := &AlterTableStatement{}
+	stmt.TableName = p.parseName()
+
+	for {
+		start := p.mark()
+		action := p.parseAlterAction()
+		if action == nil {
+			break
+		}
+		p.finish(action, start)
+		stmt.Actions = append(stmt.Actions, action)
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	return stmt
+}
+
+// parseAlterAction 解析 ALTER TABLE 中的一个动作。
+func (p *Parser) parseAlterAction() *AlterAction {
+	switch {
+	case p.matchWord("ADD"):
+		p.matchWord("COLUMN")
+		action := &AlterAction{Type: ADD_COLUMN, Column: p.parseColumnDefinition()}
+		p.parseColumnPosition(action)
+		return action
+	case p.match(DROP):
+		p.matchWord("COLUMN")
+		return &AlterAction{Type: DROP_COLUMN, Column: p.parseColumnReference()}
+	case p.matchWord("MODIFY"):
+		p.matchWord("COLUMN")
+		action := &AlterAction{Type: MODIFY_COLUMN, Column: p.parseColumnDefinition()}
+		p.parseColumnPosition(action)
+		return action
+	case p.match(ALTER):
+		// PostgreSQL 的 ALTER COLUMN name [SET DATA] TYPE type
+		p.matchWord("COLUMN")
+		column := p.parseColumnReference()
+		if p.match(SET) {
+			p.matchWord("DATA")
+		}
+		if !p.matchWord("TYPE") {
+			p.errorf("expected TYPE in ALTER COLUMN, got %q", p.peek().Value)
+			return nil
+		}
+		column.Type = p.parseColumnType()
+		return &AlterAction{Type: MODIFY_COLUMN, Column: column}
+	case p.matchWord("RENAME"):
+		if p.matchWord("COLUMN") {
+			action := &AlterAction{Type: RENAME_COLUMN, Column: p.parseColumnReference()}
+			p.matchWord("TO")
+			action.NewName = p.parseName()
+			return action
+		}
+		if !p.matchWord("TO") {
+			p.match(AS)
+		}
+		return &AlterAction{Type: RENAME_TABLE, NewName: p.parseName()}
+	default:
+		p.errorf("unsupported ALTER TABLE action %q", p.peek().Value)
+		return nil
+	}
+}
+
+// parseColumnReference 解析 DROP COLUMN、RENAME COLUMN 等动作中只有名称的列。
+func (p *Parser) parseColumnReference() *ColumnDefinition {
+	start := p.mark()
+	column := &ColumnDefinition{Name: p.parseName()}
+	p.finish(column, start)
+	return column
+}
+
+// parseColumnPosition 解析MySQL中列定义后的 FIRST 或 AFTER column。
+func (p *Parser) parseColumnPosition(action *AlterAction) {
+	if p.matchWord("FIRST") {
+		action.Position = 1
+	} else if p.matchWord("AFTER") {
+		action.After = p.parseName()
+	}
+}
+
+// parseIfExists 解析可选的 IF EXISTS。
+func (p *Parser) parseIfExists() bool {
+	if p.isWord("IF") && p.peekAt(1).Type == EXISTS {
+		p.advance()
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// parseIfNotExists 解析可选的 IF NOT EXISTS。
+func (p *Parser) parseIfNotExists() bool {
+	if p.isWord("IF") && p.peekAt(1).Type == NOT && p.peekAt(2).Type == EXISTS {
+		p.advance()
+		p.advance()
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// parseName 解析表名、列名或索引名。名称紧跟左括号时会被词法分析器识别为函数名，这里一并接受。
+func (p *Parser) parseName() string {
+	token := p.peek()
+	if token.Type != IDENTIFIER && token.Type != FUNCTION {
+		p.errorf("expected name, got %q", token.Value)
+		return ""
+	}
+	p.advance()
+	return token.Value
+}
+
+// parseNameList 解析括号内以逗号分隔的名称列表，忽略索引列上的前缀长度和排序方向。
+func (p *Parser) parseNameList() []string {
+	var names []string
+	if !p.match(LEFT_PAREN) {
+		p.errorf("expected ( before column list, got %q", p.peek().Value)
+		return names
+	}
+	for {
+		names = append(names, p.parseName())
+		if p.match(LEFT_PAREN) { // 如 name(10)
+			p.expect(NUMBER)
+			p.expect(RIGHT_PAREN)
+		}
+		p.match(ASC, DESC)
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	p.expect(RIGHT_PAREN)
+	return names
+}
+
+// parseParenExpression 解析括号包围的表达式，如 CHECK (price > 0)。
+func (p *Parser) parseParenExpression() ASTNode {
+	if !p.match(LEFT_PAREN) {
+		p.errorf("expected (, got %q", p.peek().Value)
+		return nil
+	}
+	expr := p.parseExpression()
+	p.expect(RIGHT_PAREN)
+	return expr
+}
+
+// isIndexKey 判断当前的KEY是否开始一个MySQL索引定义，是则消费它。
+// KEY不是保留字，`key VARCHAR(10)` 中的KEY是列名，此时括号内是数字而不是列名。
+func (p *Parser) isIndexKey() bool {
+	if !p.isWord("KEY") {
+		return false
+	}
+	next := p.peekAt(1)
+	isIndex := next.Type == LEFT_PAREN ||
+		((next.Type == IDENTIFIER || next.Type == FUNCTION) && p.peekAt(2).Type == LEFT_PAREN && p.peekAt(3).Type == IDENTIFIER)
+	if isIndex {
+		p.advance()
+	}
+	return isIndex
+}
+
+// isWord 判断当前令牌是否是给定的非保留字，但不消费它。
+func (p *Parser) isWord(word string) bool {
+	token := p.peek()
+	return (token.Type == IDENTIFIER || token.Type == FUNCTION) && strings.EqualFold(token.Value, word)
+}
+
+func TestParseDDLStatements(t *testing.T) {
+	stmt, errs := parseSQL(`CREATE TABLE IF NOT EXISTS orders (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    total DECIMAL(10, 2) DEFAULT 0,
+    CONSTRAINT uq_user UNIQUE (user_id, total)
+) ENGINE=InnoDB`)
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	createStmt, ok := stmt.(*CreateTableStatement)
+	if !ok {
+		t.Fatalf("Expected CreateTableStatement, got %T", stmt)
+	}
+	if createStmt.TableName != "orders" || !createStmt.IfNotExists {
+		t.Errorf("Expected CREATE TABLE IF NOT EXISTS orders, got %q IfNotExists=%t", createStmt.TableName, createStmt.IfNotExists)
+	}
+	if len(createStmt.Columns) != 3 || len(createStmt.Constraints) != 1 {
+		t.Fatalf("Expected 3 columns and 1 constraint, got %d and %d", len(createStmt.Columns), len(createStmt.Constraints))
+	}
+	if col := createStmt.Columns[0]; !col.PrimaryKey || !col.AutoIncrement {
+		t.Errorf("Expected id to be an AUTO_INCREMENT primary key")
+	}
+	if ref := createStmt.Columns[1].References; ref == nil || ref.TableName != "users" || ref.OnDelete != "CASCADE" {
+		t.Errorf("Expected user_id to reference users ON DELETE CASCADE, got %+v", ref)
+	}
+	if col := createStmt.Columns[2]; col.Type != "DECIMAL(10, 2)" || col.Default == nil {
+		t.Errorf("Expected total DECIMAL(10, 2) with a default, got %q", col.Type)
+	}
+
+	stmt, _ = parseSQL("DROP TABLE IF EXISTS users")
+	if dropStmt, ok := stmt.(*DropTableStatement); !ok || dropStmt.TableName != "users" || !dropStmt.IfExists {
+		t.Errorf("Expected DROP TABLE IF EXISTS users, got %+v", stmt)
+	}
+
+	stmt, _ = parseSQL("CREATE UNIQUE INDEX idx_email ON users (email)")
+	if indexStmt, ok := stmt.(*CreateIndexStatement); !ok || !indexStmt.IsUnique || indexStmt.TableName != "users" {
+		t.Errorf("Expected CREATE UNIQUE INDEX on users, got %+v", stmt)
+	}
+
+	stmt, _ = parseSQL("ALTER TABLE users ADD COLUMN age INT, DROP COLUMN tmp, RENAME COLUMN a TO b")
+	alterStmt, ok := stmt.(*AlterTableStatement)
+	if !ok || len(alterStmt.Actions) != 3 {
+		t.Fatalf("Expected ALTER TABLE with 3 actions, got %+v", stmt)
+	}
+	if alterStmt.Actions[0].Type != ADD_COLUMN || alterStmt.Actions[1].Type != DROP_COLUMN || alterStmt.Actions[2].NewName != "b" {
+		t.Errorf("Unexpected ALTER actions: %+v %+v %+v", alterStmt.Actions[0], alterStmt.Actions[1], alterStmt.Actions[2])
+	}
+}
@@ -0,0 +1,684 @@
+package SqlPaser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// FoldOptions 控制常量折叠的类型转换规则。
+// Dialect 为 DIALECT_MYSQL（默认值）时按MySQL的隐式转换计算：字符串与数字比较时字符串转换为数字，
+// 数字和字符串可以直接作为条件，字符串比较不区分大小写。其他方言只折叠类型一致的比较。
+type FoldOptions struct {
+	Dialect Dialect
+}
+
+// ConstantCondition 是一个恒真或恒假的条件，通常是 `OR 1=1` 之类的注入。
+type ConstantCondition struct {
+	Node   ASTNode // WHERE/ON/HAVING 的条件，或其中由 AND、OR、NOT 连接的一部分
+	Value  bool    // 恒为真时为true，恒为假或恒为NULL时为false
+	Reason string
+}
+
+// FindConstantConditions 查找节点中所有 WHERE、ON、HAVING 条件里恒真或恒假的部分。
+// 每个条件只报告最外层的常量表达式，如 `a = 1 OR 1 = 1` 整体恒真，不再单独报告 `1 = 1`。
+func FindConstantConditions(node ASTNode, opts FoldOptions) []*ConstantCondition {
+	f := &folder{mysql: opts.Dialect == DIALECT_MYSQL}
+	Inspect(node, func(n ASTNode) bool {
+		switch n := n.(type) {
+		case *WhereClause:
+			f.condition(n.Condition)
+		case *HavingClause:
+			f.condition(n.Condition)
+		}
+		return true
+	})
+	return f.found
+}
+
+// EvalConstant 计算常量表达式的值，结果为 float64、string、bool，NULL 为nil。
+// ok 为false表示表达式不是常量，如包含列或函数调用，或数字超出 float64 的范围。
+func EvalConstant(node ASTNode, opts FoldOptions) (value interface{}, ok bool) {
+	f := &folder{mysql: opts.Dialect == DIALECT_MYSQL}
+	c, _, ok := f.fold(node)
+	if !ok {
+		return nil, false
+	}
+	switch c.kind {
+	case constNumber:
+		number, _ := c.number.Float64()
+		return number, !math.IsInf(number, 0)
+	case constString:
+		return c.text, true
+	case constBool:
+		return c.truth, true
+	}
+	return nil, true
+}
+
+// constKind 是常量值的类型。
+type constKind int
+
+const (
+	constNull constKind = iota
+	constNumber
+	constString
+	constBool
+)
+
+// constant 是折叠得到的常量值。数字使用有理数精确计算，0.1 + 0.2 等于 0.3。
+type constant struct {
+	kind   constKind
+	number *big.Rat
+	text   string
+	truth  bool
+}
+
+func (c constant) String() string {
+	switch c.kind {
+	case constNumber:
+		return formatNumber(c.number)
+	case constString:
+		return "'" + c.text + "'"
+	case constBool:
+		return strings.ToUpper(strconv.FormatBool(c.truth))
+	}
+	return "NULL"
+}
+
+func formatNumber(n *big.Rat) string {
+	if n.IsInt() {
+		return n.Num().String()
+	}
+	f, _ := n.Float64()
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// decimalNumber 是精确折叠的数字字面量。指数最多三位，避免构造巨大的有理数；
+// 十六进制等其他形式不作为常量。
+var decimalNumber = regexp.MustCompile(`^(\d+\.?\d*|\.\d+)([eE][+-]?\d{1,3})?$`)
+
+// parseNumber 精确解析十进制数字。
+func parseNumber(s string) (*big.Rat, bool) {
+	if !decimalNumber.MatchString(s) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+func boolNumber(b bool) *big.Rat {
+	if b {
+		return big.NewRat(1, 1)
+	}
+	return new(big.Rat)
+}
+
+// truth 是SQL的三值逻辑结果，truthUnknown 表示不是常量。
+type truth int
+
+const (
+	truthUnknown truth = iota
+	truthTrue
+	truthFalse
+	truthNull
+)
+
+func boolTruth(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+func (t truth) not() truth {
+	switch t {
+	case truthTrue:
+		return truthFalse
+	case truthFalse:
+		return truthTrue
+	}
+	return t
+}
+
+func (t truth) constant() constant {
+	if t == truthNull {
+		return constant{kind: constNull}
+	}
+	return constant{kind: constBool, truth: t == truthTrue}
+}
+
+type folder struct {
+	mysql bool
+	found []*ConstantCondition
+}
+
+// condition 检查一个条件，不是常量时继续检查 AND、OR、NOT 的操作数。
+func (f *folder) condition(node ASTNode) {
+	if isNilNode(node) {
+		return
+	}
+	value, reason, ok := f.fold(node)
+	if t := f.truth(value); ok && t != truthUnknown {
+		if reason == "" { // 字面量或算术表达式直接作为条件
+			reason = fmt.Sprintf("constant %s used as a condition", value)
+		}
+		if t == truthNull {
+			reason += ", the condition is always NULL"
+		}
+		f.found = append(f.found, &ConstantCondition{Node: node, Value: t == truthTrue, Reason: reason})
+		return
+	}
+	switch n := node.(type) {
+	case *BinaryExpr:
+		if n.Operator == AND || n.Operator == OR {
+			f.condition(n.Left)
+			f.condition(n.Right)
+		}
+	case *UnaryExpr:
+		if n.Operator == NOT {
+			f.condition(n.Operand)
+		}
+	}
+}
+
+// fold 计算表达式的常量值，ok为false表示表达式不是常量。
+// 对于条件表达式 reason 说明值的由来，字面量和算术表达式的 reason 为空。
+func (f *folder) fold(node ASTNode) (value constant, reason string, ok bool) {
+	switch n := node.(type) {
+	case *NumberLiteral:
+		number, ok := parseNumber(n.Value)
+		return constant{kind: constNumber, number: number}, "", ok
+	case *StringLiteral:
+		return constant{kind: constString, text: n.Value}, "", true
+	case *NullLiteral:
+		return constant{kind: constNull}, "", true
+	case *BooleanLiteral:
+		if f.mysql { // MySQL 中 TRUE 和 FALSE 就是 1 和 0
+			return constant{kind: constNumber, number: boolNumber(n.Value)}, "", true
+		}
+		return constant{kind: constBool, truth: n.Value}, "", true
+	case *UnaryExpr:
+		return f.foldUnary(n)
+	case *BinaryExpr:
+		switch n.Operator {
+		case AND, OR:
+			return f.foldLogical(n)
+		case PLUS, MINUS, MULTIPLY, DIVIDE:
+			return f.foldArithmetic(n)
+		}
+		return f.foldComparison(n)
+	case *IsNullExpr:
+		operand, _, ok := f.fold(n.Operand)
+		if !ok {
+			return constant{}, "", false
+		}
+		return boolTruth((operand.kind == constNull) != n.Not).constant(), "IS NULL check on a constant", true
+	case *BetweenExpr:
+		return f.foldBetween(n)
+	case *InExpr:
+		return f.foldIn(n)
+	case *LikeExpr:
+		return f.foldLike(n)
+	}
+	return constant{}, "", false
+}
+
+func (f *folder) foldUnary(n *UnaryExpr) (constant, string, bool) {
+	operand, reason, ok := f.fold(n.Operand)
+	if !ok {
+		return constant{}, "", false
+	}
+	if n.Operator == NOT {
+		t := f.truth(operand)
+		if t == truthUnknown {
+			return constant{}, "", false
+		}
+		if reason == "" {
+			reason = fmt.Sprintf("constant %s used as a condition", operand)
+		}
+		return t.not().constant(), "NOT of a constant condition: " + reason, true
+	}
+	if operand.kind == constNull {
+		return operand, "", true
+	}
+	number, _, ok := f.number(operand)
+	if !ok {
+		return constant{}, "", false
+	}
+	if n.Operator == MINUS {
+		number = new(big.Rat).Neg(number)
+	}
+	return constant{kind: constNumber, number: number}, "", true
+}
+
+// foldLogical 按三值逻辑折叠 AND 和 OR。任一操作数恒假时 AND 恒假，任一操作数恒真时 OR 恒真，
+// 即使另一个操作数不是常量。
+func (f *folder) foldLogical(n *BinaryExpr) (constant, string, bool) {
+	left, _, leftOK := f.fold(n.Left)
+	right, _, rightOK := f.fold(n.Right)
+	l, r := truthUnknown, truthUnknown
+	if leftOK {
+		l = f.truth(left)
+	}
+	if rightOK {
+		r = f.truth(right)
+	}
+
+	absorbing, word := truthFalse, "false"
+	if n.Operator == OR {
+		absorbing, word = truthTrue, "true"
+	}
+	if l == absorbing {
+		return absorbing.constant(), fmt.Sprintf("%s operand %s is always %s", n.Operator, n.Left, word), true
+	}
+	if r == absorbing {
+		return absorbing.constant(), fmt.Sprintf("%s operand %s is always %s", n.Operator, n.Right, word), true
+	}
+	if l == truthUnknown || r == truthUnknown {
+		return constant{}, "", false
+	}
+	// 两侧都不是吸收值：都为另一个布尔值时结果为该值，否则为NULL
+	result := absorbing.not()
+	if l == truthNull || r == truthNull {
+		result = truthNull
+	}
+	return result.constant(), fmt.Sprintf("both operands of %s are constant", n.Operator), true
+}
+
+func (f *folder) foldArithmetic(n *BinaryExpr) (constant, string, bool) {
+	left, _, leftOK := f.fold(n.Left)
+	right, _, rightOK := f.fold(n.Right)
+	if !leftOK || !rightOK {
+		return constant{}, "", false
+	}
+	if left.kind == constNull || right.kind == constNull {
+		return constant{kind: constNull}, "", true
+	}
+	l, _, leftOK := f.number(left)
+	r, _, rightOK := f.number(right)
+	if !leftOK || !rightOK {
+		return constant{}, "", false
+	}
+	result := new(big.Rat)
+	switch n.Operator {
+	case PLUS:
+		result.Add(l, r)
+	case MINUS:
+		result.Sub(l, r)
+	case MULTIPLY:
+		result.Mul(l, r)
+	case DIVIDE:
+		if r.Sign() == 0 {
+			if f.mysql { // MySQL 中除以0的结果为NULL
+				return constant{kind: constNull}, "", true
+			}
+			return constant{}, "", false
+		}
+		result.Quo(l, r)
+	}
+	return constant{kind: constNumber, number: result}, "", true
+}
+
+// foldComparison 只折叠常量之间的比较。列与自身比较（如 `id = id`）不是常量：
+// 列值为NULL时结果为NULL，而且条件中的同名列可能来自不同的表。
+func (f *folder) foldComparison(n *BinaryExpr) (constant, string, bool) {
+	left, _, leftOK := f.fold(n.Left)
+	right, _, rightOK := f.fold(n.Right)
+	// 与NULL比较的结果总是NULL，不论另一侧是什么
+	if leftOK && left.kind == constNull || rightOK && right.kind == constNull {
+		return constant{kind: constNull}, "comparison with NULL", true
+	}
+	if !leftOK || !rightOK {
+		return constant{}, "", false
+	}
+	cmp, notes, ok := f.compare(left, right)
+	if !ok {
+		return constant{}, "", false
+	}
+	var result bool
+	switch n.Operator {
+	case EQUALS:
+		result = cmp == 0
+	case NOT_EQUALS:
+		result = cmp != 0
+	case LESS_THAN:
+		result = cmp < 0
+	case GREATER_THAN:
+		result = cmp > 0
+	case LESS_EQUALS:
+		result = cmp <= 0
+	case GREATER_EQUALS:
+		result = cmp >= 0
+	default:
+		return constant{}, "", false
+	}
+	return boolTruth(result).constant(), withNotes("compares constants", notes), true
+}
+
+func (f *folder) foldBetween(n *BetweenExpr) (constant, string, bool) {
+	operand, _, ok := f.fold(n.Operand)
+	lower, _, lowerOK := f.fold(n.LowerBound)
+	upper, _, upperOK := f.fold(n.UpperBound)
+	if !ok || !lowerOK || !upperOK {
+		return constant{}, "", false
+	}
+	if operand.kind == constNull || lower.kind == constNull || upper.kind == constNull {
+		return constant{kind: constNull}, "comparison with NULL", true
+	}
+	low, lowNotes, lowOK := f.compare(operand, lower)
+	high, highNotes, highOK := f.compare(operand, upper)
+	if !lowOK || !highOK {
+		return constant{}, "", false
+	}
+	result := boolTruth((low >= 0 && high <= 0) != n.Not)
+	return result.constant(), withNotes("BETWEEN on constants", append(lowNotes, highNotes...)), true
+}
+
+func (f *folder) foldIn(n *InExpr) (constant, string, bool) {
+	value, _, ok := f.fold(n.Value)
+	if !ok || n.Subquery != nil {
+		return constant{}, "", false
+	}
+	if value.kind == constNull {
+		return constant{kind: constNull}, "IN on NULL", true
+	}
+	result, allConstant := truthFalse, true
+	for _, item := range n.Values {
+		c, _, ok := f.fold(item)
+		if !ok {
+			allConstant = false
+			continue
+		}
+		if c.kind == constNull {
+			result = truthNull
+			continue
+		}
+		cmp, notes, ok := f.compare(value, c)
+		if !ok {
+			allConstant = false
+		} else if cmp == 0 {
+			// 找到相等的常量时，其余的值不影响结果
+			if n.Not {
+				return constant{kind: constBool}, withNotes(fmt.Sprintf("%s is in the list", value), notes), true
+			}
+			return truthTrue.constant(), withNotes(fmt.Sprintf("%s is in the list", value), notes), true
+		}
+	}
+	if !allConstant {
+		return constant{}, "", false
+	}
+	if n.Not {
+		result = result.not()
+	}
+	return result.constant(), "IN on constants", true
+}
+
+func (f *folder) foldLike(n *LikeExpr) (constant, string, bool) {
+	pattern, _, ok := f.fold(n.Pattern)
+	if !ok {
+		return constant{}, "", false
+	}
+	if pattern.kind == constNull {
+		return constant{kind: constNull}, "comparison with NULL", true
+	}
+	patternText, ok := f.text(pattern)
+	if !ok {
+		return constant{}, "", false
+	}
+
+	value, _, ok := f.fold(n.Value)
+	if !ok {
+		// 只由%组成的模式匹配所有非NULL的值
+		if n.Operator == LIKE && patternText != "" && strings.Trim(patternText, "%") == "" {
+			return boolTruth(!n.Not).constant(), fmt.Sprintf("pattern %s matches every non-NULL value", pattern), true
+		}
+		return constant{}, "", false
+	}
+	if value.kind == constNull {
+		return constant{kind: constNull}, "comparison with NULL", true
+	}
+	valueText, ok := f.text(value)
+	if !ok {
+		return constant{}, "", false
+	}
+
+	var expr string
+	if n.Operator == LIKE {
+		escape := '\\'
+		if n.Escape != nil {
+			c, _, ok := f.fold(n.Escape)
+			if !ok || c.kind != constString || len([]rune(c.text)) != 1 {
+				return constant{}, "", false
+			}
+			escape = []rune(c.text)[0]
+		}
+		expr = likeToRegexp(patternText, escape)
+	} else {
+		expr = patternText
+	}
+	if f.mysql {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return constant{}, "", false
+	}
+	return boolTruth(re.MatchString(valueText) != n.Not).constant(), fmt.Sprintf("%s on constants", n.Operator), true
+}
+
+// likeToRegexp 把LIKE模式转换为等价的正则表达式。
+func likeToRegexp(pattern string, escape rune) string {
+	var b strings.Builder
+	b.WriteString("^(?s)")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == escape:
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// truth 返回常量作为条件时的值。MySQL 中非零数字为真，字符串先转换为数字。
+func (f *folder) truth(c constant) truth {
+	switch c.kind {
+	case constNull:
+		return truthNull
+	case constBool:
+		return boolTruth(c.truth)
+	}
+	if !f.mysql {
+		return truthUnknown
+	}
+	number, _, ok := f.number(c)
+	if !ok {
+		return truthUnknown
+	}
+	return boolTruth(number.Sign() != 0)
+}
+
+// compare 比较两个非NULL常量，返回值的符号表示大小关系，notes 记录发生的隐式转换。
+func (f *folder) compare(a, b constant) (int, []string, bool) {
+	if a.kind == constString && b.kind == constString {
+		x, y := a.text, b.text
+		if f.mysql { // 默认排序规则不区分大小写，并忽略末尾空格
+			x, y = strings.ToLower(strings.TrimRight(x, " ")), strings.ToLower(strings.TrimRight(y, " "))
+		}
+		return strings.Compare(x, y), nil, true
+	}
+	if !f.mysql && a.kind != b.kind {
+		return 0, nil, false
+	}
+	if a.kind == constBool && b.kind == constBool {
+		return boolNumber(a.truth).Cmp(boolNumber(b.truth)), nil, true
+	}
+	x, xNote, xOK := f.number(a)
+	y, yNote, yOK := f.number(b)
+	if !xOK || !yOK {
+		return 0, nil, false
+	}
+	var notes []string
+	for _, note := range []string{xNote, yNote} {
+		if note != "" {
+			notes = append(notes, note)
+		}
+	}
+	return x.Cmp(y), notes, true
+}
+
+// number 把常量转换为数字，note 说明字符串的转换结果。
+// MySQL 取字符串开头最长的数字前缀，如 '1abc' 为 1，'abc' 为 0。
+func (f *folder) number(c constant) (*big.Rat, string, bool) {
+	switch c.kind {
+	case constNumber:
+		return c.number, "", true
+	case constBool:
+		return boolNumber(c.truth), "", true
+	case constString:
+		if !f.mysql {
+			return nil, "", false
+		}
+		number, ok := numericPrefix(c.text)
+		if !ok {
+			return nil, "", false
+		}
+		return number, fmt.Sprintf("%s is converted to %s", c, formatNumber(number)), true
+	}
+	return nil, "", false
+}
+
+// text 把常量转换为字符串，用于LIKE和REGEXP。
+func (f *folder) text(c constant) (string, bool) {
+	switch c.kind {
+	case constString:
+		return c.text, true
+	case constNumber:
+		return formatNumber(c.number), f.mysql
+	}
+	return "", false
+}
+
+// numericPrefix 按MySQL的规则解析字符串开头的数字，指数过大时 ok 为false。
+func numericPrefix(s string) (number *big.Rat, ok bool) {
+	s = strings.TrimLeft(s, " \t\n\r")
+	end, digits := 0, false
+	if end < len(s) && (s[end] == '+' || s[end] == '-') {
+		end++
+	}
+	for end < len(s) && unicode.IsDigit(rune(s[end])) {
+		end, digits = end+1, true
+	}
+	if end < len(s) && s[end] == '.' {
+		end++
+		for end < len(s) && unicode.IsDigit(rune(s[end])) {
+			end, digits = end+1, true
+		}
+	}
+	if !digits {
+		return new(big.Rat), true
+	}
+	if end < len(s) && (s[end] == 'e' || s[end] == 'E') {
+		exp := end + 1
+		if exp < len(s) && (s[exp] == '+' || s[exp] == '-') {
+			exp++
+		}
+		if exp < len(s) && unicode.IsDigit(rune(s[exp])) {
+			for exp < len(s) && unicode.IsDigit(rune(s[exp])) {
+				exp++
+			}
+			end = exp
+		}
+	}
+	negative := s[0] == '-'
+	number, ok = parseNumber(strings.TrimSuffix(strings.TrimLeft(s[:end], "+-"), "."))
+	if ok && negative {
+		number.Neg(number)
+	}
+	return number, ok
+}
+
+func withNotes(reason string, notes []string) string {
+	if len(notes) == 0 {
+		return reason
+	}
+	return reason + " (" + strings.Join(notes, ", ") + ")"
+}
+
+func TestFindConstantConditions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // 节点 => 值: 原因，多个结果以 | 分隔
+	}{
+		{"SELECT * FROM users WHERE id = 1", ""},
+		{"SELECT * FROM users WHERE 1 = 1", "1 = 1 => true: compares constants"},
+		{"SELECT * FROM users WHERE id = 1 OR 1 = 1", "id = 1 OR 1 = 1 => true: OR operand 1 = 1 is always true"},
+		{"SELECT * FROM users WHERE name = 'x' OR 'a' = 'a'", "name = 'x' OR 'a' = 'a' => true: OR operand 'a' = 'a' is always true"},
+		{"SELECT * FROM users WHERE id = 1 AND 2 > 1", "2 > 1 => true: compares constants"},
+		{"SELECT * FROM users WHERE id = 1 OR 1", "id = 1 OR 1 => true: OR operand 1 is always true"},
+		{"SELECT * FROM users WHERE '1' LIKE '1'", "'1' LIKE '1' => true: LIKE on constants"},
+		{"SELECT * FROM users WHERE id = 1 AND 1 = 2", "id = 1 AND 1 = 2 => false: AND operand 1 = 2 is always false"},
+		{"SELECT * FROM users WHERE '1abc' = 1", "'1abc' = 1 => true: compares constants ('1abc' is converted to 1)"},
+		{"SELECT * FROM users WHERE 'abc' = 0", "'abc' = 0 => true: compares constants ('abc' is converted to 0)"},
+		{"SELECT * FROM users WHERE 'A' = 'a '", "'A' = 'a ' => true: compares constants"},
+		{"SELECT * FROM users WHERE NOT 1 = 2", "NOT 1 = 2 => true: NOT of a constant condition: compares constants"},
+		{"SELECT * FROM users WHERE id = id", ""},
+		{"SELECT * FROM users u JOIN orders o ON id = id WHERE 1 = 1", "1 = 1 => true: compares constants"},
+		{"SELECT * FROM users WHERE 0.1 + 0.2 = 0.3", "0.1 + 0.2 = 0.3 => true: compares constants"},
+		{"SELECT * FROM users WHERE 9999999999999999999 = 9999999999999999998", "9999999999999999999 = 9999999999999999998 => false: compares constants"},
+		{"SELECT * FROM users WHERE '0.30abc' = 0.1 + 0.2", "'0.30abc' = 0.1 + 0.2 => true: compares constants ('0.30abc' is converted to 0.3)"},
+		{"SELECT * FROM users WHERE 10 / 4 = 2.5", "10 / 4 = 2.5 => true: compares constants"},
+		{"SELECT * FROM users WHERE 1e999 * 1e999 > 0", "1e999 * 1e999 > 0 => true: compares constants"},
+		{"SELECT * FROM users WHERE name LIKE '%'", "name LIKE '%' => true: pattern '%' matches every non-NULL value"},
+		{"SELECT * FROM users WHERE 3 BETWEEN 1 AND 5", "3 BETWEEN 1 AND 5 => true: BETWEEN on constants"},
+		{"SELECT * FROM users WHERE 1 IN (id, 1)", "1 IN (id, 1) => true: 1 is in the list"},
+		{"SELECT * FROM users WHERE NULL IS NULL", "NULL IS NULL => true: IS NULL check on a constant"},
+		{"SELECT * FROM users WHERE id = NULL", "id = NULL => false: comparison with NULL, the condition is always NULL"},
+		{"SELECT * FROM users WHERE 1 / 0", "1 / 0 => false: constant NULL used as a condition, the condition is always NULL"},
+		{"SELECT * FROM users u JOIN orders o ON 1 = 1 WHERE 2 - 1", "1 = 1 => true: compares constants|2 - 1 => true: constant 1 used as a condition"},
+		{"SELECT * FROM users WHERE id IN (SELECT id FROM admins WHERE 1 = 1)", "1 = 1 => true: compares constants"},
+		{"SELECT name FROM users GROUP BY name HAVING 1 = 1", "1 = 1 => true: compares constants"},
+		{"DELETE FROM users WHERE TRUE", "TRUE => true: constant 1 used as a condition"},
+	}
+
+	for _, tt := range tests {
+		stmt, errs := parseSQL(tt.input)
+		if len(errs) > 0 {
+			t.Errorf("For input %q, unexpected errors %v", tt.input, errs)
+			continue
+		}
+		var got []string
+		for _, c := range FindConstantConditions(stmt, FoldOptions{}) {
+			got = append(got, fmt.Sprintf("%s => %t: %s", c.Node, c.Value, c.Reason))
+		}
+		if strings.Join(got, "|") != tt.expected {
+			t.Errorf("For input %q, expected %q but got %q", tt.input, tt.expected, strings.Join(got, "|"))
+		}
+	}
+
+	// 非MySQL方言不做字符串与数字之间的隐式转换
+	stmt, _ := parseSQL("SELECT * FROM users WHERE '1' = 1 OR 1")
+	if got := FindConstantConditions(stmt, FoldOptions{Dialect: DIALECT_POSTGRESQL}); len(got) != 0 {
+		t.Errorf("Expected no constant conditions for PostgreSQL, got %v", got)
+	}
+	stmt, _ = parseSQL("SELECT * FROM users WHERE 'a' = 'A' OR TRUE")
+	got := FindConstantConditions(stmt, FoldOptions{Dialect: DIALECT_POSTGRESQL})
+	if len(got) != 1 || !got[0].Value || got[0].Reason != "OR operand TRUE is always true" {
+		t.Errorf("Unexpected PostgreSQL result %v", got)
+	}
+}
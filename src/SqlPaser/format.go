@@ -0,0 +1,842 @@
+package SqlPaser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// KeywordCase 控制格式化输出中关键字的大小写。
+type KeywordCase int
+
+const (
+	KEYWORD_UPPER KeywordCase = iota
+	KEYWORD_LOWER
+)
+
+// Dialect 控制格式化输出中标识符的引用方式。
+type Dialect int
+
+const (
+	DIALECT_MYSQL      Dialect = iota // `name`
+	DIALECT_POSTGRESQL                // "name"
+	DIALECT_SQLSERVER                 // [name]
+	DIALECT_ORACLE                    // "name"
+	DIALECT_SQLITE                    // "name"
+)
+
+var dialectNames = []string{"mysql", "postgresql", "sqlserver", "oracle", "sqlite"}
+
+func (d Dialect) String() string {
+	if d >= 0 && int(d) < len(dialectNames) {
+		return dialectNames[d]
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+func (d Dialect) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Dialect) UnmarshalText(text []byte) error {
+	for i, name := range dialectNames {
+		if strings.EqualFold(string(text), name) {
+			*d = Dialect(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown dialect %q", text)
+}
+
+// Options 是 Format 的格式化选项，零值输出大写关键字、MySQL引用风格的单行SQL。
+type Options struct {
+	KeywordCase KeywordCase
+	Indent      string // 为空时输出单行SQL，否则每个子句换行，子查询按Indent缩进。
+	Dialect     Dialect
+}
+
+// Format 把AST重新生成为规范化的SQL文本。
+// 标识符只在必要时加引号；SqlPaser的词法分析器按MySQL规则把双引号读作字符串，
+// 因此只有DIALECT_MYSQL的输出可以重新解析为相同的AST。
+func Format(node ASTNode, opts Options) string {
+	f := &formatter{opts: opts}
+	return f.format(node)
+}
+
+// formatter 保存格式化过程中的选项和当前缩进深度。
+type formatter struct {
+	opts  Options
+	depth int
+}
+
+// 表达式的优先级，数值越大结合越紧，用来决定何时需要加括号。
+const (
+	precedenceOr = iota + 1
+	precedenceAnd
+	precedenceNot
+	precedenceComparison
+	precedenceAdditive
+	precedenceMultiplicative
+	precedenceUnary
+	precedencePrimary
+)
+
+// binaryOperators 是二元运算符的输出文本和优先级。
+var binaryOperators = map[TokenType]struct {
+	text       string
+	precedence int
+}{
+	OR:             {"OR", precedenceOr},
+	AND:            {"AND", precedenceAnd},
+	EQUALS:         {"=", precedenceComparison},
+	NOT_EQUALS:     {"<>", precedenceComparison},
+	LESS_THAN:      {"<", precedenceComparison},
+	GREATER_THAN:   {">", precedenceComparison},
+	LESS_EQUALS:    {"<=", precedenceComparison},
+	GREATER_EQUALS: {">=", precedenceComparison},
+	PLUS:           {"+", precedenceAdditive},
+	MINUS:          {"-", precedenceAdditive},
+	MULTIPLY:       {"*", precedenceMultiplicative},
+	DIVIDE:         {"/", precedenceMultiplicative},
+}
+
+// kw 按选项转换关键字的大小写。
+func (f *formatter) kw(keyword string) string {
+	if f.opts.KeywordCase == KEYWORD_LOWER {
+		return strings.ToLower(keyword)
+	}
+	return keyword
+}
+
+// clause 返回子句之间的分隔符，单行模式下是空格。
+func (f *formatter) clause() string {
+	if f.opts.Indent == "" {
+		return " "
+	}
+	return "\n" + strings.Repeat(f.opts.Indent, f.depth)
+}
+
+// nested 格式化括号内的子查询，多行模式下子查询缩进一级。
+func (f *formatter) nested(stmt *SelectStatement) string {
+	if f.opts.Indent == "" {
+		return "(" + f.format(stmt) + ")"
+	}
+	f.depth++
+	body := f.clause() + f.format(stmt)
+	f.depth--
+	return "(" + body + f.clause() + ")"
+}
+
+// ident 格式化可能带有表名前缀的标识符，各部分在必要时分别加引号。
+func (f *formatter) ident(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if needsQuoting(part) {
+			parts[i] = f.quote(part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// quote 按方言给标识符加引号。
+func (f *formatter) quote(name string) string {
+	switch f.opts.Dialect {
+	case DIALECT_POSTGRESQL, DIALECT_ORACLE, DIALECT_SQLITE:
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	case DIALECT_SQLSERVER:
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	default:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+}
+
+// needsQuoting 判断标识符是否是关键字或包含需要引用的字符。
+func needsQuoting(name string) bool {
+	if name == "" {
+		return true
+	}
+	if _, ok := keywords[strings.ToUpper(name)]; ok {
+		return true
+	}
+	if strings.HasPrefix(name, "@") {
+		// 变量名不能加引号，@@version 加引号后就成了标识符
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "@"), "@")
+		if name == "" {
+			return true
+		}
+	}
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		isLetter := ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+		if !isLetter && !(i > 0 && ch >= '0' && ch <= '9') {
+			return true
+		}
+	}
+	return false
+}
+
+// names 格式化以逗号分隔的标识符列表。
+func (f *formatter) names(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = f.ident(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// list 格式化以逗号分隔的表达式列表。
+func (f *formatter) list(nodes []ASTNode) string {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		parts[i] = f.format(node)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stringLiteral 给字符串加单引号并转义，与词法分析器的转义规则对应。
+func stringLiteral(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// precedence 返回表达式节点的优先级。
+func precedence(node ASTNode) int {
+	switch n := node.(type) {
+	case *BinaryExpr:
+		if operator, ok := binaryOperators[n.Operator]; ok {
+			return operator.precedence
+		}
+	case *UnaryExpr:
+		if n.Operator == NOT {
+			return precedenceNot
+		}
+		return precedenceUnary
+	case *BetweenExpr, *InExpr, *LikeExpr, *IsNullExpr:
+		return precedenceComparison
+	}
+	return precedencePrimary
+}
+
+// operand 格式化子表达式，优先级低于minPrecedence时加括号。
+func (f *formatter) operand(node ASTNode, minPrecedence int) string {
+	if precedence(node) < minPrecedence {
+		return "(" + f.format(node) + ")"
+	}
+	return f.format(node)
+}
+
+// not 在谓词被否定时返回 "NOT "。
+func (f *formatter) not(not bool) string {
+	if not {
+		return f.kw("NOT") + " "
+	}
+	return ""
+}
+
+func (f *formatter) format(node ASTNode) string {
+	if node == nil || (reflect.ValueOf(node).Kind() == reflect.Ptr && reflect.ValueOf(node).IsNil()) {
+		return ""
+	}
+
+	switch n := node.(type) {
+	case *Script:
+		statements := make([]string, len(n.Statements))
+		for i, stmt := range n.Statements {
+			statements[i] = f.format(stmt)
+		}
+		return strings.Join(statements, ";\n")
+	case *ScriptStatement:
+		if n.Node == nil {
+			return n.Text
+		}
+		return f.format(n.Node)
+	case *SelectStatement:
+		return f.formatSelect(n)
+	case *SetOperation:
+		return f.formatSetOperation(n)
+	case *InsertStatement:
+		sql := f.kw("INSERT INTO") + " " + f.ident(n.TableName)
+		if len(n.Columns) > 0 {
+			sql += " (" + f.names(n.Columns) + ")"
+		}
+		if len(n.Values) > 0 {
+			rows := make([]string, len(n.Values))
+			for i, row := range n.Values {
+				rows[i] = "(" + f.list(row) + ")"
+			}
+			sql += f.clause() + f.kw("VALUES") + " " + strings.Join(rows, ", ")
+		}
+		if n.SelectStatement != nil {
+			sql += f.clause() + f.format(n.SelectStatement)
+		}
+		return sql
+	case *UpdateStatement:
+		updates := make([]string, len(n.Updates))
+		for i, update := range n.Updates {
+			updates[i] = f.format(update)
+		}
+		sql := f.kw("UPDATE") + " " + f.ident(n.TableName) + f.clause() + f.kw("SET") + " " + strings.Join(updates, ", ")
+		if n.Where != nil {
+			sql += f.clause() + f.format(n.Where)
+		}
+		return sql
+	case *UpdateExpression:
+		return f.ident(n.Column) + " = " + f.format(n.Value)
+	case *DeleteStatement:
+		sql := f.kw("DELETE FROM") + " " + f.ident(n.TableName)
+		if n.Where != nil {
+			sql += f.clause() + f.format(n.Where)
+		}
+		return sql
+	case *CreateTableStatement:
+		return f.formatCreateTable(n)
+	case *ColumnDefinition:
+		return f.formatColumnDefinition(n)
+	case *TableConstraint:
+		return f.formatTableConstraint(n)
+	case *ForeignKeyReference:
+		sql := f.kw("REFERENCES") + " " + f.ident(n.TableName)
+		if len(n.Columns) > 0 {
+			sql += " (" + f.names(n.Columns) + ")"
+		}
+		if n.OnDelete != "" {
+			sql += " " + f.kw("ON DELETE "+n.OnDelete)
+		}
+		if n.OnUpdate != "" {
+			sql += " " + f.kw("ON UPDATE "+n.OnUpdate)
+		}
+		return sql
+	case *DropTableStatement:
+		sql := f.kw("DROP TABLE") + " "
+		if n.IfExists {
+			sql += f.kw("IF EXISTS") + " "
+		}
+		sql += f.ident(n.TableName)
+		if n.Cascade {
+			sql += " " + f.kw("CASCADE")
+		}
+		return sql
+	case *CreateIndexStatement:
+		sql := f.kw("CREATE") + " "
+		if n.IsUnique {
+			sql += f.kw("UNIQUE") + " "
+		}
+		sql += f.kw("INDEX") + " "
+		if n.IfNotExists {
+			sql += f.kw("IF NOT EXISTS") + " "
+		}
+		return sql + f.ident(n.IndexName) + " " + f.kw("ON") + " " + f.ident(n.TableName) + " (" + f.names(n.Columns) + ")"
+	case *DropIndexStatement:
+		sql := f.kw("DROP INDEX") + " "
+		if n.IfExists {
+			sql += f.kw("IF EXISTS") + " "
+		}
+		sql += f.ident(n.IndexName)
+		if n.TableName != "" {
+			sql += " " + f.kw("ON") + " " + f.ident(n.TableName)
+		}
+		return sql
+	case *AlterTableStatement:
+		actions := make([]string, len(n.Actions))
+		for i, action := range n.Actions {
+			actions[i] = f.format(action)
+		}
+		return f.kw("ALTER TABLE") + " " + f.ident(n.TableName) + " " + strings.Join(actions, ", ")
+	case *AlterAction:
+		return f.formatAlterAction(n)
+	case *TransactionStatement:
+		switch n.Type {
+		case COMMIT_TRANSACTION:
+			return f.kw("COMMIT")
+		case ROLLBACK_TRANSACTION:
+			return f.kw("ROLLBACK")
+		default:
+			return f.kw("BEGIN")
+		}
+	case *WithClause:
+		ctes := make([]string, len(n.CTEs))
+		for i, cte := range n.CTEs {
+			ctes[i] = f.format(cte)
+		}
+		sql := f.kw("WITH") + " "
+		if n.Recursive {
+			sql += f.kw("RECURSIVE") + " "
+		}
+		return sql + strings.Join(ctes, ", ")
+	case *CommonTableExpression:
+		sql := f.ident(n.Name)
+		if len(n.Columns) > 0 {
+			sql += " (" + f.names(n.Columns) + ")"
+		}
+		return sql + " " + f.kw("AS") + " " + f.nested(n.Select)
+	case *FromClause:
+		sql := f.kw("FROM") + " "
+		if n.Derived != nil {
+			sql += f.format(n.Derived)
+		} else {
+			sql += f.ident(n.TableName)
+			if n.Alias != "" {
+				sql += " " + f.kw("AS") + " " + f.ident(n.Alias)
+			}
+		}
+		for _, join := range n.Joins {
+			if join.Type == COMMA {
+				sql += ", " + f.format(join)
+			} else {
+				sql += f.clause() + f.format(join)
+			}
+		}
+		return sql
+	case *JoinClause:
+		return f.formatJoin(n)
+	case *NestedSubQuery:
+		sql := f.nested(n.Select)
+		if n.Alias != "" {
+			sql += " " + f.kw("AS") + " " + f.ident(n.Alias)
+		}
+		return sql
+	case *WhereClause:
+		return f.kw("WHERE") + " " + f.format(n.Condition)
+	case *GroupByClause:
+		return f.kw("GROUP BY") + " " + f.list(n.Columns)
+	case *HavingClause:
+		return f.kw("HAVING") + " " + f.format(n.Condition)
+	case *OrderByClause:
+		columns := make([]string, len(n.Columns))
+		for i, column := range n.Columns {
+			columns[i] = f.format(column)
+		}
+		return f.kw("ORDER BY") + " " + strings.Join(columns, ", ")
+	case *OrderByExpression:
+		switch n.Direction {
+		case ASC:
+			return f.format(n.Column) + " " + f.kw("ASC")
+		case DESC:
+			return f.format(n.Column) + " " + f.kw("DESC")
+		}
+		return f.format(n.Column)
+	case *LimitClause:
+		sql := f.kw("LIMIT") + " " + f.format(n.Count)
+		if n.Offset != nil {
+			sql += " " + f.kw("OFFSET") + " " + f.format(n.Offset)
+		}
+		return sql
+	case *AliasClause:
+		return f.ident(n.Alias)
+	case *Identifier:
+		return f.ident(n.Name)
+	case *ColumnName:
+		if n.Table != "" {
+			return f.ident(n.Table) + "." + f.ident(n.Name)
+		}
+		return f.ident(n.Name)
+	case *NumberLiteral:
+		return n.Value
+	case *StringLiteral:
+		return stringLiteral(n.Value)
+	case *BooleanLiteral:
+		if n.Value {
+			return f.kw("TRUE")
+		}
+		return f.kw("FALSE")
+	case *NullLiteral:
+		return f.kw("NULL")
+	case *Placeholder:
+		return "?"
+	case *LiteralValue:
+		if n.Type == STRING {
+			return stringLiteral(n.Value)
+		}
+		return n.Value
+	case *Star:
+		return "*"
+	case *AliasedExpression:
+		return f.format(n.Expr) + " " + f.kw("AS") + " " + f.ident(n.Alias)
+	case *FunctionCall:
+		args := f.list(n.Args)
+		if n.Distinct {
+			args = f.kw("DISTINCT") + " " + args
+		}
+		return n.Name + "(" + args + ")"
+	case *Subquery:
+		return f.nested(n.Statement)
+	case *SubQuery:
+		return f.nested(n.Select)
+	case *BinaryExpr:
+		operator, ok := binaryOperators[n.Operator]
+		if !ok {
+			return f.format(n.Left) + " ? " + f.format(n.Right)
+		}
+		// 左结合：右侧同优先级的表达式也需要括号
+		return f.operand(n.Left, operator.precedence) + " " + f.kw(operator.text) + " " + f.operand(n.Right, operator.precedence+1)
+	case *UnaryExpr:
+		if n.Operator == NOT {
+			return f.kw("NOT") + " " + f.operand(n.Operand, precedenceNot)
+		}
+		sign := "-"
+		if n.Operator == PLUS {
+			sign = "+"
+		}
+		operand := f.operand(n.Operand, precedenceUnary)
+		if strings.HasPrefix(operand, sign) { // 避免 --1 被当作注释
+			operand = "(" + operand + ")"
+		}
+		return sign + operand
+	case *BetweenExpr:
+		return f.operand(n.Operand, precedenceComparison) + " " + f.not(n.Not) + f.kw("BETWEEN") + " " +
+			f.operand(n.LowerBound, precedenceAdditive) + " " + f.kw("AND") + " " + f.operand(n.UpperBound, precedenceAdditive)
+	case *InExpr:
+		sql := f.operand(n.Value, precedenceComparison) + " " + f.not(n.Not) + f.kw("IN") + " "
+		if n.Subquery != nil {
+			return sql + f.format(n.Subquery)
+		}
+		return sql + "(" + f.list(n.Values) + ")"
+	case *LikeExpr:
+		operator := "LIKE"
+		if n.Operator == REGEXP {
+			operator = "REGEXP"
+		}
+		sql := f.operand(n.Value, precedenceComparison) + " " + f.not(n.Not) + f.kw(operator) + " " + f.operand(n.Pattern, precedenceAdditive)
+		if n.Escape != nil {
+			sql += " " + f.kw("ESCAPE") + " " + f.operand(n.Escape, precedenceAdditive)
+		}
+		return sql
+	case *IsNullExpr:
+		if n.Not {
+			return f.operand(n.Operand, precedenceComparison) + " " + f.kw("IS NOT NULL")
+		}
+		return f.operand(n.Operand, precedenceComparison) + " " + f.kw("IS NULL")
+	case *ExistsExpr:
+		return f.kw("EXISTS") + " " + f.format(n.Subquery)
+	case *CaseExpr:
+		return f.formatCase(n)
+	case *CaseBranch:
+		return f.kw("WHEN") + " " + f.format(n.Condition) + " " + f.kw("THEN") + " " + f.format(n.Result)
+	default:
+		return fmt.Sprintf("/* unsupported %T */", n)
+	}
+}
+
+func (f *formatter) formatSelect(n *SelectStatement) string {
+	sql := ""
+	if n.With != nil {
+		sql += f.format(n.With) + f.clause()
+	}
+	sql += f.kw("SELECT") + " "
+	if n.Distinct {
+		sql += f.kw("DISTINCT") + " "
+	}
+	sql += f.list(n.Columns)
+
+	for _, clause := range []ASTNode{n.From, n.Where, n.GroupBy, n.Having} {
+		if text := f.format(clause); text != "" {
+			sql += f.clause() + text
+		}
+	}
+	for _, op := range n.SetOperations {
+		sql += f.clause() + f.format(op)
+	}
+	for _, clause := range []ASTNode{n.OrderBy, n.Limit} {
+		if text := f.format(clause); text != "" {
+			sql += f.clause() + text
+		}
+	}
+	return sql
+}
+
+// formatSetOperation 格式化 UNION 等集合运算。右侧的查询自身带有 WITH、ORDER BY、LIMIT
+// 或集合运算时加括号，否则这些子句会被当作整个合并结果的子句。
+func (f *formatter) formatSetOperation(n *SetOperation) string {
+	sql := f.kw(n.Operator.String())
+	if n.All {
+		sql += " " + f.kw("ALL")
+	}
+	if s := n.Select; s != nil && (s.With != nil || s.OrderBy != nil || s.Limit != nil || len(s.SetOperations) > 0) {
+		return sql + " " + f.nested(s)
+	}
+	return sql + f.clause() + f.format(n.Select)
+}
+
+func (f *formatter) formatJoin(n *JoinClause) string {
+	sql := ""
+	if n.Type != COMMA {
+		if n.Natural {
+			sql += f.kw("NATURAL") + " "
+		}
+		switch n.Type {
+		case LEFT:
+			sql += f.kw("LEFT JOIN")
+		case RIGHT:
+			sql += f.kw("RIGHT JOIN")
+		case FULL:
+			sql += f.kw("FULL JOIN")
+		case CROSS:
+			sql += f.kw("CROSS JOIN")
+		default:
+			if n.Natural {
+				sql += f.kw("JOIN")
+			} else {
+				sql += f.kw("INNER JOIN")
+			}
+		}
+		sql += " "
+	}
+
+	if n.Derived != nil {
+		sql += f.format(n.Derived)
+	} else {
+		sql += f.format(n.Table)
+		if n.Alias != nil {
+			sql += " " + f.kw("AS") + " " + f.format(n.Alias)
+		}
+	}
+
+	if n.On != nil {
+		sql += " " + f.kw("ON") + " " + f.format(n.On.Condition)
+	} else if len(n.Using) > 0 {
+		sql += " " + f.kw("USING") + " (" + f.names(n.Using) + ")"
+	}
+	return sql
+}
+
+func (f *formatter) formatCase(n *CaseExpr) string {
+	if n.IfFunction && len(n.Branches) == 1 {
+		branch := n.Branches[0]
+		return "IF(" + f.format(branch.Condition) + ", " + f.format(branch.Result) + ", " + f.format(n.Default) + ")"
+	}
+	sql := f.kw("CASE")
+	if n.Expr != nil {
+		sql += " " + f.format(n.Expr)
+	}
+	for _, branch := range n.Branches {
+		sql += " " + f.format(branch)
+	}
+	if n.Default != nil {
+		sql += " " + f.kw("ELSE") + " " + f.format(n.Default)
+	}
+	return sql + " " + f.kw("END")
+}
+
+func (f *formatter) formatCreateTable(n *CreateTableStatement) string {
+	sql := f.kw("CREATE") + " "
+	if n.Temporary {
+		sql += f.kw("TEMPORARY") + " "
+	}
+	sql += f.kw("TABLE") + " "
+	if n.IfNotExists {
+		sql += f.kw("IF NOT EXISTS") + " "
+	}
+	sql += f.ident(n.TableName) + " ("
+
+	var definitions []string
+	for _, column := range n.Columns {
+		definitions = append(definitions, f.format(column))
+	}
+	for _, constraint := range n.Constraints {
+		definitions = append(definitions, f.format(constraint))
+	}
+	if f.opts.Indent == "" {
+		return sql + strings.Join(definitions, ", ") + ")"
+	}
+	f.depth++
+	separator := f.clause()
+	f.depth--
+	return sql + separator + strings.Join(definitions, ","+separator) + f.clause() + ")"
+}
+
+func (f *formatter) formatColumnDefinition(n *ColumnDefinition) string {
+	sql := f.ident(n.Name)
+	if n.Type != "" {
+		sql += " " + n.Type
+	}
+	if n.NotNull {
+		sql += " " + f.kw("NOT NULL")
+	}
+	if n.Default != nil {
+		sql += " " + f.kw("DEFAULT") + " " + f.operand(n.Default, precedenceAdditive)
+	}
+	if n.PrimaryKey {
+		sql += " " + f.kw("PRIMARY KEY")
+	}
+	if n.Unique {
+		sql += " " + f.kw("UNIQUE")
+	}
+	if n.AutoIncrement {
+		sql += " " + f.kw("AUTO_INCREMENT")
+	}
+	if n.Check != nil {
+		sql += " " + f.kw("CHECK") + " (" + f.format(n.Check) + ")"
+	}
+	if n.References != nil {
+		sql += " " + f.format(n.References)
+	}
+	return sql
+}
+
+func (f *formatter) formatTableConstraint(n *TableConstraint) string {
+	sql := ""
+	if n.Name != "" && n.Type != INDEX {
+		sql += f.kw("CONSTRAINT") + " " + f.ident(n.Name) + " "
+	}
+	switch n.Type {
+	case PRIMARY:
+		sql += f.kw("PRIMARY KEY")
+	case UNIQUE:
+		sql += f.kw("UNIQUE")
+	case FOREIGN:
+		sql += f.kw("FOREIGN KEY")
+	case CHECK:
+		return sql + f.kw("CHECK") + " (" + f.format(n.Check) + ")"
+	case INDEX:
+		sql += f.kw("INDEX")
+		if n.Name != "" {
+			sql += " " + f.ident(n.Name)
+		}
+	}
+	sql += " (" + f.names(n.Columns) + ")"
+	if n.References != nil {
+		sql += " " + f.format(n.References)
+	}
+	return sql
+}
+
+func (f *formatter) formatAlterAction(n *AlterAction) string {
+	position := ""
+	if n.Position == 1 {
+		position = " " + f.kw("FIRST")
+	} else if n.After != "" {
+		position = " " + f.kw("AFTER") + " " + f.ident(n.After)
+	}
+
+	switch n.Type {
+	case ADD_COLUMN:
+		return f.kw("ADD COLUMN") + " " + f.format(n.Column) + position
+	case DROP_COLUMN:
+		return f.kw("DROP COLUMN") + " " + f.ident(n.Column.Name)
+	case MODIFY_COLUMN:
+		return f.kw("MODIFY COLUMN") + " " + f.format(n.Column) + position
+	case RENAME_COLUMN:
+		return f.kw("RENAME COLUMN") + " " + f.ident(n.Column.Name) + " " + f.kw("TO") + " " + f.ident(n.NewName)
+	case RENAME_TABLE:
+		return f.kw("RENAME TO") + " " + f.ident(n.NewName)
+	}
+	return fmt.Sprintf("/* unsupported action %d */", n.Type)
+}
+
+// String 方法以默认选项格式化节点，便于调试和日志输出。
+
+func (n *AliasClause) String() string           { return Format(n, Options{}) }
+func (n *FromClause) String() string            { return Format(n, Options{}) }
+func (n *JoinClause) String() string            { return Format(n, Options{}) }
+func (n *WhereClause) String() string           { return Format(n, Options{}) }
+func (n *GroupByClause) String() string         { return Format(n, Options{}) }
+func (n *HavingClause) String() string          { return Format(n, Options{}) }
+func (n *OrderByClause) String() string         { return Format(n, Options{}) }
+func (n *OrderByExpression) String() string     { return Format(n, Options{}) }
+func (n *LimitClause) String() string           { return Format(n, Options{}) }
+func (n *SelectStatement) String() string       { return Format(n, Options{}) }
+func (n *SetOperation) String() string          { return Format(n, Options{}) }
+func (n *InsertStatement) String() string       { return Format(n, Options{}) }
+func (n *UpdateStatement) String() string       { return Format(n, Options{}) }
+func (n *UpdateExpression) String() string      { return Format(n, Options{}) }
+func (n *DeleteStatement) String() string       { return Format(n, Options{}) }
+func (n *CreateTableStatement) String() string  { return Format(n, Options{}) }
+func (n *ColumnDefinition) String() string      { return Format(n, Options{}) }
+func (n *TableConstraint) String() string       { return Format(n, Options{}) }
+func (n *ForeignKeyReference) String() string   { return Format(n, Options{}) }
+func (n *DropTableStatement) String() string    { return Format(n, Options{}) }
+func (n *CreateIndexStatement) String() string  { return Format(n, Options{}) }
+func (n *DropIndexStatement) String() string    { return Format(n, Options{}) }
+func (n *NumberLiteral) String() string         { return Format(n, Options{}) }
+func (n *StringLiteral) String() string         { return Format(n, Options{}) }
+func (n *BooleanLiteral) String() string        { return Format(n, Options{}) }
+func (n *Subquery) String() string              { return Format(n, Options{}) }
+func (n *NullLiteral) String() string           { return Format(n, Options{}) }
+func (n *Placeholder) String() string           { return Format(n, Options{}) }
+func (n *Identifier) String() string            { return Format(n, Options{}) }
+func (n *BinaryExpr) String() string            { return Format(n, Options{}) }
+func (n *UnaryExpr) String() string             { return Format(n, Options{}) }
+func (n *LiteralValue) String() string          { return Format(n, Options{}) }
+func (n *ColumnName) String() string            { return Format(n, Options{}) }
+func (n *AliasedExpression) String() string     { return Format(n, Options{}) }
+func (n *FunctionCall) String() string          { return Format(n, Options{}) }
+func (n *Star) String() string                  { return Format(n, Options{}) }
+func (n *SubQuery) String() string              { return Format(n, Options{}) }
+func (n *BetweenExpr) String() string           { return Format(n, Options{}) }
+func (n *InExpr) String() string                { return Format(n, Options{}) }
+func (n *LikeExpr) String() string              { return Format(n, Options{}) }
+func (n *IsNullExpr) String() string            { return Format(n, Options{}) }
+func (n *ExistsExpr) String() string            { return Format(n, Options{}) }
+func (n *CaseExpr) String() string              { return Format(n, Options{}) }
+func (n *CaseBranch) String() string            { return Format(n, Options{}) }
+func (n *AlterTableStatement) String() string   { return Format(n, Options{}) }
+func (n *AlterAction) String() string           { return Format(n, Options{}) }
+func (n *TransactionStatement) String() string  { return Format(n, Options{}) }
+func (n *WithClause) String() string            { return Format(n, Options{}) }
+func (n *CommonTableExpression) String() string { return Format(n, Options{}) }
+func (n *NestedSubQuery) String() string        { return Format(n, Options{}) }
+func (n *Script) String() string                { return Format(n, Options{}) }
+func (n *ScriptStatement) String() string       { return Format(n, Options{}) }
+
+// formatCorpus 是格式化往返测试使用的语句。
+var formatCorpus = []string{
+	"SELECT name FROM users WHERE id = 42",
+	"SELECT @@version, @@global.port, @id",
+	"SELECT DISTINCT u.id AS user_id, COUNT(DISTINCT o.id) AS orders FROM users u LEFT JOIN orders o ON u.id = o.user_id GROUP BY u.id HAVING COUNT(o.id) > 1 ORDER BY u.id DESC, name LIMIT 10 OFFSET 5",
+	"SELECT * FROM t WHERE (a = 1 OR b = 2) AND NOT c <> 3 - (4 - 5) * -6",
+	"SELECT a FROM t WHERE id NOT IN (1, 2, 3) AND name LIKE 'a\\_%' ESCAPE '\\\\' OR x IS NOT NULL",
+	"SELECT a FROM t WHERE name NOT RLIKE '^r' AND id BETWEEN 1 AND 2 + 3 AND EXISTS (SELECT 1 FROM u WHERE u.id = t.id)",
+	"SELECT CASE WHEN (SUBSTRING(user(),1,1)='r') THEN SLEEP(5) ELSE 0 END",
+	"SELECT a FROM t WHERE 1 = IF(ASCII(SUBSTR(database(),1,1)) > 100, SLEEP(3), 0)",
+	"SELECT CASE x WHEN 1 THEN 'it''s' WHEN 2 THEN CASE WHEN y IS NULL THEN 'n' END END AS z FROM `select`",
+	"WITH RECURSIVE cte (n) AS (SELECT 1), b AS (SELECT x FROM y) SELECT a FROM (SELECT id FROM users) AS t, orders o CROSS JOIN z NATURAL LEFT JOIN w JOIN v USING (id, k)",
+	"SELECT a FROM t WHERE id IN (SELECT id FROM admins WHERE `db`.`order`.id = 1)",
+	"SELECT a FROM t WHERE id = 1 UNION ALL SELECT user, password FROM mysql.user ORDER BY a LIMIT 5",
+	"WITH RECURSIVE r (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM r WHERE n < 10) SELECT n FROM r",
+	"SELECT a FROM t INTERSECT SELECT a FROM u EXCEPT (SELECT a FROM v ORDER BY a LIMIT 1)",
+	"SELECT a FROM t WHERE id IN (SELECT id FROM x UNION SELECT id FROM y)",
+	"INSERT INTO target (a, b) VALUES (1, 'x'), (2, 'y')",
+	"INSERT INTO target (a) SELECT id FROM users WHERE active = 1",
+	"UPDATE users SET name = 'x', age = age + 1 WHERE id = 1",
+	"DELETE FROM users WHERE id = 1",
+	"DROP TABLE IF EXISTS users CASCADE",
+	"CREATE TABLE IF NOT EXISTS products (id INT PRIMARY KEY AUTO_INCREMENT, name VARCHAR(255) NOT NULL DEFAULT 'x', price DECIMAL(10,2) CHECK (price > 0), owner_id INT REFERENCES users(id) ON DELETE CASCADE, CONSTRAINT fk_o FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE SET NULL, UNIQUE (name), INDEX idx_p (price))",
+	"CREATE UNIQUE INDEX idx_email ON users (email)",
+	"DROP INDEX idx_email ON users",
+	"ALTER TABLE users ADD COLUMN age INT DEFAULT -1 AFTER name, DROP COLUMN tmp, MODIFY email VARCHAR(100) NOT NULL FIRST, RENAME COLUMN a TO b, RENAME TO people",
+}
+
+// clearPositions 把树中所有节点的范围清零。
+func clearPositions(node ASTNode) {
+	Inspect(node, func(n ASTNode) bool {
+		if n != nil {
+			n.(positioned).setSpan(0, 0)
+		}
+		return true
+	})
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	optionSets := []Options{
+		{},
+		{KeywordCase: KEYWORD_LOWER, Indent: "  "},
+	}
+	for _, input := range formatCorpus {
+		expected, errs := parseSQL(input)
+		if len(errs) > 0 {
+			t.Errorf("For input %q, unexpected errors: %v", input, errs)
+			continue
+		}
+		for _, opts := range optionSets {
+			formatted := Format(expected, opts)
+			got, errs := parseSQL(formatted)
+			// 格式化后令牌的位置会变化，只比较结构
+			clearPositions(expected)
+			clearPositions(got)
+			if len(errs) > 0 || !reflect.DeepEqual(expected, got) {
+				t.Errorf("Round trip of %q changed the AST, formatted as %q (errors: %v)", input, formatted, errs)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//go:build ignore
+
+// gen_tokens 读取 token.go 中的 TokenType 常量，生成名称表 token_string.go。
+// 修改令牌类型后在本目录运行 go generate。
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "token.go", nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 常量块中第一个常量声明为 TokenType，其余依次使用 iota
+	var names []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST || len(gen.Specs) == 0 {
+			continue
+		}
+		first := gen.Specs[0].(*ast.ValueSpec)
+		if ident, ok := first.Type.(*ast.Ident); !ok || ident.Name != "TokenType" {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				names = append(names, name.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		log.Fatal("no TokenType constants found in token.go")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by \"go run gen_tokens.go\"; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package SqlPaser")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// tokenTypeNames 是每个令牌类型的常量名。")
+	fmt.Fprintln(&buf, "var tokenTypeNames = [...]string{")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%s: %q,\n", name, name)
+	}
+	fmt.Fprintln(&buf, "}")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// tokenTypesByName 根据常量名查找令牌类型。")
+	fmt.Fprintln(&buf, "var tokenTypesByName = map[string]TokenType{")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%q: %s,\n", name, name)
+	}
+	fmt.Fprintln(&buf, "}")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("token_string.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
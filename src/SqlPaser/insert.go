@@ -1,26 +1,15 @@
 package SqlPaser
 
-import (
-	"fmt"
-	"testing"
-)
+import "testing"
 
 func (p *Parser) parseInsertStatement() *InsertStatement {
 	stmt := &InsertStatement{}
 
-	// 检查并消费 INSERT 关键字
-	p.expect(INSERT)
-
-	// 检查并消费 INTO 关键字
-	p.expect(INTO)
+	// INSERT 已由调用者消费，MySQL 中 INTO 可以省略
+	p.match(INTO)
 
 	// 获取并消费表名
-	tableNameToken := p.expect(IDENTIFIER)
-	if tableNameToken.Type != IDENTIFIER {
-		// 抛出错误或进行其他错误处理
-		panic(fmt.Sprintf("Expected IDENTIFIER for table name, got %s", tableNameToken.Type))
-	}
-	stmt.TableName = tableNameToken.Value
+	stmt.TableName = p.expect(IDENTIFIER).Value
 
 	// 检查是否有列名列表
 	if p.match(LEFT_PAREN) {
@@ -45,14 +34,15 @@ func (p *Parser) parseInsertStatement() *InsertStatement {
 			var valuesRow []ASTNode
 			for {
 				valueToken := p.peek()
-				if valueToken.Type == STRING || valueToken.Type == NUMBER {
-					valuesRow = append(valuesRow, &LiteralValue{Type: valueToken.Type, Value: valueToken.Value})
+				next := p.peekAt(1).Type
+				if (valueToken.Type == STRING || valueToken.Type == NUMBER) && (next == COMMA || next == RIGHT_PAREN) {
+					value := &LiteralValue{Type: valueToken.Type, Value: valueToken.Value}
 					p.advance()
+					p.finish(value, valueToken.Pos)
+					valuesRow = append(valuesRow, value)
 				} else {
-					// TODO: 这里可以处理更复杂的值类型，如函数调用、子查询等。
-					// 当前，我们只处理基本的字符串和数字。
-					// 抛出错误或其他处理。
-					panic("Unexpected token type in VALUES")
+					// 函数调用、子查询、占位符等更复杂的值按表达式解析
+					valuesRow = append(valuesRow, p.parseExpression())
 				}
 
 				if !p.match(COMMA) {
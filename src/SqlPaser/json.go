@@ -0,0 +1,416 @@
+package SqlPaser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// AST 的JSON格式：每个节点是一个对象，"type" 字段为节点的类型名，如 "BinaryExpr"，
+// "pos" 和 "end" 为节点在输入中的范围，其余字段为结构体的导出字段，字段名首字母小写，
+// 与 "type" 冲突的 Type 字段（如 JoinClause.Type）写作 "kind"。
+// 空字符串、false、nil 和空列表会被省略，令牌类型等枚举以名称表示。
+
+func (t TokenType) MarshalText() ([]byte, error) {
+	if t < 0 || int(t) >= len(tokenTypeNames) {
+		return nil, fmt.Errorf("unknown token type %d", int(t))
+	}
+	return []byte(t.String()), nil
+}
+
+func (t *TokenType) UnmarshalText(text []byte) error {
+	tokenType, ok := LookupTokenType(string(text))
+	if !ok {
+		return fmt.Errorf("unknown token type %q", text)
+	}
+	*t = tokenType
+	return nil
+}
+
+var alterActionTypeNames = map[AlterActionType]string{
+	ADD_COLUMN:    "ADD_COLUMN",
+	DROP_COLUMN:   "DROP_COLUMN",
+	MODIFY_COLUMN: "MODIFY_COLUMN",
+	RENAME_COLUMN: "RENAME_COLUMN",
+	RENAME_TABLE:  "RENAME_TABLE",
+}
+
+func (t AlterActionType) MarshalText() ([]byte, error) {
+	name, ok := alterActionTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown alter action type %d", int(t))
+	}
+	return []byte(name), nil
+}
+
+func (t *AlterActionType) UnmarshalText(text []byte) error {
+	for actionType, name := range alterActionTypeNames {
+		if name == string(text) {
+			*t = actionType
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown alter action type %q", text)
+}
+
+var transactionTypeNames = map[TransactionType]string{
+	BEGIN_TRANSACTION:    "BEGIN_TRANSACTION",
+	COMMIT_TRANSACTION:   "COMMIT_TRANSACTION",
+	ROLLBACK_TRANSACTION: "ROLLBACK_TRANSACTION",
+}
+
+func (t TransactionType) MarshalText() ([]byte, error) {
+	name, ok := transactionTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction type %d", int(t))
+	}
+	return []byte(name), nil
+}
+
+func (t *TransactionType) UnmarshalText(text []byte) error {
+	for transactionType, name := range transactionTypeNames {
+		if name == string(text) {
+			*t = transactionType
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown transaction type %q", text)
+}
+
+// nodeTypes 按类型名索引所有节点类型，用于根据 "type" 字段创建节点。
+var nodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []ASTNode{
+		&AliasClause{},
+		&FromClause{},
+		&JoinClause{},
+		&WhereClause{},
+		&GroupByClause{},
+		&HavingClause{},
+		&OrderByClause{},
+		&OrderByExpression{},
+		&LimitClause{},
+		&SelectStatement{},
+		&SetOperation{},
+		&InsertStatement{},
+		&UpdateStatement{},
+		&UpdateExpression{},
+		&DeleteStatement{},
+		&CreateTableStatement{},
+		&ColumnDefinition{},
+		&TableConstraint{},
+		&ForeignKeyReference{},
+		&DropTableStatement{},
+		&CreateIndexStatement{},
+		&DropIndexStatement{},
+		&NumberLiteral{},
+		&StringLiteral{},
+		&BooleanLiteral{},
+		&Subquery{},
+		&NullLiteral{},
+		&Placeholder{},
+		&Identifier{},
+		&BinaryExpr{},
+		&UnaryExpr{},
+		&LiteralValue{},
+		&ColumnName{},
+		&AliasedExpression{},
+		&FunctionCall{},
+		&Star{},
+		&SubQuery{},
+		&BetweenExpr{},
+		&InExpr{},
+		&LikeExpr{},
+		&IsNullExpr{},
+		&ExistsExpr{},
+		&CaseExpr{},
+		&CaseBranch{},
+		&AlterTableStatement{},
+		&AlterAction{},
+		&TransactionStatement{},
+		&WithClause{},
+		&CommonTableExpression{},
+		&NestedSubQuery{},
+		&Script{},
+		&ScriptStatement{},
+	} {
+		nodeType := reflect.TypeOf(node).Elem()
+		nodeTypes[nodeType.Name()] = nodeType
+	}
+}
+
+var nodeInterface = reflect.TypeOf((*ASTNode)(nil)).Elem()
+
+// UnmarshalNode 根据 "type" 字段把JSON解码为对应类型的节点，null 解码为nil。
+func UnmarshalNode(data []byte) (ASTNode, error) {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	nodeType, ok := nodeTypes[header.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown node type %q", header.Type)
+	}
+	node := reflect.New(nodeType).Interface().(ASTNode)
+	if err := json.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// fieldKey 返回字段在JSON中的名称，如 TableName 为 tableName。
+func fieldKey(name string) string {
+	if name == "Type" {
+		return "kind"
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// isEmptyField 判断字段是否在JSON中省略。
+func isEmptyField(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil() || (value.Kind() == reflect.Interface && isNilNode(value.Interface().(ASTNode)))
+	case reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	}
+	return false
+}
+
+// marshalNode 把节点编码为带 "type" 字段的JSON对象。
+func marshalNode(node ASTNode) ([]byte, error) {
+	value := reflect.ValueOf(node).Elem()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"type":%q,"pos":%d,"end":%d`, value.Type().Name(), node.Pos(), node.End())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() || isEmptyField(value.Field(i)) {
+			continue
+		}
+		data, err := json.Marshal(value.Field(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", value.Type().Name(), field.Name, err)
+		}
+		fmt.Fprintf(&buf, ",%q:", fieldKey(field.Name))
+		buf.Write(data)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// unmarshalNode 把JSON对象解码到节点中，"type" 字段必须与节点类型一致。
+func unmarshalNode(data []byte, node ASTNode) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	value := reflect.ValueOf(node).Elem()
+	var typeName string
+	if err := json.Unmarshal(fields["type"], &typeName); err != nil || typeName != value.Type().Name() {
+		return fmt.Errorf("expected %s node, got type %s", value.Type().Name(), fields["type"])
+	}
+
+	var pos, end int
+	if raw, ok := fields["pos"]; ok {
+		if err := json.Unmarshal(raw, &pos); err != nil {
+			return err
+		}
+	}
+	if raw, ok := fields["end"]; ok {
+		if err := json.Unmarshal(raw, &end); err != nil {
+			return err
+		}
+	}
+	node.(positioned).setSpan(pos, end)
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		raw, ok := fields[fieldKey(field.Name)]
+		if !field.IsExported() || !ok {
+			continue
+		}
+		if err := decodeValue(raw, value.Field(i)); err != nil {
+			return fmt.Errorf("%s.%s: %w", value.Type().Name(), field.Name, err)
+		}
+	}
+	return nil
+}
+
+// decodeValue 解码一个字段，ASTNode 接口及其列表根据 "type" 字段创建具体节点。
+func decodeValue(raw json.RawMessage, target reflect.Value) error {
+	switch {
+	case target.Type() == nodeInterface:
+		node, err := UnmarshalNode(raw)
+		if err != nil {
+			return err
+		}
+		if node != nil {
+			target.Set(reflect.ValueOf(node))
+		}
+		return nil
+	case target.Kind() == reflect.Slice && containsNodeInterface(target.Type().Elem()):
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+		return nil
+	}
+	return json.Unmarshal(raw, target.Addr().Interface())
+}
+
+// containsNodeInterface 判断类型是否是 ASTNode 或由 ASTNode 组成的（多维）列表。
+func containsNodeInterface(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		return containsNodeInterface(t.Elem())
+	}
+	return t == nodeInterface
+}
+
+func (n *AliasClause) MarshalJSON() ([]byte, error)           { return marshalNode(n) }
+func (n *FromClause) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *JoinClause) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *WhereClause) MarshalJSON() ([]byte, error)           { return marshalNode(n) }
+func (n *GroupByClause) MarshalJSON() ([]byte, error)         { return marshalNode(n) }
+func (n *HavingClause) MarshalJSON() ([]byte, error)          { return marshalNode(n) }
+func (n *OrderByClause) MarshalJSON() ([]byte, error)         { return marshalNode(n) }
+func (n *OrderByExpression) MarshalJSON() ([]byte, error)     { return marshalNode(n) }
+func (n *LimitClause) MarshalJSON() ([]byte, error)           { return marshalNode(n) }
+func (n *SelectStatement) MarshalJSON() ([]byte, error)       { return marshalNode(n) }
+func (n *SetOperation) MarshalJSON() ([]byte, error)          { return marshalNode(n) }
+func (n *InsertStatement) MarshalJSON() ([]byte, error)       { return marshalNode(n) }
+func (n *UpdateStatement) MarshalJSON() ([]byte, error)       { return marshalNode(n) }
+func (n *UpdateExpression) MarshalJSON() ([]byte, error)      { return marshalNode(n) }
+func (n *DeleteStatement) MarshalJSON() ([]byte, error)       { return marshalNode(n) }
+func (n *CreateTableStatement) MarshalJSON() ([]byte, error)  { return marshalNode(n) }
+func (n *ColumnDefinition) MarshalJSON() ([]byte, error)      { return marshalNode(n) }
+func (n *TableConstraint) MarshalJSON() ([]byte, error)       { return marshalNode(n) }
+func (n *ForeignKeyReference) MarshalJSON() ([]byte, error)   { return marshalNode(n) }
+func (n *DropTableStatement) MarshalJSON() ([]byte, error)    { return marshalNode(n) }
+func (n *CreateIndexStatement) MarshalJSON() ([]byte, error)  { return marshalNode(n) }
+func (n *DropIndexStatement) MarshalJSON() ([]byte, error)    { return marshalNode(n) }
+func (n *NumberLiteral) MarshalJSON() ([]byte, error)         { return marshalNode(n) }
+func (n *StringLiteral) MarshalJSON() ([]byte, error)         { return marshalNode(n) }
+func (n *BooleanLiteral) MarshalJSON() ([]byte, error)        { return marshalNode(n) }
+func (n *Subquery) MarshalJSON() ([]byte, error)              { return marshalNode(n) }
+func (n *NullLiteral) MarshalJSON() ([]byte, error)           { return marshalNode(n) }
+func (n *Placeholder) MarshalJSON() ([]byte, error)           { return marshalNode(n) }
+func (n *Identifier) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *BinaryExpr) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *UnaryExpr) MarshalJSON() ([]byte, error)             { return marshalNode(n) }
+func (n *LiteralValue) MarshalJSON() ([]byte, error)          { return marshalNode(n) }
+func (n *ColumnName) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *AliasedExpression) MarshalJSON() ([]byte, error)     { return marshalNode(n) }
+func (n *FunctionCall) MarshalJSON() ([]byte, error)          { return marshalNode(n) }
+func (n *Star) MarshalJSON() ([]byte, error)                  { return marshalNode(n) }
+func (n *SubQuery) MarshalJSON() ([]byte, error)              { return marshalNode(n) }
+func (n *BetweenExpr) MarshalJSON() ([]byte, error)           { return marshalNode(n) }
+func (n *InExpr) MarshalJSON() ([]byte, error)                { return marshalNode(n) }
+func (n *LikeExpr) MarshalJSON() ([]byte, error)              { return marshalNode(n) }
+func (n *IsNullExpr) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *ExistsExpr) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *CaseExpr) MarshalJSON() ([]byte, error)              { return marshalNode(n) }
+func (n *CaseBranch) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *AlterTableStatement) MarshalJSON() ([]byte, error)   { return marshalNode(n) }
+func (n *AlterAction) MarshalJSON() ([]byte, error)           { return marshalNode(n) }
+func (n *TransactionStatement) MarshalJSON() ([]byte, error)  { return marshalNode(n) }
+func (n *WithClause) MarshalJSON() ([]byte, error)            { return marshalNode(n) }
+func (n *CommonTableExpression) MarshalJSON() ([]byte, error) { return marshalNode(n) }
+func (n *NestedSubQuery) MarshalJSON() ([]byte, error)        { return marshalNode(n) }
+func (n *Script) MarshalJSON() ([]byte, error)                { return marshalNode(n) }
+func (n *ScriptStatement) MarshalJSON() ([]byte, error)       { return marshalNode(n) }
+
+func (n *AliasClause) UnmarshalJSON(data []byte) error           { return unmarshalNode(data, n) }
+func (n *FromClause) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *JoinClause) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *WhereClause) UnmarshalJSON(data []byte) error           { return unmarshalNode(data, n) }
+func (n *GroupByClause) UnmarshalJSON(data []byte) error         { return unmarshalNode(data, n) }
+func (n *HavingClause) UnmarshalJSON(data []byte) error          { return unmarshalNode(data, n) }
+func (n *OrderByClause) UnmarshalJSON(data []byte) error         { return unmarshalNode(data, n) }
+func (n *OrderByExpression) UnmarshalJSON(data []byte) error     { return unmarshalNode(data, n) }
+func (n *LimitClause) UnmarshalJSON(data []byte) error           { return unmarshalNode(data, n) }
+func (n *SelectStatement) UnmarshalJSON(data []byte) error       { return unmarshalNode(data, n) }
+func (n *SetOperation) UnmarshalJSON(data []byte) error          { return unmarshalNode(data, n) }
+func (n *InsertStatement) UnmarshalJSON(data []byte) error       { return unmarshalNode(data, n) }
+func (n *UpdateStatement) UnmarshalJSON(data []byte) error       { return unmarshalNode(data, n) }
+func (n *UpdateExpression) UnmarshalJSON(data []byte) error      { return unmarshalNode(data, n) }
+func (n *DeleteStatement) UnmarshalJSON(data []byte) error       { return unmarshalNode(data, n) }
+func (n *CreateTableStatement) UnmarshalJSON(data []byte) error  { return unmarshalNode(data, n) }
+func (n *ColumnDefinition) UnmarshalJSON(data []byte) error      { return unmarshalNode(data, n) }
+func (n *TableConstraint) UnmarshalJSON(data []byte) error       { return unmarshalNode(data, n) }
+func (n *ForeignKeyReference) UnmarshalJSON(data []byte) error   { return unmarshalNode(data, n) }
+func (n *DropTableStatement) UnmarshalJSON(data []byte) error    { return unmarshalNode(data, n) }
+func (n *CreateIndexStatement) UnmarshalJSON(data []byte) error  { return unmarshalNode(data, n) }
+func (n *DropIndexStatement) UnmarshalJSON(data []byte) error    { return unmarshalNode(data, n) }
+func (n *NumberLiteral) UnmarshalJSON(data []byte) error         { return unmarshalNode(data, n) }
+func (n *StringLiteral) UnmarshalJSON(data []byte) error         { return unmarshalNode(data, n) }
+func (n *BooleanLiteral) UnmarshalJSON(data []byte) error        { return unmarshalNode(data, n) }
+func (n *Subquery) UnmarshalJSON(data []byte) error              { return unmarshalNode(data, n) }
+func (n *NullLiteral) UnmarshalJSON(data []byte) error           { return unmarshalNode(data, n) }
+func (n *Placeholder) UnmarshalJSON(data []byte) error           { return unmarshalNode(data, n) }
+func (n *Identifier) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *BinaryExpr) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *UnaryExpr) UnmarshalJSON(data []byte) error             { return unmarshalNode(data, n) }
+func (n *LiteralValue) UnmarshalJSON(data []byte) error          { return unmarshalNode(data, n) }
+func (n *ColumnName) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *AliasedExpression) UnmarshalJSON(data []byte) error     { return unmarshalNode(data, n) }
+func (n *FunctionCall) UnmarshalJSON(data []byte) error          { return unmarshalNode(data, n) }
+func (n *Star) UnmarshalJSON(data []byte) error                  { return unmarshalNode(data, n) }
+func (n *SubQuery) UnmarshalJSON(data []byte) error              { return unmarshalNode(data, n) }
+func (n *BetweenExpr) UnmarshalJSON(data []byte) error           { return unmarshalNode(data, n) }
+func (n *InExpr) UnmarshalJSON(data []byte) error                { return unmarshalNode(data, n) }
+func (n *LikeExpr) UnmarshalJSON(data []byte) error              { return unmarshalNode(data, n) }
+func (n *IsNullExpr) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *ExistsExpr) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *CaseExpr) UnmarshalJSON(data []byte) error              { return unmarshalNode(data, n) }
+func (n *CaseBranch) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *AlterTableStatement) UnmarshalJSON(data []byte) error   { return unmarshalNode(data, n) }
+func (n *AlterAction) UnmarshalJSON(data []byte) error           { return unmarshalNode(data, n) }
+func (n *TransactionStatement) UnmarshalJSON(data []byte) error  { return unmarshalNode(data, n) }
+func (n *WithClause) UnmarshalJSON(data []byte) error            { return unmarshalNode(data, n) }
+func (n *CommonTableExpression) UnmarshalJSON(data []byte) error { return unmarshalNode(data, n) }
+func (n *NestedSubQuery) UnmarshalJSON(data []byte) error        { return unmarshalNode(data, n) }
+func (n *Script) UnmarshalJSON(data []byte) error                { return unmarshalNode(data, n) }
+func (n *ScriptStatement) UnmarshalJSON(data []byte) error       { return unmarshalNode(data, n) }
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, input := range formatCorpus {
+		expected, _ := parseSQL(input)
+		data, err := json.Marshal(expected)
+		if err != nil {
+			t.Errorf("For input %q, marshal failed: %v", input, err)
+			continue
+		}
+		got, err := UnmarshalNode(data)
+		if err != nil {
+			t.Errorf("For input %q, unmarshal failed: %v", input, err)
+			continue
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Round trip of %q changed the AST, JSON: %s", input, data)
+		}
+	}
+
+	stmt, _ := parseSQL("SELECT a FROM t WHERE id <> 1")
+	data, _ := json.Marshal(stmt)
+	if !strings.Contains(string(data), `{"type":"BinaryExpr","pos":22,"end":29,"left":{"type":"Identifier","pos":22,"end":24,"name":"id"},"operator":"NOT_EQUALS"`) {
+		t.Errorf("Unexpected JSON for BinaryExpr: %s", data)
+	}
+}
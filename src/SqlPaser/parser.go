@@ -1,5 +1,10 @@
 package SqlPaser
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Parser 结构用于解析令牌数组。
 type Parser struct {
 	tokens   []Token
@@ -21,26 +26,43 @@ func (p *Parser) next() {
 
 func (p *Parser) currentToken() Token {
 	if p.current >= len(p.tokens) {
-		return Token{EOF, ""}
+		return p.eof()
 	}
 	return p.tokens[p.current]
 }
 
 func (p *Parser) getPreviousToken() Token {
 	if p.current-1 < 0 {
-		return Token{EOF, ""}
+		return Token{Type: EOF}
 	}
 	return p.tokens[p.current-1]
 }
 
+// eof 返回位于最后一个令牌之后的EOF令牌，使节点的结束位置在输入末尾也有意义。
+func (p *Parser) eof() Token {
+	if len(p.tokens) == 0 {
+		return Token{Type: EOF}
+	}
+	end := p.tokens[len(p.tokens)-1].End
+	return Token{Type: EOF, Pos: end, End: end}
+}
+
 // peek 查看当前令牌，但不消费它。
 func (p *Parser) peek() Token {
 	if p.current >= len(p.tokens) {
-		return Token{EOF, ""}
+		return p.eof()
 	}
 	return p.tokens[p.current]
 }
 
+// peekAt 查看当前位置之后第offset个令牌，但不消费它。
+func (p *Parser) peekAt(offset int) Token {
+	if p.current+offset >= len(p.tokens) {
+		return p.eof()
+	}
+	return p.tokens[p.current+offset]
+}
+
 // advance 消费当前令牌并前进到下一个。
 func (p *Parser) advance() Token {
 	if p.current < len(p.tokens) {
@@ -60,8 +82,19 @@ func (p *Parser) match(types ...TokenType) bool {
 	return false
 }
 
-// expect 检查当前令牌是否匹配给定的令牌类型，并消费它。
-func (p *Parser) expect(t TokenType) *Token {
+// matchWord 检查当前令牌是否是给定的非保留字（如 MODIFY、AUTO_INCREMENT），是则消费它。
+// 这些词没有单独的令牌类型，词法分析器会把它们识别为标识符或函数名。
+func (p *Parser) matchWord(word string) bool {
+	token := p.peek()
+	if (token.Type == IDENTIFIER || token.Type == FUNCTION) && strings.EqualFold(token.Value, word) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// accept 在当前令牌匹配给定的令牌类型时消费并返回它，否则返回nil。
+func (p *Parser) accept(t TokenType) *Token {
 	if p.peek().Type == t {
 		token := p.advance()
 		return &token
@@ -69,33 +102,106 @@ func (p *Parser) expect(t TokenType) *Token {
 	return nil
 }
 
+// expect 消费给定类型的令牌。当前令牌不匹配时记录错误，不消费令牌，
+// 返回位于当前位置、值为空的占位令牌，调用者不需要检查nil。
+func (p *Parser) expect(t TokenType) *Token {
+	if token := p.accept(t); token != nil {
+		return token
+	}
+	token := p.peek()
+	p.errorf("expected %s, got %q", t, token.Value)
+	return &Token{Type: t, Pos: token.Pos, End: token.Pos}
+}
+
+// errorf 记录一条语法错误，解析会继续进行。
+func (p *Parser) errorf(format string, args ...interface{}) {
+	p.errors = append(p.errors, fmt.Sprintf(format, args...))
+}
+
+// Errors 返回解析过程中记录的语法错误。
+func (p *Parser) Errors() []string {
+	return p.errors
+}
+
+// mark 返回当前令牌的起始位置，作为随后解析的节点的起点。
+func (p *Parser) mark() int {
+	return p.peek().Pos
+}
+
+// finish 把节点的范围设为从start到上一个已消费令牌的结尾。
+func (p *Parser) finish(node ASTNode, start int) {
+	if isNilNode(node) {
+		return
+	}
+	end := p.getPreviousToken().End
+	if end < start {
+		end = start
+	}
+	node.(positioned).setSpan(start, end)
+}
+
 // Parse 将提供的令牌解析为一个AST。
 func (p *Parser) Parse() ASTNode {
+	start := p.mark()
+	node := p.parseStatement()
+	p.finish(node, start)
+	return node
+}
+
+func (p *Parser) parseStatement() ASTNode {
 	switch {
 	case p.match(SELECT):
 		return p.parseSelectStatement()
+	case p.peek().Type == WITH:
+		return p.parseQuery()
 	case p.match(INSERT):
 		return p.parseInsertStatement()
 	case p.match(UPDATE):
 		return p.parseUpdateStatement()
 	case p.match(DELETE):
 		return p.parseDeleteStatement()
+	case p.match(CREATE):
+		return p.parseCreateStatement()
+	case p.match(DROP):
+		return p.parseDropStatement()
+	case p.match(ALTER):
+		return p.parseAlterStatement()
+	case p.match(BEGIN), p.matchWord("START"):
+		return p.parseTransactionStatement(BEGIN_TRANSACTION)
+	case p.match(COMMIT):
+		return p.parseTransactionStatement(COMMIT_TRANSACTION)
+	case p.match(ROLLBACK):
+		return p.parseTransactionStatement(ROLLBACK_TRANSACTION)
 	default:
-		// TODO: 处理未识别的令牌类型或语法错误。
+		p.errorf("unexpected %q at start of statement", p.peek().Value)
 		return nil
 	}
 }
 
+// parseTransactionStatement 解析 BEGIN/START TRANSACTION、COMMIT 和 ROLLBACK 之后可选的 TRANSACTION 或 WORK。
+func (p *Parser) parseTransactionStatement(transactionType TransactionType) *TransactionStatement {
+	start := p.getPreviousToken().Pos
+	if !p.matchWord("TRANSACTION") {
+		p.matchWord("WORK")
+	}
+	stmt := &TransactionStatement{Type: transactionType}
+	p.finish(stmt, start)
+	return stmt
+}
+
 // parseDeleteStatement 解析一个DELETE语句。
 func (p *Parser) parseDeleteStatement() *DeleteStatement {
 	stmt := &DeleteStatement{}
-	stmt.TableName = p.expect(IDENTIFIER).Value
+	start := p.getPreviousToken().Pos
+	p.match(FROM)
+	stmt.TableName = p.parseName()
 
 	// 解析WHERE子句（如果存在）。
 	if p.match(WHERE) {
 		stmt.Where = p.parseWhereClause()
 	}
 
+	p.finish(stmt, start)
 	return stmt
 }
 
@@ -103,13 +209,18 @@ func (p *Parser) parseDeleteStatement() *DeleteStatement {
 func (p *Parser) parseColumns() []ASTNode {
 	var columns []ASTNode
 	for {
+		start := p.mark()
 		if p.match(STAR) {
-			columns = append(columns, &Star{})
+			star := &Star{}
+			p.finish(star, start)
+			columns = append(columns, star)
 		} else {
 			expr := p.parseExpression() // 使用parseExpression来处理更复杂的表达式
 			if p.match(AS) {
 				alias := p.expect(IDENTIFIER).Value
-				columns = append(columns, &AliasedExpression{Expr: expr, Alias: alias})
+				aliased := &AliasedExpression{Expr: expr, Alias: alias}
+				p.finish(aliased, start)
+				columns = append(columns, aliased)
 			} else {
 				columns = append(columns, expr)
 			}
@@ -122,60 +233,106 @@ func (p *Parser) parseColumns() []ASTNode {
 	return columns
 }
 
-// parseFromClause 解析FROM子句。
+// parseFromClause 解析FROM子句，包括其后以逗号分隔的表和所有JOIN子句。
 func (p *Parser) parseFromClause() *FromClause {
 	from := &FromClause{}
-	from.TableName = p.expect(IDENTIFIER).Value
-
-	// 解析表的别名
-	if p.match(AS) {
-		p.advance() // 跳过AS
-		alias := &AliasClause{Alias: p.expect(IDENTIFIER).Value}
-		from.Alias = alias.Alias
-	} else if p.peek().Type == IDENTIFIER { // 如果没有AS，但后面是标识符，也视为别名
-		alias := &AliasClause{Alias: p.advance().Value}
-		from.Alias = alias.Alias
+	start := p.getPreviousToken().Pos
+	if p.match(LEFT_PAREN) {
+		from.Derived = p.parseDerivedTable()
+	} else {
+		from.TableName = p.parseName()
+		from.Alias = p.parseAlias()
 	}
 
-	// 解析所有的JOIN子句
-	for p.match(INNER, LEFT, RIGHT, FULL) {
-		join := &JoinClause{}
-		join.Type = p.currentToken().Type
-		p.expect(JOIN) // 期望下一个 token 是 JOIN 关键字
+	for {
+		if p.match(COMMA) {
+			from.Joins = append(from.Joins, p.parseJoinClause(COMMA, false))
+			continue
+		}
+		natural := p.match(NATURAL)
+		joinType, ok := p.parseJoinType()
+		if !ok {
+			if natural {
+				p.errorf("expected JOIN after NATURAL, got %q", p.peek().Value)
+			}
+			break
+		}
+		from.Joins = append(from.Joins, p.parseJoinClause(joinType, natural))
+	}
 
-		join.Table = &Identifier{Name: p.expect(IDENTIFIER).Value}
+	p.finish(from, start)
+	return from
+}
 
-		// 解析JOIN的别名
-		if p.match(AS) {
-			p.advance()
-			alias := &AliasClause{Alias: p.expect(IDENTIFIER).Value}
-			join.Alias = alias
-		} else if p.peek().Type == IDENTIFIER {
-			alias := &AliasClause{Alias: p.advance().Value}
-			join.Alias = alias
+// parseJoinType 解析 [INNER | LEFT [OUTER] | RIGHT [OUTER] | FULL [OUTER] | CROSS] JOIN，
+// 当前位置不是JOIN时返回false且不消费令牌。
+func (p *Parser) parseJoinType() (TokenType, bool) {
+	switch p.peek().Type {
+	case JOIN:
+		p.advance()
+		return INNER, true
+	case INNER, CROSS:
+		if p.peekAt(1).Type != JOIN {
+			return EOF, false
 		}
-
-		if p.match(ON) {
-			join.On = p.parseWhereClause()
+		joinType := p.advance().Type
+		p.advance()
+		return joinType, true
+	case LEFT, RIGHT, FULL:
+		offset := 1
+		if p.peekAt(offset).Type == OUTER {
+			offset++
+		}
+		if p.peekAt(offset).Type != JOIN {
+			return EOF, false
 		}
-		from.Joins = append(from.Joins, join)
+		joinType := p.advance().Type
+		p.match(OUTER)
+		p.advance()
+		return joinType, true
 	}
+	return EOF, false
+}
 
-	return from
+// parseAlias 解析可选的别名，AS可以省略。
+func (p *Parser) parseAlias() string {
+	if p.match(AS) {
+		return p.parseName()
+	}
+	if p.peek().Type == IDENTIFIER {
+		return p.advance().Value
+	}
+	return ""
+}
+
+// parseDerivedTable 解析FROM或JOIN中的派生表 (SELECT ...) alias，调用前左括号已被消费。
+func (p *Parser) parseDerivedTable() *NestedSubQuery {
+	start := p.getPreviousToken().Pos
+	if !p.isSubquery() {
+		p.errorf("expected subquery after (, got %q", p.peek().Value)
+		return nil
+	}
+	derived := &NestedSubQuery{Select: p.parseSubquery().Statement}
+	derived.Alias = p.parseAlias()
+	p.finish(derived, start)
+	return derived
 }
 
 // parseUpdateStatement 解析一个UPDATE语句。
 func (p *Parser) parseUpdateStatement() *UpdateStatement {
 	stmt := &UpdateStatement{}
+	start := p.getPreviousToken().Pos
 	stmt.TableName = p.expect(IDENTIFIER).Value
 
 	p.expect(SET)
 	for {
+		updateStart := p.mark()
 		updateExpr := &UpdateExpression{
 			Column: p.expect(IDENTIFIER).Value,
 		}
 		p.expect(EQUALS)
 		updateExpr.Value = p.parseExpression()
+		p.finish(updateExpr, updateStart)
 		stmt.Updates = append(stmt.Updates, updateExpr)
 		if !p.match(COMMA) { // 如果不再有更多的列更新。
 			break
@@ -186,5 +343,6 @@ func (p *Parser) parseUpdateStatement() *UpdateStatement {
 		stmt.Where = p.parseWhereClause()
 	}
 
+	p.finish(stmt, start)
 	return stmt
 }
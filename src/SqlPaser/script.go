@@ -0,0 +1,326 @@
+package SqlPaser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// StatementSpan 表示一条语句在原始输入中的字节范围，不含结尾的分号。
+type StatementSpan struct {
+	Start int
+	End   int
+}
+
+// BlockTracker 跟踪 BEGIN ... END 块的嵌套深度，用来判断分号是否结束一条语句。
+// 调用方负责跳过字符串和注释，把单词、分号和其他符号依次交给它。
+// PythonSqlPaser 按令牌切分语句时也使用它。
+//
+// BEGIN 在 MySQL 中不是保留字，可以用作列名或别名，如 SELECT begin FROM t。
+// 因此只有在语句开头、标签（lbl:）或 THEN/ELSE/DO 等复合语句关键字之后，
+// 以及 CREATE PROCEDURE/FUNCTION/TRIGGER/EVENT 的例程体开头，BEGIN 才开始语句块，
+// 跟在点号之后（x.begin）时永远不会。否则 `SELECT begin FROM t; DROP TABLE users` 会被当作一条语句。
+type BlockTracker struct {
+	depth        int
+	pendingBegin bool   // 上一个单词是BEGIN，还不能确定它是事务还是语句块
+	pendingEnd   bool   // 上一个单词是END，可能后跟 IF/LOOP 等
+	previous     string // 当前语句中上一个单词或符号，语句开头为空
+	first        string // 当前语句的第一个单词
+	routine      bool   // 当前语句定义存储例程，且例程体还没有开始
+}
+
+// blockKeywords 是之后可以直接开始 BEGIN ... END 块的关键字。
+var blockKeywords = map[string]bool{"THEN": true, "ELSE": true, "DO": true, "LOOP": true, "REPEAT": true, "BEGIN": true}
+
+// Word 处理一个单词，value 可以包含多个以空白分隔的单词，如 "END IF"。
+func (b *BlockTracker) Word(value string) {
+	for _, word := range strings.Fields(strings.ToUpper(value)) {
+		b.word(word)
+	}
+}
+
+// Symbol 处理分号以外的符号，如点号、冒号、括号或字符串。
+func (b *BlockTracker) Symbol(value string) {
+	b.pendingEnd = false
+	if b.pendingBegin {
+		// BEGIN 之后不是单词，如 `SELECT 1 AS begin, 2`，不开启语句块
+		b.pendingBegin = false
+	}
+	b.previous = value
+}
+
+func (b *BlockTracker) word(word string) {
+	if b.pendingBegin {
+		b.pendingBegin = false
+		switch word {
+		case "TRANSACTION", "WORK", "TRAN", "DEFERRED", "IMMEDIATE", "EXCLUSIVE":
+			// BEGIN TRANSACTION 是事务语句，不开启语句块
+		default:
+			b.depth++
+			b.routine = false
+		}
+	}
+	if b.pendingEnd {
+		b.pendingEnd = false
+		switch word {
+		case "IF", "LOOP", "WHILE", "REPEAT":
+			// END IF 之类关闭的是控制结构，它们的开头没有计入深度，这里要补回来
+			b.depth++
+			b.previous = word
+			return
+		case "CASE":
+			// END CASE 中的CASE不是新的CASE
+			b.previous = word
+			return
+		}
+	}
+
+	switch word {
+	case "BEGIN":
+		b.pendingBegin = b.opensBlock()
+	case "CASE":
+		b.depth++
+	case "END":
+		if b.depth > 0 {
+			b.depth--
+		}
+		b.pendingEnd = true
+	case "PROCEDURE", "FUNCTION", "TRIGGER", "EVENT":
+		b.routine = b.routine || b.first == "CREATE"
+	}
+	if b.first == "" {
+		b.first = word
+	}
+	b.previous = word
+}
+
+// opensBlock 判断当前位置的BEGIN能否开始语句块。
+func (b *BlockTracker) opensBlock() bool {
+	switch {
+	case b.previous == "." || b.previous == "@":
+		return false
+	case b.previous == "" || b.previous == ":" || blockKeywords[b.previous]:
+		return true
+	}
+	return b.routine
+}
+
+// Semicolon 处理一个分号，返回它是否结束了当前语句。
+func (b *BlockTracker) Semicolon() bool {
+	// BEGIN; 是事务语句
+	b.pendingBegin = false
+	b.pendingEnd = false
+	// 语句块中的分号之后是块内的下一条语句
+	b.previous = ""
+	if b.depth == 0 {
+		b.first = ""
+		b.routine = false
+		return true
+	}
+	return false
+}
+
+// SplitStatements 按顶层分号把输入切分为多条语句。
+// 字符串、反引号标识符、注释、美元符号引用以及 BEGIN ... END 块中的分号不会切分语句，
+// 只包含空白和注释的片段会被忽略。
+func SplitStatements(input string) []StatementSpan {
+	var spans []StatementSpan
+	var tracker BlockTracker
+	start := 0
+	hasCode := false
+
+	addSpan := func(end int) {
+		if hasCode {
+			spans = append(spans, trimSpan(input, start, end))
+		}
+		hasCode = false
+	}
+
+	for pos := 0; pos < len(input); {
+		ch := input[pos]
+		switch {
+		case ch == '-' && pos+1 < len(input) && input[pos+1] == '-', ch == '#' && isHashComment(input, pos):
+			for pos < len(input) && input[pos] != '\n' {
+				pos++
+			}
+		case ch == '/' && pos+1 < len(input) && input[pos+1] == '*':
+			if pos+2 < len(input) && input[pos+2] == '!' {
+				// MySQL 的可执行注释，其中的内容仍然是代码
+				hasCode = true
+				pos += 3
+				continue
+			}
+			end := strings.Index(input[pos+2:], "*/")
+			if end < 0 {
+				pos = len(input)
+			} else {
+				pos += 2 + end + 2
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			hasCode = true
+			lexer := &Lexer{input: input, pos: pos}
+			lexer.lexQuoted(ch)
+			tracker.Symbol(string(ch))
+			pos = lexer.pos
+		case ch == '$' && dollarQuoteTag(input, pos) != "":
+			hasCode = true
+			lexer := &Lexer{input: input, pos: pos}
+			lexer.lexDollarQuoted()
+			tracker.Symbol("$")
+			pos = lexer.pos
+		case isIdentifierChar(ch):
+			hasCode = true
+			end := pos
+			for end < len(input) && isIdentifierChar(input[end]) {
+				end++
+			}
+			tracker.Word(input[pos:end])
+			pos = end
+		case ch == ';':
+			if tracker.Semicolon() {
+				addSpan(pos)
+				start = pos + 1
+			}
+			pos++
+		default:
+			if ch != ' ' && ch != '\t' && ch != '\r' && ch != '\n' {
+				hasCode = true
+				tracker.Symbol(string(ch))
+			}
+			pos++
+		}
+	}
+	addSpan(len(input))
+	return spans
+}
+
+// trimSpan 去掉语句两端的空白。
+func trimSpan(input string, start, end int) StatementSpan {
+	for start < end && strings.ContainsRune(" \t\r\n", rune(input[start])) {
+		start++
+	}
+	for end > start && strings.ContainsRune(" \t\r\n", rune(input[end-1])) {
+		end--
+	}
+	return StatementSpan{Start: start, End: end}
+}
+
+// ParseScript 把输入切分为多条语句并逐条解析，每条语句的错误单独记录，
+// 一条语句解析失败不影响其他语句。
+func ParseScript(input string) *Script {
+	script := &Script{}
+	script.setSpan(0, len(input))
+	for _, span := range SplitStatements(input) {
+		text := input[span.Start:span.End]
+		tokens := Tokenize(text)
+		if len(tokens) == 0 {
+			// 只有注释，例如 `1; --` 的结尾
+			continue
+		}
+
+		// 令牌位置相对于整个输入，而不是单条语句
+		for i := range tokens {
+			tokens[i].Pos += span.Start
+			tokens[i].End += span.Start
+		}
+
+		stmt := &ScriptStatement{Text: text}
+		stmt.setSpan(span.Start, span.End)
+		parseStatement(stmt, tokens)
+		script.Statements = append(script.Statements, stmt)
+	}
+	return script
+}
+
+// parseStatement 解析一条语句。解析器内部的panic被转换为这条语句的错误，
+// 输入来自不可信的请求，不能让一条畸形的语句终止整个进程。
+func parseStatement(stmt *ScriptStatement, tokens []Token) {
+	parser := NewParser(tokens)
+	defer func() {
+		if r := recover(); r != nil {
+			stmt.Node = nil
+			stmt.Errors = append(parser.Errors(), fmt.Sprintf("internal parser error: %v", r))
+		}
+	}()
+	stmt.Node = parser.Parse()
+	if token := parser.peek(); token.Type != EOF && stmt.Node != nil {
+		parser.errorf("unexpected %q after end of statement", token.Value)
+	}
+	stmt.Errors = parser.Errors()
+}
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // 每条语句的原文
+	}{
+		{"1; DROP TABLE users--", []string{"1", "DROP TABLE users--"}},
+		{"SELECT ';' FROM t; SELECT 2 /* ; */;", []string{"SELECT ';' FROM t", "SELECT 2 /* ; */"}},
+		{"BEGIN; UPDATE t SET a = 1; COMMIT;", []string{"BEGIN", "UPDATE t SET a = 1", "COMMIT"}},
+		{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql; SELECT f()",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "SELECT f()"}},
+		{"CREATE PROCEDURE p() BEGIN IF a THEN SELECT 1; END IF; SELECT 2; END; CALL p()",
+			[]string{"CREATE PROCEDURE p() BEGIN IF a THEN SELECT 1; END IF; SELECT 2; END", "CALL p()"}},
+		{"CREATE PROCEDURE p() lbl: BEGIN IF a THEN BEGIN SELECT 1; END; END IF; END; CALL p()",
+			[]string{"CREATE PROCEDURE p() lbl: BEGIN IF a THEN BEGIN SELECT 1; END; END IF; END", "CALL p()"}},
+		// BEGIN 用作列名时不开启语句块，堆叠的查询仍然被切分出来
+		{"SELECT begin FROM t; DROP TABLE users", []string{"SELECT begin FROM t", "DROP TABLE users"}},
+		{"SELECT x.begin FROM t; DROP TABLE users", []string{"SELECT x.begin FROM t", "DROP TABLE users"}},
+		{"SELECT 1 AS begin FROM t; DROP TABLE users", []string{"SELECT 1 AS begin FROM t", "DROP TABLE users"}},
+		{"CREATE PROCEDURE p() BEGIN SELECT x.begin FROM t; END; DROP TABLE users",
+			[]string{"CREATE PROCEDURE p() BEGIN SELECT x.begin FROM t; END", "DROP TABLE users"}},
+	}
+
+	for _, tt := range tests {
+		spans := SplitStatements(tt.input)
+		var got []string
+		for _, span := range spans {
+			got = append(got, tt.input[span.Start:span.End])
+		}
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("For input %q, expected %q but got %q", tt.input, tt.expected, got)
+		}
+	}
+
+	script := ParseScript("SELECT name FROM users WHERE id = 1; DROP TABLE users--")
+	if len(script.Statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %d", len(script.Statements))
+	}
+	if _, ok := script.Statements[1].Node.(*DropTableStatement); !ok {
+		t.Errorf("Expected second statement to be DropTableStatement, got %T", script.Statements[1].Node)
+	}
+	if len(script.Statements[0].Errors) > 0 || len(script.Statements[1].Errors) > 0 {
+		t.Errorf("Unexpected errors: %v %v", script.Statements[0].Errors, script.Statements[1].Errors)
+	}
+
+	// 缺少必需的令牌时记录错误而不是panic
+	for _, input := range []string{"UPDATE- ", "UPDATE t SET", "SELECT 1 AS begin; SELECT 2", `INSERT "LIKE `,
+		"SELECT * FROM (SELECT 1 ORDER)", "SELECT a FROM t GROUP BY", "INSERT INTO t (", "CREATE TEMPORARY x"} {
+		script := ParseScript(input)
+		if len(script.Statements) == 0 || len(script.Statements[0].Errors) == 0 {
+			t.Errorf("Expected a parse error for %q", input)
+		}
+	}
+	// 缺少表达式时不能留下nil操作数
+	for _, input := range []string{"SELECT a FROM t WHERE id =", "SELECT a FROM t WHERE", "SELECT a,",
+		"SELECT 1 UNION ALL SELECT", "SELECT a FROM t LIMIT", "UPDATE t SET a ="} {
+		script := ParseScript(input)
+		if len(script.Statements) != 1 || strings.Join(script.Statements[0].Errors, "; ") != `expected expression, got ""` {
+			t.Errorf("Expected a missing expression error for %q, got %+v", input, script.Statements)
+		}
+	}
+
+	// 不认识的字符不能截断令牌流，之后的部分也要解析
+	for _, input := range []string{"SELECT * FROM t WHERE id = 1|0 UNION SELECT password FROM mysql.user",
+		"SELECT * FROM t WHERE id = 1%0 OR 1=1", "SELECT * FROM t WHERE id = !1", "SELECT a[1] FROM t",
+		"CREATE TABLE t (a VARCHAR(1|0))"} {
+		script := ParseScript(input)
+		if len(script.Statements) != 1 || len(script.Statements[0].Errors) == 0 {
+			t.Errorf("Expected a parse error for %q", input)
+		}
+	}
+	tokens := Tokenize("1 &1; DROP TABLE users")
+	if len(tokens) != 7 || tokens[1].Type != ILLEGAL || tokens[1].Value != "&" || tokens[6].Value != "users" {
+		t.Errorf("Unexpected tokens %v", tokens)
+	}
+}
@@ -1,12 +1,69 @@
 package SqlPaser
 
 import (
+	"strings"
 	"testing"
 )
 
+// parseSelectStatement 解析SELECT之后的部分，包括 UNION 等集合运算，调用前SELECT已被消费。
 func (p *Parser) parseSelectStatement() *SelectStatement {
 	stmt := &SelectStatement{}
+	start := p.getPreviousToken().Pos
+	p.parseSelectCore(stmt)
 
+	// 解析 UNION、INTERSECT、EXCEPT（如果存在）。
+	for p.peek().Type == UNION || p.peek().Type == INTERSECT || p.peek().Type == EXCEPT {
+		op := p.parseSetOperation()
+		if op == nil {
+			break
+		}
+		stmt.SetOperations = append(stmt.SetOperations, op)
+	}
+
+	// 解析 ORDER BY 子句（如果存在）。
+	if p.match(ORDER_BY) {
+		stmt.OrderBy = p.parseOrderByClause()
+	}
+
+	// 解析 LIMIT 子句（如果存在）。
+	if p.match(LIMIT) {
+		stmt.Limit = p.parseLimitClause()
+	}
+
+	// TODO: 解析其他子句，如 ALIAS等。
+
+	p.finish(stmt, start)
+	return stmt
+}
+
+// parseSetOperation 解析 UNION [ALL | DISTINCT] 等集合运算及其右侧的查询，
+// 右侧可以是 SELECT ... 或带括号的完整查询。右侧缺失时记录错误并返回nil。
+func (p *Parser) parseSetOperation() *SetOperation {
+	start := p.mark()
+	op := &SetOperation{Operator: p.advance().Type}
+	if op.All = p.matchWord("ALL"); !op.All {
+		p.match(DISTINCT)
+	}
+	switch {
+	case p.match(SELECT):
+		branchStart := p.getPreviousToken().Pos
+		op.Select = &SelectStatement{}
+		p.parseSelectCore(op.Select)
+		p.finish(op.Select, branchStart)
+	case p.peek().Type == LEFT_PAREN && (p.peekAt(1).Type == SELECT || p.peekAt(1).Type == WITH):
+		p.advance()
+		op.Select = p.parseSubquery().Statement
+	default:
+		p.errorf("expected SELECT after %s, got %q", op.Operator, p.peek().Value)
+		return nil
+	}
+	p.finish(op, start)
+	return op
+}
+
+// parseSelectCore 解析一个SELECT的列、FROM、WHERE、GROUP BY和HAVING，
+// 不包括作用于集合运算结果的 ORDER BY 和 LIMIT。
+func (p *Parser) parseSelectCore(stmt *SelectStatement) {
 	// 解析是否存在 DISTINCT 关键字。
 	if p.match(DISTINCT) {
 		stmt.Distinct = true
@@ -20,14 +77,6 @@ func (p *Parser) parseSelectStatement() *SelectStatement {
 		stmt.From = p.parseFromClause()
 	}
 
-	// 解析所有的JOIN子句
-	for p.match(INNER, LEFT, RIGHT, FULL) {
-		joinType := p.currentToken().Type
-		p.advance() // 跳过 JOIN 类型
-		p.expect(JOIN)
-		stmt.From.Joins = append(stmt.From.Joins, p.parseJoinClause(joinType))
-	}
-
 	// 解析 WHERE 子句（如果存在）。
 	if p.match(WHERE) {
 		stmt.Where = p.parseWhereClause()
@@ -42,20 +91,6 @@ func (p *Parser) parseSelectStatement() *SelectStatement {
 	if p.match(HAVING) {
 		stmt.Having = p.parseHavingClause()
 	}
-
-	// 解析 ORDER BY 子句（如果存在）。
-	if p.match(ORDER_BY) {
-		stmt.OrderBy = p.parseOrderByClause()
-	}
-
-	// 解析 LIMIT 子句（如果存在）。
-	if p.match(LIMIT) {
-		stmt.Limit = p.parseLimitClause()
-	}
-
-	// TODO: 解析其他子句，如 ALIAS等。
-
-	return stmt
 }
 
 func TestParseSelectStatement(t *testing.T) {
@@ -124,3 +159,89 @@ func TestParseSelectStatement(t *testing.T) {
 
 	// ... 更多断言 ...
 }
+
+func TestParseFromClause(t *testing.T) {
+	stmt, errs := parseSQL(`
+        WITH RECURSIVE recent (id) AS (SELECT id FROM orders WHERE total > 100)
+        SELECT u.name, t.cnt
+        FROM (SELECT user_id, COUNT(*) AS cnt FROM orders GROUP BY user_id) AS t, users u
+        CROSS JOIN settings
+        NATURAL JOIN profiles
+        LEFT OUTER JOIN recent r USING (id)
+    `)
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	selectStmt, ok := stmt.(*SelectStatement)
+	if !ok {
+		t.Fatalf("Expected statement to be of type *SelectStatement, got %T", stmt)
+	}
+
+	if selectStmt.With == nil || !selectStmt.With.Recursive || len(selectStmt.With.CTEs) != 1 {
+		t.Fatalf("Expected one recursive CTE, got %+v", selectStmt.With)
+	}
+	if cte := selectStmt.With.CTEs[0]; cte.Name != "recent" || len(cte.Columns) != 1 || cte.Select == nil {
+		t.Errorf("Unexpected CTE %+v", cte)
+	}
+
+	from := selectStmt.From
+	if from.Derived == nil || from.Derived.Alias != "t" {
+		t.Fatalf("Expected derived table aliased as t, got %+v", from.Derived)
+	}
+	expectedJoins := []struct {
+		joinType TokenType
+		natural  bool
+		table    string
+	}{
+		{COMMA, false, "users"},
+		{CROSS, false, "settings"},
+		{INNER, true, "profiles"},
+		{LEFT, false, "recent"},
+	}
+	if len(from.Joins) != len(expectedJoins) {
+		t.Fatalf("Expected %d joins, got %d", len(expectedJoins), len(from.Joins))
+	}
+	for i, expected := range expectedJoins {
+		join := from.Joins[i]
+		if join.Type != expected.joinType || join.Natural != expected.natural || join.Table.Name != expected.table {
+			t.Errorf("Join %d: expected %v %t %s, got %v %t %s", i, expected.joinType, expected.natural, expected.table, join.Type, join.Natural, join.Table.Name)
+		}
+	}
+	if using := from.Joins[3].Using; len(using) != 1 || using[0] != "id" {
+		t.Errorf("Expected USING (id), got %v", using)
+	}
+}
+
+func TestParseSetOperations(t *testing.T) {
+	stmt, errs := parseSQL("WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n+1 FROM r) SELECT n FROM r")
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	cte := stmt.(*SelectStatement).With.CTEs[0].Select
+	if len(cte.SetOperations) != 1 || cte.SetOperations[0].Operator != UNION || !cte.SetOperations[0].All || cte.SetOperations[0].Select.From.TableName != "r" {
+		t.Errorf("Expected UNION ALL in recursive CTE, got %+v", cte.SetOperations)
+	}
+
+	stmt, errs = parseSQL("SELECT a FROM t UNION SELECT b FROM u INTERSECT (SELECT c FROM v LIMIT 1) EXCEPT DISTINCT SELECT d FROM w ORDER BY a LIMIT 5")
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	selectStmt := stmt.(*SelectStatement)
+	var operators []string
+	for _, op := range selectStmt.SetOperations {
+		operators = append(operators, op.Operator.String())
+	}
+	if strings.Join(operators, " ") != "UNION INTERSECT EXCEPT" || selectStmt.OrderBy == nil || selectStmt.Limit == nil || selectStmt.SetOperations[1].Select.Limit == nil {
+		t.Errorf("Unexpected set operations %v in %s", operators, selectStmt)
+	}
+	// ORDER BY 和 LIMIT 属于整个合并结果，不属于最后一个分支
+	if last := selectStmt.SetOperations[2].Select; last.OrderBy != nil || last.Limit != nil {
+		t.Errorf("Expected ORDER BY and LIMIT on the compound query, got %s", last)
+	}
+
+	for _, input := range []string{"SELECT 1 UNION", "SELECT 1 UNION ALL 2", "SELECT a FROM t ORDER BY a UNION SELECT b FROM u"} {
+		if script := ParseScript(input); len(script.Statements[0].Errors) == 0 {
+			t.Errorf("Expected a parse error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package SqlPaser
+
+import "testing"
+
+// TestSqlPaser 运行与被测代码写在同一文件中的测试函数。
+func TestSqlPaser(t *testing.T) {
+	t.Run("Analyze", TestAnalyze)
+	t.Run("ParseDDLStatements", TestParseDDLStatements)
+	t.Run("FindConstantConditions", TestFindConstantConditions)
+	t.Run("FormatRoundTrip", TestFormatRoundTrip)
+	t.Run("ParseInsertStatement", TestParseInsertStatement)
+	t.Run("JSONRoundTrip", TestJSONRoundTrip)
+	t.Run("ParseScript", TestParseScript)
+	t.Run("ParseSelectStatement", TestParseSelectStatement)
+	t.Run("ParseFromClause", TestParseFromClause)
+	t.Run("ParseSetOperations", TestParseSetOperations)
+	t.Run("Templatize", TestTemplatize)
+	t.Run("Lexer", TestLexer)
+	t.Run("TokenTypeNames", TestTokenTypeNames)
+	t.Run("ParseWhereExpressions", TestParseWhereExpressions)
+	t.Run("Walk", TestWalk)
+}
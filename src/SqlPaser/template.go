@@ -0,0 +1,266 @@
+package SqlPaser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// QueryTemplate 是去掉字面量之后的查询结构，同一应用中只有参数不同的查询具有相同的模板。
+type QueryTemplate struct {
+	Node ASTNode // 字面量被替换为Placeholder的AST副本。
+	SQL  string  // 模板的规范化SQL，如 `SELECT name FROM users WHERE id = ?`。
+	Hash string  // SQL的SHA-256十六进制摘要，可以作为允许列表的键。
+}
+
+// Templatize 返回节点的查询模板，原AST不会被修改。
+// 数字、字符串和 LiteralValue 被替换为占位符，全部由占位符组成的IN列表
+// 和INSERT的多行VALUES会折叠为一个，因此 `id IN (1, 2)` 与 `id IN (3)` 具有相同的模板，
+// 参数化的 OFFSET 被省略，`LIMIT 10 OFFSET 20` 与 `LIMIT 5` 也具有相同的模板。
+func Templatize(node ASTNode) *QueryTemplate {
+	template := &QueryTemplate{Node: templateNode(node)}
+	template.SQL = Format(template.Node, Options{})
+	sum := sha256.Sum256([]byte(template.SQL))
+	template.Hash = hex.EncodeToString(sum[:])
+	return template
+}
+
+// isPlaceholder 判断节点是否已被替换为占位符。
+func isPlaceholder(node ASTNode) bool {
+	_, ok := node.(*Placeholder)
+	return ok
+}
+
+// templateNodes 对列表中的每个节点做模板化。
+func templateNodes(nodes []ASTNode) []ASTNode {
+	if nodes == nil {
+		return nil
+	}
+	result := make([]ASTNode, len(nodes))
+	for i, node := range nodes {
+		result[i] = templateNode(node)
+	}
+	return result
+}
+
+// templateSelect 对SELECT语句做模板化，nil保持为nil。
+func templateSelect(stmt *SelectStatement) *SelectStatement {
+	if stmt == nil {
+		return nil
+	}
+	return templateNode(stmt).(*SelectStatement)
+}
+
+// templateWhere 对WHERE子句做模板化，nil保持为nil。
+func templateWhere(where *WhereClause) *WhereClause {
+	if where == nil {
+		return nil
+	}
+	return &WhereClause{Condition: templateNode(where.Condition)}
+}
+
+// templateNested 对派生表做模板化，nil保持为nil。
+func templateNested(nested *NestedSubQuery) *NestedSubQuery {
+	if nested == nil {
+		return nil
+	}
+	return &NestedSubQuery{Select: templateSelect(nested.Select), Alias: nested.Alias}
+}
+
+// templateSubquery 对子查询做模板化，nil保持为nil。
+func templateSubquery(subquery *Subquery) *Subquery {
+	if subquery == nil {
+		return nil
+	}
+	return &Subquery{Statement: templateSelect(subquery.Statement)}
+}
+
+// templateNode 返回替换了字面量的节点副本，不包含字面量的节点原样共享。
+func templateNode(node ASTNode) ASTNode {
+	switch n := node.(type) {
+	case *NumberLiteral, *StringLiteral, *LiteralValue:
+		return &Placeholder{}
+	case *UnaryExpr:
+		operand := templateNode(n.Operand)
+		if n.Operator != NOT && isPlaceholder(operand) {
+			return operand // -1 与 1 是同一个参数
+		}
+		return &UnaryExpr{Operator: n.Operator, Operand: operand}
+	case *Script:
+		script := &Script{}
+		for _, stmt := range n.Statements {
+			copied := *stmt
+			copied.Node = templateNode(stmt.Node)
+			script.Statements = append(script.Statements, &copied)
+		}
+		return script
+	case *SelectStatement:
+		stmt := *n
+		if n.With != nil {
+			with := &WithClause{Recursive: n.With.Recursive}
+			for _, cte := range n.With.CTEs {
+				with.CTEs = append(with.CTEs, &CommonTableExpression{Name: cte.Name, Columns: cte.Columns, Select: templateSelect(cte.Select)})
+			}
+			stmt.With = with
+		}
+		stmt.Columns = templateNodes(n.Columns)
+		if n.From != nil {
+			from := *n.From
+			from.Derived = templateNested(n.From.Derived)
+			from.Joins = nil
+			for _, join := range n.From.Joins {
+				copied := *join
+				copied.Derived = templateNested(join.Derived)
+				copied.On = templateWhere(join.On)
+				from.Joins = append(from.Joins, &copied)
+			}
+			stmt.From = &from
+		}
+		stmt.Where = templateWhere(n.Where)
+		if n.GroupBy != nil {
+			stmt.GroupBy = &GroupByClause{Columns: templateNodes(n.GroupBy.Columns)}
+		}
+		if n.Having != nil {
+			stmt.Having = &HavingClause{Condition: templateNode(n.Having.Condition)}
+		}
+		stmt.SetOperations = nil
+		for _, op := range n.SetOperations {
+			stmt.SetOperations = append(stmt.SetOperations, &SetOperation{Operator: op.Operator, All: op.All, Select: templateSelect(op.Select)})
+		}
+		if n.Limit != nil {
+			limit := &LimitClause{Count: templateNode(n.Limit.Count)}
+			// 分页时只有偏移量不同，OFFSET 为参数时省略，LIMIT 10 OFFSET 20 与 LIMIT 5 具有相同的模板
+			if offset := templateNode(n.Limit.Offset); !isNilNode(offset) && !isPlaceholder(offset) {
+				limit.Offset = offset
+			}
+			stmt.Limit = limit
+		}
+		return &stmt
+	case *InsertStatement:
+		stmt := *n
+		stmt.Values = nil
+		for _, row := range n.Values {
+			stmt.Values = append(stmt.Values, templateNodes(row))
+		}
+		if len(stmt.Values) > 1 && isPlaceholderRows(stmt.Values) {
+			stmt.Values = stmt.Values[:1]
+		}
+		stmt.SelectStatement = templateSelect(n.SelectStatement)
+		return &stmt
+	case *UpdateStatement:
+		stmt := *n
+		stmt.Updates = nil
+		for _, update := range n.Updates {
+			stmt.Updates = append(stmt.Updates, &UpdateExpression{Column: update.Column, Value: templateNode(update.Value)})
+		}
+		stmt.Where = templateWhere(n.Where)
+		return &stmt
+	case *DeleteStatement:
+		return &DeleteStatement{TableName: n.TableName, Where: templateWhere(n.Where)}
+	case *BinaryExpr:
+		return &BinaryExpr{Left: templateNode(n.Left), Operator: n.Operator, Right: templateNode(n.Right)}
+	case *AliasedExpression:
+		return &AliasedExpression{Expr: templateNode(n.Expr), Alias: n.Alias}
+	case *FunctionCall:
+		return &FunctionCall{Name: n.Name, Args: templateNodes(n.Args), Distinct: n.Distinct}
+	case *Subquery:
+		return templateSubquery(n)
+	case *SubQuery:
+		return &SubQuery{Select: templateSelect(n.Select)}
+	case *BetweenExpr:
+		return &BetweenExpr{Operand: templateNode(n.Operand), LowerBound: templateNode(n.LowerBound), UpperBound: templateNode(n.UpperBound), Not: n.Not}
+	case *InExpr:
+		in := &InExpr{Value: templateNode(n.Value), Values: templateNodes(n.Values), Subquery: templateSubquery(n.Subquery), Not: n.Not}
+		if len(in.Values) > 1 && isPlaceholderRows([][]ASTNode{in.Values}) {
+			in.Values = in.Values[:1]
+		}
+		return in
+	case *LikeExpr:
+		like := *n
+		like.Value = templateNode(n.Value)
+		like.Pattern = templateNode(n.Pattern)
+		if n.Escape != nil {
+			like.Escape = templateNode(n.Escape)
+		}
+		return &like
+	case *IsNullExpr:
+		return &IsNullExpr{Operand: templateNode(n.Operand), Not: n.Not}
+	case *ExistsExpr:
+		return &ExistsExpr{Subquery: templateSubquery(n.Subquery)}
+	case *CaseExpr:
+		caseExpr := &CaseExpr{IfFunction: n.IfFunction}
+		if n.Expr != nil {
+			caseExpr.Expr = templateNode(n.Expr)
+		}
+		for _, branch := range n.Branches {
+			caseExpr.Branches = append(caseExpr.Branches, &CaseBranch{Condition: templateNode(branch.Condition), Result: templateNode(branch.Result)})
+		}
+		if n.Default != nil {
+			caseExpr.Default = templateNode(n.Default)
+		}
+		return caseExpr
+	}
+	// 标识符、DDL等不包含参数的节点原样返回
+	return node
+}
+
+// isPlaceholderRows 判断所有行的所有值是否都是占位符。
+func isPlaceholderRows(rows [][]ASTNode) bool {
+	for _, row := range rows {
+		for _, value := range row {
+			if !isPlaceholder(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestTemplatize(t *testing.T) {
+	tests := []struct {
+		inputs   []string // 这些输入应当得到同一个模板
+		expected string
+	}{
+		{
+			[]string{"SELECT name FROM users WHERE id = 42", "SELECT name FROM users WHERE id = 7", "select name from users where id = -1"},
+			"SELECT name FROM users WHERE id = ?",
+		},
+		{
+			[]string{"SELECT a FROM t WHERE id IN (1, 2, 3) AND name = 'x'", "SELECT a FROM t WHERE id IN (4) AND name = 'it''s'"},
+			"SELECT a FROM t WHERE id IN (?) AND name = ?",
+		},
+		{
+			[]string{"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y')", "INSERT INTO t (a, b) VALUES (3, 'z')"},
+			"INSERT INTO t (a, b) VALUES (?, ?)",
+		},
+		{
+			[]string{"SELECT a FROM t WHERE id = $1 AND b = $2", "SELECT a FROM t WHERE id = ? AND b = 'x'"},
+			"SELECT a FROM t WHERE id = ? AND b = ?",
+		},
+		{
+			[]string{"SELECT a FROM t ORDER BY a LIMIT 10 OFFSET 20", "SELECT a FROM t ORDER BY a LIMIT 5", "SELECT a FROM t ORDER BY a LIMIT 20, 10", "SELECT a FROM t ORDER BY a LIMIT ? OFFSET ?"},
+			"SELECT a FROM t ORDER BY a LIMIT ?",
+		},
+	}
+
+	for _, tt := range tests {
+		var hash string
+		for _, input := range tt.inputs {
+			stmt, _ := parseSQL(input)
+			template := Templatize(stmt)
+			if template.SQL != tt.expected {
+				t.Errorf("For input %q, expected template %q but got %q", input, tt.expected, template.SQL)
+			}
+			if hash != "" && template.Hash != hash {
+				t.Errorf("For input %q, expected hash %s but got %s", input, hash, template.Hash)
+			}
+			hash = template.Hash
+		}
+	}
+
+	// 结构不同的查询不能共享模板
+	stmt, _ := parseSQL("SELECT name FROM users WHERE id = 1 OR 1 = 1")
+	if Templatize(stmt).SQL == "SELECT name FROM users WHERE id = ?" {
+		t.Errorf("Expected injected OR to change the template")
+	}
+}
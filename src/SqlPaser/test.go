@@ -14,14 +14,14 @@ func TestLexer(t *testing.T) {
 		{
 			input: "SELECT column1, column2 FROM table;",
 			expected: []Token{
-				{SELECT, "SELECT"},
-				{IDENTIFIER, "COLUMN1"},
-				{COMMA, ","},
-				{IDENTIFIER, "COLUMN2"},
-				{FROM, "FROM"},
-				{TABLE, "TABLE"},
-				{SEMICOLON, ";"},
-				{EOF, ""},
+				{Type: SELECT, Value: "SELECT", Pos: 0, End: 6},
+				{Type: IDENTIFIER, Value: "column1", Pos: 7, End: 14},
+				{Type: COMMA, Value: ",", Pos: 14, End: 15},
+				{Type: IDENTIFIER, Value: "column2", Pos: 16, End: 23},
+				{Type: FROM, Value: "FROM", Pos: 24, End: 28},
+				{Type: TABLE, Value: "TABLE", Pos: 29, End: 34},
+				{Type: SEMICOLON, Value: ";", Pos: 34, End: 35},
+				{Type: EOF, Value: "", Pos: 35, End: 35},
 			},
 		},
 		// ... 更多测试用例
@@ -38,7 +38,69 @@ func TestLexer(t *testing.T) {
 			}
 		}
 		if !reflect.DeepEqual(tokens, tt.expected) {
-			fmt.Println("For input %q, expected %v but got %v", tt.input, tt.expected, tokens)
+			t.Errorf("For input %q, expected %v but got %v", tt.input, tt.expected, tokens)
+		}
+	}
+}
+
+func TestTokenTypeNames(t *testing.T) {
+	// 名称表由 go generate 生成，新增令牌类型后没有重新生成时最后一个常量不在表中
+	if len(tokenTypeNames) != int(ILLEGAL)+1 {
+		t.Fatalf("token_string.go is out of date, run go generate")
+	}
+	for i := range tokenTypeNames {
+		tokenType := TokenType(i)
+		if got, ok := LookupTokenType(tokenType.String()); !ok || got != tokenType {
+			t.Errorf("LookupTokenType(%q) = %d, %t", tokenType.String(), got, ok)
+		}
+	}
+	if NOT_EQUALS.String() != "NOT_EQUALS" || LEFT_JOIN.String() != "LEFT_JOIN" {
+		t.Errorf("Unexpected names %s %s", NOT_EQUALS, LEFT_JOIN)
+	}
+	if TokenType(-1).String() != "TokenType(-1)" {
+		t.Errorf("Unexpected name for unknown token type: %s", TokenType(-1))
+	}
+}
+
+// parseSQL 对输入做词法和语法分析，供测试函数使用。
+func parseSQL(input string) (ASTNode, []string) {
+	parser := NewParser(Tokenize(input))
+	stmt := parser.Parse()
+	return stmt, parser.Errors()
+}
+
+func TestParseWhereExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // WHERE条件根节点的类型
+	}{
+		{"SELECT a FROM t WHERE id IN (1, 2, 3)", "*SqlPaser.InExpr"},
+		{"SELECT a FROM t WHERE id NOT IN (SELECT id FROM admins)", "*SqlPaser.InExpr"},
+		{"SELECT a FROM t WHERE name LIKE 'a%' ESCAPE '!'", "*SqlPaser.LikeExpr"},
+		{"SELECT a FROM t WHERE name NOT RLIKE '^r'", "*SqlPaser.LikeExpr"},
+		{"SELECT a FROM t WHERE deleted_at IS NOT NULL", "*SqlPaser.IsNullExpr"},
+		{"SELECT a FROM t WHERE EXISTS (SELECT 1 FROM users)", "*SqlPaser.ExistsExpr"},
+		{"SELECT a FROM t WHERE NOT EXISTS (SELECT 1 FROM users)", "*SqlPaser.UnaryExpr"},
+		{"SELECT a FROM t WHERE id NOT BETWEEN 1 AND 10", "*SqlPaser.BetweenExpr"},
+		{"SELECT a FROM t WHERE id = 1 OR name LIKE 'a%'", "*SqlPaser.BinaryExpr"},
+		{"SELECT a FROM t WHERE CASE WHEN (SUBSTRING(user(),1,1)='r') THEN SLEEP(5) ELSE 0 END", "*SqlPaser.CaseExpr"},
+		{"SELECT a FROM t WHERE CASE id WHEN 1 THEN CASE WHEN b IS NULL THEN 1 END ELSE 0 END", "*SqlPaser.CaseExpr"},
+		{"SELECT a FROM t WHERE IF(ASCII(SUBSTR(database(),1,1))>100, SLEEP(3), 0)", "*SqlPaser.CaseExpr"},
+		{"SELECT a FROM t WHERE id = 1 AND BENCHMARK(1e7, MD5(1)) > 0.5", "*SqlPaser.BinaryExpr"},
+	}
+
+	for _, tt := range tests {
+		stmt, errs := parseSQL(tt.input)
+		selectStmt, ok := stmt.(*SelectStatement)
+		if !ok || selectStmt.Where == nil {
+			t.Errorf("For input %q, expected a SELECT with WHERE, got %T", tt.input, stmt)
+			continue
+		}
+		if len(errs) > 0 {
+			t.Errorf("For input %q, unexpected errors: %v", tt.input, errs)
+		}
+		if got := fmt.Sprintf("%T", selectStmt.Where.Condition); got != tt.expected {
+			t.Errorf("For input %q, expected %s but got %s", tt.input, tt.expected, got)
 		}
 	}
 }
@@ -1,12 +1,32 @@
 package SqlPaser
 
+import "strconv"
+
 type Token struct {
 	Type  TokenType // 令牌类型
 	Value string    // 令牌值
+	Pos   int       // 令牌在输入中的起始字节偏移
+	End   int       // 令牌在输入中的结束字节偏移（不含）
 }
 
+//go:generate go run gen_tokens.go
+
 type TokenType int
 
+// String 返回令牌类型的常量名，如 NOT_EQUALS。
+func (t TokenType) String() string {
+	if t >= 0 && int(t) < len(tokenTypeNames) {
+		return tokenTypeNames[t]
+	}
+	return "TokenType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// LookupTokenType 根据常量名查找令牌类型，是 String 的逆操作。
+func LookupTokenType(name string) (TokenType, bool) {
+	t, ok := tokenTypesByName[name]
+	return t, ok
+}
+
 const (
 	EOF TokenType = iota
 	// 关键字
@@ -109,6 +129,27 @@ const (
 	TRUE
 	FALSE
 	BY
+	// 谓词
+	ESCAPE
+	REGEXP
+	// DDL 约束
+	PRIMARY
+	FOREIGN
+	REFERENCES
+	UNIQUE
+	DEFAULT
+	CONSTRAINT
+	CHECK
+	// 公共表表达式与连接
+	WITH
+	RECURSIVE
+	CROSS
+	NATURAL
+	USING
+	// 预编译语句的参数占位符 ?
+	PLACEHOLDER
+	// 词法分析器不认识的字符，如 | & % ^ ~ [ {，解析器遇到时报告语法错误
+	ILLEGAL
 )
 
 // 关键字映射
@@ -146,6 +187,7 @@ var keywords = map[string]TokenType{
 	")":        RIGHT_PAREN,
 	".":        DOT,
 	";":        SEMICOLON,
+	"?":        PLACEHOLDER,
 	// ... 更多关键字
 	"INNER":     INNER,
 	"LEFT":      LEFT,
@@ -157,6 +199,8 @@ var keywords = map[string]TokenType{
 	"EXCEPT":    EXCEPT,
 	"IS":        IS,
 	"NULL":      NULL,
+	"TRUE":      TRUE,
+	"FALSE":     FALSE,
 	"LIKE":      LIKE,
 	"BETWEEN":   BETWEEN,
 	"IN":        IN,
@@ -192,4 +236,22 @@ var keywords = map[string]TokenType{
 	"OFFSET": OFFSET,
 	"INTO":   INTO,
 	"BY":     BY,
+	// 谓词
+	"ESCAPE": ESCAPE,
+	"REGEXP": REGEXP,
+	"RLIKE":  REGEXP, // MySQL 中 RLIKE 是 REGEXP 的同义词
+	// DDL 约束
+	"PRIMARY":    PRIMARY,
+	"FOREIGN":    FOREIGN,
+	"REFERENCES": REFERENCES,
+	"UNIQUE":     UNIQUE,
+	"DEFAULT":    DEFAULT,
+	"CONSTRAINT": CONSTRAINT,
+	"CHECK":      CHECK,
+	// 公共表表达式与连接
+	"WITH":      WITH,
+	"RECURSIVE": RECURSIVE,
+	"CROSS":     CROSS,
+	"NATURAL":   NATURAL,
+	"USING":     USING,
 }
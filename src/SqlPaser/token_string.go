@@ -0,0 +1,219 @@
+// Code generated by "go run gen_tokens.go"; DO NOT EDIT.
+
+package SqlPaser
+
+// tokenTypeNames 是每个令牌类型的常量名。
+var tokenTypeNames = [...]string{
+	EOF:            "EOF",
+	SELECT:         "SELECT",
+	INSERT:         "INSERT",
+	UPDATE:         "UPDATE",
+	DELETE:         "DELETE",
+	FROM:           "FROM",
+	WHERE:          "WHERE",
+	JOIN:           "JOIN",
+	ON:             "ON",
+	GROUP_BY:       "GROUP_BY",
+	HAVING:         "HAVING",
+	ORDER_BY:       "ORDER_BY",
+	LIMIT:          "LIMIT",
+	AND:            "AND",
+	OR:             "OR",
+	NOT:            "NOT",
+	AS:             "AS",
+	DISTINCT:       "DISTINCT",
+	EQUALS:         "EQUALS",
+	NOT_EQUALS:     "NOT_EQUALS",
+	LESS_THAN:      "LESS_THAN",
+	GREATER_THAN:   "GREATER_THAN",
+	LESS_EQUALS:    "LESS_EQUALS",
+	GREATER_EQUALS: "GREATER_EQUALS",
+	PLUS:           "PLUS",
+	MINUS:          "MINUS",
+	MULTIPLY:       "MULTIPLY",
+	DIVIDE:         "DIVIDE",
+	STRING:         "STRING",
+	NUMBER:         "NUMBER",
+	COMMA:          "COMMA",
+	LEFT_PAREN:     "LEFT_PAREN",
+	RIGHT_PAREN:    "RIGHT_PAREN",
+	DOT:            "DOT",
+	SEMICOLON:      "SEMICOLON",
+	IDENTIFIER:     "IDENTIFIER",
+	COMMENT:        "COMMENT",
+	FUNCTION:       "FUNCTION",
+	INNER:          "INNER",
+	LEFT:           "LEFT",
+	RIGHT:          "RIGHT",
+	OUTER:          "OUTER",
+	FULL:           "FULL",
+	UNION:          "UNION",
+	INTERSECT:      "INTERSECT",
+	EXCEPT:         "EXCEPT",
+	IS:             "IS",
+	NULL:           "NULL",
+	LIKE:           "LIKE",
+	BETWEEN:        "BETWEEN",
+	IN:             "IN",
+	EXISTS:         "EXISTS",
+	CASE:           "CASE",
+	WHEN:           "WHEN",
+	THEN:           "THEN",
+	ELSE:           "ELSE",
+	END:            "END",
+	BEGIN:          "BEGIN",
+	COMMIT:         "COMMIT",
+	ROLLBACK:       "ROLLBACK",
+	CREATE:         "CREATE",
+	ALTER:          "ALTER",
+	DROP:           "DROP",
+	INDEX:          "INDEX",
+	TABLE:          "TABLE",
+	DATABASE:       "DATABASE",
+	VIEW:           "VIEW",
+	TRIGGER:        "TRIGGER",
+	REPLACE:        "REPLACE",
+	RETURNING:      "RETURNING",
+	TOP:            "TOP",
+	ROWNUM:         "ROWNUM",
+	PARTITION:      "PARTITION",
+	VACUUM:         "VACUUM",
+	SET:            "SET",
+	STAR:           "STAR",
+	VALUES:         "VALUES",
+	ASC:            "ASC",
+	DESC:           "DESC",
+	OFFSET:         "OFFSET",
+	INTO:           "INTO",
+	LEFT_JOIN:      "LEFT_JOIN",
+	RIGHT_JOIN:     "RIGHT_JOIN",
+	INNER_JOIN:     "INNER_JOIN",
+	FULL_JOIN:      "FULL_JOIN",
+	TRUE:           "TRUE",
+	FALSE:          "FALSE",
+	BY:             "BY",
+	ESCAPE:         "ESCAPE",
+	REGEXP:         "REGEXP",
+	PRIMARY:        "PRIMARY",
+	FOREIGN:        "FOREIGN",
+	REFERENCES:     "REFERENCES",
+	UNIQUE:         "UNIQUE",
+	DEFAULT:        "DEFAULT",
+	CONSTRAINT:     "CONSTRAINT",
+	CHECK:          "CHECK",
+	WITH:           "WITH",
+	RECURSIVE:      "RECURSIVE",
+	CROSS:          "CROSS",
+	NATURAL:        "NATURAL",
+	USING:          "USING",
+	PLACEHOLDER:    "PLACEHOLDER",
+	ILLEGAL:        "ILLEGAL",
+}
+
+// tokenTypesByName 根据常量名查找令牌类型。
+var tokenTypesByName = map[string]TokenType{
+	"EOF":            EOF,
+	"SELECT":         SELECT,
+	"INSERT":         INSERT,
+	"UPDATE":         UPDATE,
+	"DELETE":         DELETE,
+	"FROM":           FROM,
+	"WHERE":          WHERE,
+	"JOIN":           JOIN,
+	"ON":             ON,
+	"GROUP_BY":       GROUP_BY,
+	"HAVING":         HAVING,
+	"ORDER_BY":       ORDER_BY,
+	"LIMIT":          LIMIT,
+	"AND":            AND,
+	"OR":             OR,
+	"NOT":            NOT,
+	"AS":             AS,
+	"DISTINCT":       DISTINCT,
+	"EQUALS":         EQUALS,
+	"NOT_EQUALS":     NOT_EQUALS,
+	"LESS_THAN":      LESS_THAN,
+	"GREATER_THAN":   GREATER_THAN,
+	"LESS_EQUALS":    LESS_EQUALS,
+	"GREATER_EQUALS": GREATER_EQUALS,
+	"PLUS":           PLUS,
+	"MINUS":          MINUS,
+	"MULTIPLY":       MULTIPLY,
+	"DIVIDE":         DIVIDE,
+	"STRING":         STRING,
+	"NUMBER":         NUMBER,
+	"COMMA":          COMMA,
+	"LEFT_PAREN":     LEFT_PAREN,
+	"RIGHT_PAREN":    RIGHT_PAREN,
+	"DOT":            DOT,
+	"SEMICOLON":      SEMICOLON,
+	"IDENTIFIER":     IDENTIFIER,
+	"COMMENT":        COMMENT,
+	"FUNCTION":       FUNCTION,
+	"INNER":          INNER,
+	"LEFT":           LEFT,
+	"RIGHT":          RIGHT,
+	"OUTER":          OUTER,
+	"FULL":           FULL,
+	"UNION":          UNION,
+	"INTERSECT":      INTERSECT,
+	"EXCEPT":         EXCEPT,
+	"IS":             IS,
+	"NULL":           NULL,
+	"LIKE":           LIKE,
+	"BETWEEN":        BETWEEN,
+	"IN":             IN,
+	"EXISTS":         EXISTS,
+	"CASE":           CASE,
+	"WHEN":           WHEN,
+	"THEN":           THEN,
+	"ELSE":           ELSE,
+	"END":            END,
+	"BEGIN":          BEGIN,
+	"COMMIT":         COMMIT,
+	"ROLLBACK":       ROLLBACK,
+	"CREATE":         CREATE,
+	"ALTER":          ALTER,
+	"DROP":           DROP,
+	"INDEX":          INDEX,
+	"TABLE":          TABLE,
+	"DATABASE":       DATABASE,
+	"VIEW":           VIEW,
+	"TRIGGER":        TRIGGER,
+	"REPLACE":        REPLACE,
+	"RETURNING":      RETURNING,
+	"TOP":            TOP,
+	"ROWNUM":         ROWNUM,
+	"PARTITION":      PARTITION,
+	"VACUUM":         VACUUM,
+	"SET":            SET,
+	"STAR":           STAR,
+	"VALUES":         VALUES,
+	"ASC":            ASC,
+	"DESC":           DESC,
+	"OFFSET":         OFFSET,
+	"INTO":           INTO,
+	"LEFT_JOIN":      LEFT_JOIN,
+	"RIGHT_JOIN":     RIGHT_JOIN,
+	"INNER_JOIN":     INNER_JOIN,
+	"FULL_JOIN":      FULL_JOIN,
+	"TRUE":           TRUE,
+	"FALSE":          FALSE,
+	"BY":             BY,
+	"ESCAPE":         ESCAPE,
+	"REGEXP":         REGEXP,
+	"PRIMARY":        PRIMARY,
+	"FOREIGN":        FOREIGN,
+	"REFERENCES":     REFERENCES,
+	"UNIQUE":         UNIQUE,
+	"DEFAULT":        DEFAULT,
+	"CONSTRAINT":     CONSTRAINT,
+	"CHECK":          CHECK,
+	"WITH":           WITH,
+	"RECURSIVE":      RECURSIVE,
+	"CROSS":          CROSS,
+	"NATURAL":        NATURAL,
+	"USING":          USING,
+	"PLACEHOLDER":    PLACEHOLDER,
+	"ILLEGAL":        ILLEGAL,
+}
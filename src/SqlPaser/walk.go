@@ -0,0 +1,265 @@
+package SqlPaser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Visitor 的 Visit 方法在 Walk 遍历到每个节点时被调用。
+// 返回的 w 不为nil时，Walk 用 w 访问该节点的每个子节点，最后调用 w.Visit(nil)。
+type Visitor interface {
+	Visit(node ASTNode) (w Visitor)
+}
+
+// Walk 按深度优先顺序遍历AST，子节点按它们在SQL中出现的顺序访问，nil子节点会被跳过。
+func Walk(v Visitor, node ASTNode) {
+	if isNilNode(node) {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	visitChildren(node, func(child ASTNode) ASTNode {
+		Walk(v, child)
+		return child
+	}, false)
+	v.Visit(nil)
+}
+
+type inspector func(ASTNode) bool
+
+func (f inspector) Visit(node ASTNode) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect 按深度优先顺序遍历AST，对每个节点调用 f(node)，f 返回true时继续访问子节点，
+// 子节点都访问完后调用 f(nil)。
+func Inspect(node ASTNode, f func(ASTNode) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite 自底向上地改写AST：先改写子节点，再把 f(node) 的结果作为节点的新值返回。
+// 原树会被就地修改。f 返回的节点类型与字段类型不符时保留原节点，
+// 返回nil时清空该字段，列表中的元素则被删除。
+func Rewrite(node ASTNode, f func(ASTNode) ASTNode) ASTNode {
+	if isNilNode(node) {
+		return node
+	}
+	visitChildren(node, func(child ASTNode) ASTNode {
+		return Rewrite(child, f)
+	}, true)
+	return f(node)
+}
+
+// isNilNode 判断节点是否为nil，可选子句以带类型的nil指针出现，同样视为空节点。
+func isNilNode(node ASTNode) bool {
+	if node == nil {
+		return true
+	}
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
+
+// childVisitor 对子节点调用 visit，rewrite 为true时用返回值替换子节点。
+type childVisitor struct {
+	visit   func(ASTNode) ASTNode
+	rewrite bool
+}
+
+// visitChild 访问一个子节点字段。
+func visitChild[T ASTNode](v childVisitor, field *T) {
+	if isNilNode(*field) {
+		return
+	}
+	result := v.visit(*field)
+	if !v.rewrite {
+		return
+	}
+	if isNilNode(result) {
+		var zero T
+		*field = zero
+	} else if typed, ok := result.(T); ok {
+		*field = typed
+	}
+}
+
+// visitList 访问子节点列表中的每个元素。
+func visitList[T ASTNode](v childVisitor, list *[]T) {
+	if !v.rewrite {
+		for i := range *list {
+			visitChild(v, &(*list)[i])
+		}
+		return
+	}
+	var kept []T
+	for _, item := range *list {
+		visitChild(v, &item)
+		if !isNilNode(item) {
+			kept = append(kept, item)
+		}
+	}
+	*list = kept
+}
+
+// visitChildren 按出现顺序访问节点的所有子节点，新增节点类型时需要同时修改这里。
+func visitChildren(node ASTNode, visit func(ASTNode) ASTNode, rewrite bool) {
+	v := childVisitor{visit: visit, rewrite: rewrite}
+	switch n := node.(type) {
+	case *Script:
+		visitList(v, &n.Statements)
+	case *ScriptStatement:
+		visitChild(v, &n.Node)
+	case *SelectStatement:
+		visitChild(v, &n.With)
+		visitList(v, &n.Columns)
+		visitChild(v, &n.From)
+		visitChild(v, &n.Where)
+		visitChild(v, &n.GroupBy)
+		visitChild(v, &n.Having)
+		visitList(v, &n.SetOperations)
+		visitChild(v, &n.OrderBy)
+		visitChild(v, &n.Limit)
+	case *SetOperation:
+		visitChild(v, &n.Select)
+	case *WithClause:
+		visitList(v, &n.CTEs)
+	case *CommonTableExpression:
+		visitChild(v, &n.Select)
+	case *FromClause:
+		visitChild(v, &n.Derived)
+		visitList(v, &n.Joins)
+	case *JoinClause:
+		visitChild(v, &n.Table)
+		visitChild(v, &n.Derived)
+		visitChild(v, &n.Alias)
+		visitChild(v, &n.On)
+	case *NestedSubQuery:
+		visitChild(v, &n.Select)
+	case *WhereClause:
+		visitChild(v, &n.Condition)
+	case *GroupByClause:
+		visitList(v, &n.Columns)
+	case *HavingClause:
+		visitChild(v, &n.Condition)
+	case *OrderByClause:
+		visitList(v, &n.Columns)
+	case *OrderByExpression:
+		visitChild(v, &n.Column)
+	case *InsertStatement:
+		for i := range n.Values {
+			visitList(v, &n.Values[i])
+		}
+		visitChild(v, &n.SelectStatement)
+	case *UpdateStatement:
+		visitList(v, &n.Updates)
+		visitChild(v, &n.Where)
+	case *UpdateExpression:
+		visitChild(v, &n.Value)
+	case *DeleteStatement:
+		visitChild(v, &n.Where)
+	case *CreateTableStatement:
+		visitList(v, &n.Columns)
+		visitList(v, &n.Constraints)
+	case *ColumnDefinition:
+		visitChild(v, &n.Default)
+		visitChild(v, &n.Check)
+		visitChild(v, &n.References)
+	case *TableConstraint:
+		visitChild(v, &n.Check)
+		visitChild(v, &n.References)
+	case *AlterTableStatement:
+		visitList(v, &n.Actions)
+	case *AlterAction:
+		visitChild(v, &n.Column)
+	case *BinaryExpr:
+		visitChild(v, &n.Left)
+		visitChild(v, &n.Right)
+	case *UnaryExpr:
+		visitChild(v, &n.Operand)
+	case *AliasedExpression:
+		visitChild(v, &n.Expr)
+	case *FunctionCall:
+		visitList(v, &n.Args)
+	case *Subquery:
+		visitChild(v, &n.Statement)
+	case *SubQuery:
+		visitChild(v, &n.Select)
+	case *BetweenExpr:
+		visitChild(v, &n.Operand)
+		visitChild(v, &n.LowerBound)
+		visitChild(v, &n.UpperBound)
+	case *InExpr:
+		visitChild(v, &n.Value)
+		visitList(v, &n.Values)
+		visitChild(v, &n.Subquery)
+	case *LikeExpr:
+		visitChild(v, &n.Value)
+		visitChild(v, &n.Pattern)
+		visitChild(v, &n.Escape)
+	case *IsNullExpr:
+		visitChild(v, &n.Operand)
+	case *ExistsExpr:
+		visitChild(v, &n.Subquery)
+	case *CaseExpr:
+		visitChild(v, &n.Expr)
+		visitList(v, &n.Branches)
+		visitChild(v, &n.Default)
+	case *CaseBranch:
+		visitChild(v, &n.Condition)
+		visitChild(v, &n.Result)
+	case *LimitClause:
+		visitChild(v, &n.Count)
+		visitChild(v, &n.Offset)
+	case *AliasClause, *ForeignKeyReference, *DropTableStatement, *CreateIndexStatement,
+		*DropIndexStatement, *TransactionStatement, *Identifier, *ColumnName, *NumberLiteral,
+		*StringLiteral, *BooleanLiteral, *NullLiteral, *Placeholder, *LiteralValue, *Star:
+		// 没有子节点
+	}
+}
+
+func TestWalk(t *testing.T) {
+	input := "SELECT a, COUNT(b) FROM t WHERE id IN (SELECT id FROM u WHERE x = (SELECT MAX(y) FROM v)) AND SLEEP(5) = 0"
+	stmt, _ := parseSQL(input)
+
+	// 统计嵌套子查询
+	subqueries := 0
+	Inspect(stmt, func(node ASTNode) bool {
+		if _, ok := node.(*Subquery); ok {
+			subqueries++
+		}
+		return true
+	})
+	if subqueries != 2 {
+		t.Errorf("Expected 2 subqueries, got %d", subqueries)
+	}
+
+	// 查找所有函数调用，并检查节点范围对应的原文
+	var calls []string
+	Inspect(stmt, func(node ASTNode) bool {
+		if call, ok := node.(*FunctionCall); ok {
+			calls = append(calls, input[call.Pos():call.End()])
+		}
+		return true
+	})
+	if !reflect.DeepEqual(calls, []string{"COUNT(b)", "MAX(y)", "SLEEP(5)"}) {
+		t.Errorf("Unexpected function calls: %q", calls)
+	}
+	if stmt.Pos() != 0 || stmt.End() != len(input) {
+		t.Errorf("Expected statement to span [0:%d], got [%d:%d]", len(input), stmt.Pos(), stmt.End())
+	}
+
+	// 把 SLEEP(n) 改写为 0
+	stmt = Rewrite(stmt, func(node ASTNode) ASTNode {
+		if call, ok := node.(*FunctionCall); ok && call.Name == "SLEEP" {
+			return &NumberLiteral{Value: "0"}
+		}
+		return node
+	})
+	expected := "SELECT a, COUNT(b) FROM t WHERE id IN (SELECT id FROM u WHERE x = (SELECT MAX(y) FROM v)) AND 0 = 0"
+	if stmt.String() != expected {
+		t.Errorf("Expected rewritten query %q, got %q", expected, stmt.String())
+	}
+}
@@ -1,9 +1,14 @@
 package SqlPaser
 
+import "strings"
+
 // parseWhereClause 解析WHERE子句。
 func (p *Parser) parseWhereClause() *WhereClause {
+	start := p.getPreviousToken().Pos // WHERE 或 ON
 	condition := p.parseExpression()
-	return &WhereClause{Condition: condition}
+	where := &WhereClause{Condition: condition}
+	p.finish(where, start)
+	return where
 }
 
 // parseExpression 递归地解析表达式。
@@ -12,132 +17,217 @@ func (p *Parser) parseExpression() ASTNode {
 }
 
 func (p *Parser) parseOrExpression() ASTNode {
+	start := p.mark()
 	expr := p.parseAndExpression()
 	for p.match(OR) {
 		right := p.parseAndExpression()
 		expr = &BinaryExpr{Left: expr, Operator: OR, Right: right}
+		p.finish(expr, start)
 	}
 	return expr
 }
 
 func (p *Parser) parseAndExpression() ASTNode {
+	start := p.mark()
 	expr := p.parseNotExpression()
 	for p.match(AND) {
 		right := p.parseNotExpression()
 		expr = &BinaryExpr{Left: expr, Operator: AND, Right: right}
+		p.finish(expr, start)
 	}
 	return expr
 }
 
 func (p *Parser) parseNotExpression() ASTNode {
+	start := p.mark()
 	if p.match(NOT) {
-		operand := p.parseComparisonExpression()
-		return &UnaryExpr{Operator: NOT, Operand: operand}
+		operand := p.parseNotExpression()
+		expr := &UnaryExpr{Operator: NOT, Operand: operand}
+		p.finish(expr, start)
+		return expr
 	}
 	return p.parseComparisonExpression()
 }
 
+// parseComparisonExpression 解析比较运算以及 IS/IN/LIKE/BETWEEN/REGEXP 谓词，
+// 它们在标准SQL中处于同一优先级，低于算术运算、高于NOT。
 func (p *Parser) parseComparisonExpression() ASTNode {
+	start := p.mark()
 	expr := p.parseTerm()
 	for {
 		switch {
-		case p.match(EQUALS):
-			right := p.parseTerm()
-			expr = &BinaryExpr{Left: expr, Operator: EQUALS, Right: right}
-		case p.match(NOT_EQUALS):
-			right := p.parseTerm()
-			expr = &BinaryExpr{Left: expr, Operator: NOT_EQUALS, Right: right}
-		case p.match(LESS_THAN):
-			right := p.parseTerm()
-			expr = &BinaryExpr{Left: expr, Operator: LESS_THAN, Right: right}
-		case p.match(GREATER_THAN):
-			right := p.parseTerm()
-			expr = &BinaryExpr{Left: expr, Operator: GREATER_THAN, Right: right}
-		case p.match(LESS_EQUALS):
+		case p.match(EQUALS, NOT_EQUALS, LESS_THAN, GREATER_THAN, LESS_EQUALS, GREATER_EQUALS):
+			operator := p.getPreviousToken().Type
 			right := p.parseTerm()
-			expr = &BinaryExpr{Left: expr, Operator: LESS_EQUALS, Right: right}
-		case p.match(GREATER_EQUALS):
-			right := p.parseTerm()
-			expr = &BinaryExpr{Left: expr, Operator: GREATER_EQUALS, Right: right}
-		case p.match(BETWEEN):
-			lowerBound := p.parseTerm()
-			p.expect(AND)
-			upperBound := p.parseTerm()
-			expr = &BetweenExpr{Operand: expr, LowerBound: lowerBound, UpperBound: upperBound}
+			expr = &BinaryExpr{Left: expr, Operator: operator, Right: right}
+		case p.match(IS):
+			isNull := &IsNullExpr{Operand: expr, Not: p.match(NOT)}
+			if !p.match(NULL) {
+				p.errorf("expected NULL after IS, got %q", p.peek().Value)
+			}
+			expr = isNull
+		case p.peek().Type == NOT && p.isNegatablePredicate(p.peekAt(1).Type):
+			p.advance() // 跳过 NOT
+			expr = p.parsePredicate(expr, true)
+		case p.isNegatablePredicate(p.peek().Type):
+			expr = p.parsePredicate(expr, false)
 		default:
 			return expr
 		}
+		p.finish(expr, start)
+	}
+}
+
+// isNegatablePredicate 判断令牌是否是可以前置NOT的谓词关键字。
+func (p *Parser) isNegatablePredicate(t TokenType) bool {
+	return t == BETWEEN || t == IN || t == LIKE || t == REGEXP
+}
+
+// parsePredicate 解析 BETWEEN/IN/LIKE/REGEXP 谓词的剩余部分，operand 是左侧已解析的表达式。
+func (p *Parser) parsePredicate(operand ASTNode, not bool) ASTNode {
+	switch {
+	case p.match(BETWEEN):
+		lowerBound := p.parseTerm()
+		p.expect(AND)
+		upperBound := p.parseTerm()
+		return &BetweenExpr{Operand: operand, LowerBound: lowerBound, UpperBound: upperBound, Not: not}
+	case p.match(IN):
+		return p.parseInExpr(operand, not)
+	case p.match(LIKE, REGEXP):
+		like := &LikeExpr{Value: operand, Operator: p.getPreviousToken().Type, Not: not}
+		like.Pattern = p.parseTerm()
+		if like.Operator == LIKE && p.match(ESCAPE) {
+			like.Escape = p.parseTerm()
+		}
+		return like
 	}
+	return operand
+}
+
+// parseInExpr 解析 IN 之后的值列表或子查询。
+func (p *Parser) parseInExpr(operand ASTNode, not bool) *InExpr {
+	in := &InExpr{Value: operand, Not: not}
+	if !p.match(LEFT_PAREN) {
+		p.errorf("expected ( after IN, got %q", p.peek().Value)
+		return in
+	}
+	if p.isSubquery() {
+		in.Subquery = p.parseSubquery()
+		return in
+	}
+	if !p.match(RIGHT_PAREN) { // 允许空列表 IN ()
+		for {
+			in.Values = append(in.Values, p.parseExpression())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+		p.expect(RIGHT_PAREN)
+	}
+	return in
 }
 
 func (p *Parser) parseTerm() ASTNode {
+	start := p.mark()
 	expr := p.parseFactor()
-	for {
-		switch {
-		case p.match(PLUS):
-			right := p.parseFactor()
-			expr = &BinaryExpr{Left: expr, Operator: PLUS, Right: right}
-		case p.match(MINUS):
-			right := p.parseFactor()
-			expr = &BinaryExpr{Left: expr, Operator: MINUS, Right: right}
-		default:
-			return expr
-		}
+	for p.match(PLUS, MINUS) {
+		operator := p.getPreviousToken().Type
+		right := p.parseFactor()
+		expr = &BinaryExpr{Left: expr, Operator: operator, Right: right}
+		p.finish(expr, start)
 	}
+	return expr
 }
 
 func (p *Parser) parseFactor() ASTNode {
-	expr := p.parsePrimary()
-	for {
-		switch {
-		case p.match(MULTIPLY):
-			right := p.parsePrimary()
-			expr = &BinaryExpr{Left: expr, Operator: MULTIPLY, Right: right}
-		case p.match(DIVIDE):
-			right := p.parsePrimary()
-			expr = &BinaryExpr{Left: expr, Operator: DIVIDE, Right: right}
-		default:
-			return expr
-		}
+	start := p.mark()
+	expr := p.parseUnary()
+	for p.match(MULTIPLY, DIVIDE) {
+		operator := p.getPreviousToken().Type
+		right := p.parseUnary()
+		expr = &BinaryExpr{Left: expr, Operator: operator, Right: right}
+		p.finish(expr, start)
+	}
+	return expr
+}
+
+// parseUnary 解析一元正负号，如 `-1`。
+func (p *Parser) parseUnary() ASTNode {
+	start := p.mark()
+	if p.match(MINUS, PLUS) {
+		operator := p.getPreviousToken().Type
+		expr := &UnaryExpr{Operator: operator, Operand: p.parseUnary()}
+		p.finish(expr, start)
+		return expr
 	}
+	return p.parsePrimary()
 }
 
 func (p *Parser) parsePrimary() ASTNode {
-	if token := p.expect(NUMBER); token != nil {
+	start := p.mark()
+	if p.match(LEFT_PAREN) {
+		if p.isSubquery() {
+			return p.parseSubquery()
+		}
+		// 括号内表达式的范围不含括号
+		expr := p.parseExpression()
+		p.expect(RIGHT_PAREN)
+		return expr
+	}
+	expr := p.parseOperand()
+	p.finish(expr, start)
+	return expr
+}
+
+// parseOperand 解析字面量、标识符、函数调用等不带括号的基本表达式。
+func (p *Parser) parseOperand() ASTNode {
+	if token := p.accept(NUMBER); token != nil {
 		return &NumberLiteral{Value: token.Value}
 	}
-	if token := p.expect(STRING); token != nil {
+	if token := p.accept(STRING); token != nil {
 		return &StringLiteral{Value: token.Value}
 	}
 	if p.match(NULL) {
 		return &NullLiteral{}
 	}
-	if token := p.expect(TRUE); token != nil {
+	if p.match(PLACEHOLDER) {
+		return &Placeholder{}
+	}
+	if token := p.accept(TRUE); token != nil {
 		return &BooleanLiteral{Value: true}
 	}
-	if token := p.expect(FALSE); token != nil {
+	if token := p.accept(FALSE); token != nil {
 		return &BooleanLiteral{Value: false}
 	}
+	if p.match(CASE) {
+		return p.parseCaseExpr()
+	}
 	if p.peek().Type == FUNCTION {
+		if strings.EqualFold(p.peek().Value, "IF") {
+			return p.parseIfFunction()
+		}
 		return p.parseFunctionCall()
 	}
-	if token := p.expect(IDENTIFIER); token != nil {
+	if token := p.accept(IDENTIFIER); token != nil {
 		return &Identifier{Name: token.Value}
 	}
-	if p.match(LEFT_PAREN) {
-		if p.isSubquery() {
-			return p.parseSubquery()
+	if p.match(MULTIPLY, STAR) { // COUNT(*)
+		return &Star{}
+	}
+	if p.match(EXISTS) {
+		if !p.match(LEFT_PAREN) || !p.isSubquery() {
+			p.errorf("expected subquery after EXISTS")
+			return nil
 		}
-		expr := p.parseExpression()
-		p.expect(RIGHT_PAREN)
-		return expr
+		return &ExistsExpr{Subquery: p.parseSubquery()}
 	}
 
-	// 如果没有匹配到任何已知的模式，抛出错误或返回nil
+	p.errorf("expected expression, got %q", p.peek().Value)
 	return nil
 }
 
 func (p *Parser) parseFunctionCall() *FunctionCall {
+	start := p.mark()
 	funcName := p.currentToken().Value
 	p.expect(FUNCTION)
 	p.expect(LEFT_PAREN)
@@ -158,17 +248,114 @@ func (p *Parser) parseFunctionCall() *FunctionCall {
 		}
 		p.expect(RIGHT_PAREN)
 	}
-	return &FunctionCall{Name: funcName, Args: args, Distinct: isDistinct}
+	call := &FunctionCall{Name: funcName, Args: args, Distinct: isDistinct}
+	p.finish(call, start)
+	return call
+}
+
+// parseCaseExpr 解析CASE表达式，调用前CASE已被消费。
+// 同时支持简单CASE和搜索式CASE，分支中可以继续嵌套CASE。
+func (p *Parser) parseCaseExpr() *CaseExpr {
+	caseExpr := &CaseExpr{}
+	if p.peek().Type != WHEN {
+		caseExpr.Expr = p.parseExpression()
+	}
+	for p.peek().Type == WHEN {
+		start := p.mark()
+		p.advance()
+		branch := &CaseBranch{Condition: p.parseExpression()}
+		if !p.match(THEN) {
+			p.errorf("expected THEN in CASE, got %q", p.peek().Value)
+		}
+		branch.Result = p.parseExpression()
+		p.finish(branch, start)
+		caseExpr.Branches = append(caseExpr.Branches, branch)
+	}
+	if len(caseExpr.Branches) == 0 {
+		p.errorf("expected WHEN in CASE, got %q", p.peek().Value)
+	}
+	if p.match(ELSE) {
+		caseExpr.Default = p.parseExpression()
+	}
+	if !p.match(END) {
+		p.errorf("expected END to close CASE, got %q", p.peek().Value)
+	}
+	return caseExpr
+}
+
+// parseIfFunction 把MySQL的 IF(cond, a, b) 解析为只有一个分支的CASE表达式，
+// 这样条件延时之类的分析只需要处理CaseExpr。
+func (p *Parser) parseIfFunction() ASTNode {
+	start := p.mark()
+	call := p.parseFunctionCall()
+	if len(call.Args) != 3 {
+		// 参数个数不对时保留为普通函数调用
+		return call
+	}
+	branch := &CaseBranch{Condition: call.Args[0], Result: call.Args[1]}
+	p.finish(branch, start)
+	return &CaseExpr{
+		Branches:   []*CaseBranch{branch},
+		Default:    call.Args[2],
+		IfFunction: true,
+	}
 }
 
 func (p *Parser) isSubquery() bool {
 	// 这里只是一个简单的检查，实际上可能需要更复杂的逻辑来检查是否是子查询
-	return p.peek().Type == SELECT
+	return p.peek().Type == SELECT || p.peek().Type == WITH
 }
 
+// parseSubquery 解析括号内的SELECT子查询，调用前左括号已被消费。
 func (p *Parser) parseSubquery() *Subquery {
-	p.expect(LEFT_PAREN)
-	stmt := p.parseSelectStatement() // 这里你应该已经有一个解析SELECT语句的函数
+	start := p.getPreviousToken().Pos
+	stmt := p.parseQuery()
 	p.expect(RIGHT_PAREN)
-	return &Subquery{Statement: stmt}
+	subquery := &Subquery{Statement: stmt}
+	p.finish(subquery, start)
+	return subquery
+}
+
+// parseQuery 解析 [WITH ...] SELECT ... 形式的查询，当前令牌应为WITH或SELECT。
+func (p *Parser) parseQuery() *SelectStatement {
+	start := p.mark()
+	var with *WithClause
+	if p.match(WITH) {
+		with = p.parseWithClause()
+	}
+	if !p.match(SELECT) {
+		p.errorf("expected SELECT, got %q", p.peek().Value)
+		stmt := &SelectStatement{With: with}
+		p.finish(stmt, start)
+		return stmt
+	}
+	stmt := p.parseSelectStatement()
+	stmt.With = with
+	p.finish(stmt, start)
+	return stmt
+}
+
+// parseWithClause 解析WITH之后的公共表表达式列表，调用前WITH已被消费。
+func (p *Parser) parseWithClause() *WithClause {
+	start := p.getPreviousToken().Pos
+	with := &WithClause{Recursive: p.match(RECURSIVE)}
+	defer p.finish(with, start)
+	for {
+		cteStart := p.mark()
+		cte := &CommonTableExpression{Name: p.parseName()}
+		if p.peek().Type == LEFT_PAREN {
+			cte.Columns = p.parseNameList()
+		}
+		if !p.match(AS) || !p.match(LEFT_PAREN) {
+			p.errorf("expected AS ( after common table expression %s, got %q", cte.Name, p.peek().Value)
+			return with
+		}
+		cte.Select = p.parseSubquery().Statement
+		p.finish(cte, cteStart)
+		with.CTEs = append(with.CTEs, cte)
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	return with
 }
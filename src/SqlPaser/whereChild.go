@@ -1,16 +1,18 @@
 package SqlPaser
 
-import "strconv"
-
 // parseGroupByClause 解析 GROUP BY 子句。
 func (p *Parser) parseGroupByClause() *GroupByClause {
 	groupBy := &GroupByClause{}
+	start := p.getPreviousToken().Pos
+	defer p.finish(groupBy, start)
 	// 期望紧跟着'BY'
 	p.expect(BY)
 	// 解析 GROUP BY 后的列
 	for {
 		column := p.expect(IDENTIFIER)
-		groupBy.Columns = append(groupBy.Columns, &Identifier{Name: column.Value})
+		identifier := &Identifier{Name: column.Value}
+		p.finish(identifier, column.Pos)
+		groupBy.Columns = append(groupBy.Columns, identifier)
 
 		if !p.match(COMMA) {
 			break
@@ -23,25 +25,32 @@ func (p *Parser) parseGroupByClause() *GroupByClause {
 // parseHavingClause 解析 HAVING 子句。
 func (p *Parser) parseHavingClause() *HavingClause {
 	having := &HavingClause{}
+	start := p.getPreviousToken().Pos
 	having.Condition = p.parseExpression()
+	p.finish(having, start)
 	return having
 }
 
 // parseOrderByClause 解析 ORDER BY 子句。
 func (p *Parser) parseOrderByClause() *OrderByClause {
 	orderBy := &OrderByClause{}
+	start := p.getPreviousToken().Pos
+	defer p.finish(orderBy, start)
 	// 期望紧跟着'BY'
 	p.expect(BY)
 	// 解析 ORDER BY 后的列和排序方向（ASC 或 DESC）
 	for {
 		column := p.expect(IDENTIFIER)
-		order := &OrderByExpression{Column: &Identifier{Name: column.Value}}
+		identifier := &Identifier{Name: column.Value}
+		p.finish(identifier, column.Pos)
+		order := &OrderByExpression{Column: identifier}
 
 		if p.match(ASC) {
 			order.Direction = ASC
 		} else if p.match(DESC) {
 			order.Direction = DESC
 		}
+		p.finish(order, column.Pos)
 
 		orderBy.Columns = append(orderBy.Columns, order)
 
@@ -56,36 +65,65 @@ func (p *Parser) parseOrderByClause() *OrderByClause {
 // parseLimitClause 解析 LIMIT 子句。
 func (p *Parser) parseLimitClause() *LimitClause {
 	limit := &LimitClause{}
-	limitValue := p.expect(NUMBER)
-	limit.Count, _ = strconv.Atoi(limitValue.Value)
+	start := p.getPreviousToken().Pos
+	defer p.finish(limit, start)
+	limit.Count = p.parseExpression()
 
+	// 行数和偏移量通常是数字或占位符，如 10、? 或 $1
 	// 如果有 OFFSET 关键字，解析它
 	if p.match(OFFSET) {
+		limit.Offset = p.parseExpression()
+	} else if p.match(COMMA) {
+		// MySQL 的 LIMIT offset, count
 		limit.Offset = limit.Count
+		limit.Count = p.parseExpression()
 	}
 
 	return limit
 }
 
-// 新增的解析 JOIN 子句的函数
-func (p *Parser) parseJoinClause(joinType TokenType) *JoinClause {
+// parseJoinClause 解析JOIN关键字之后的表、别名以及ON或USING条件。
+func (p *Parser) parseJoinClause(joinType TokenType, natural bool) *JoinClause {
 	join := &JoinClause{
-		Type: joinType,
+		Type:    joinType,
+		Natural: natural,
 	}
-
-	// 解析 JOIN 后的表名
-	join.Table = &Identifier{Name: p.expect(IDENTIFIER).Value}
-
-	// 检查是否有 AS 别名
-	if p.match(AS) {
-		p.advance() // 跳过 AS
-		join.Alias = &AliasClause{Alias: p.expect(IDENTIFIER).Value}
+	// JOIN 关键字可能有多个令牌，如 NATURAL LEFT OUTER JOIN
+	start := p.getPreviousToken().Pos
+	for i := p.current - 2; i >= 0 && isJoinKeyword(p.tokens[i].Type); i-- {
+		start = p.tokens[i].Pos
+	}
+	defer p.finish(join, start)
+
+	// 解析 JOIN 后的表名或派生表
+	if p.match(LEFT_PAREN) {
+		join.Derived = p.parseDerivedTable()
+	} else {
+		tableStart := p.mark()
+		join.Table = &Identifier{Name: p.parseName()}
+		p.finish(join.Table, tableStart)
+		aliasStart := p.mark()
+		if alias := p.parseAlias(); alias != "" {
+			join.Alias = &AliasClause{Alias: alias}
+			p.finish(join.Alias, aliasStart)
+		}
 	}
 
-	// 解析 ON 子句
+	// 解析 ON 或 USING 子句
 	if p.match(ON) {
 		join.On = p.parseWhereClause()
+	} else if p.match(USING) {
+		join.Using = p.parseNameList()
 	}
 
 	return join
 }
+
+// isJoinKeyword 判断令牌是否属于JOIN关键字序列。
+func isJoinKeyword(t TokenType) bool {
+	switch t {
+	case NATURAL, INNER, CROSS, LEFT, RIGHT, FULL, OUTER:
+		return true
+	}
+	return false
+}
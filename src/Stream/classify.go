@@ -0,0 +1,527 @@
+package Stream
+
+import (
+	"HawkEye-Go/src/Engine"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// InputFormat 是输入的格式。
+type InputFormat int
+
+const (
+	INPUT_LINES  InputFormat = iota // 每行是一个载荷
+	INPUT_NDJSON                    // 每行是一个JSON对象，载荷在 Field 指定的字段中
+)
+
+var inputFormatNames = []string{"lines", "ndjson"}
+
+func (f InputFormat) String() string {
+	if f >= 0 && int(f) < len(inputFormatNames) {
+		return inputFormatNames[f]
+	}
+	return fmt.Sprintf("InputFormat(%d)", int(f))
+}
+
+func (f *InputFormat) UnmarshalText(text []byte) error {
+	for i, name := range inputFormatNames {
+		if strings.EqualFold(string(text), name) {
+			*f = InputFormat(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown input format %q", text)
+}
+
+// Explain 决定输出中解释结论的详细程度。
+type Explain int
+
+const (
+	EXPLAIN_NONE     Explain = iota
+	EXPLAIN_RULES            // 命中的规则和原因
+	EXPLAIN_FEATURES         // 另外输出模型使用的特征
+)
+
+var explainNames = []string{"none", "rules", "features"}
+
+func (e Explain) String() string {
+	if e >= 0 && int(e) < len(explainNames) {
+		return explainNames[e]
+	}
+	return fmt.Sprintf("Explain(%d)", int(e))
+}
+
+func (e *Explain) UnmarshalText(text []byte) error {
+	for i, name := range explainNames {
+		if strings.EqualFold(string(text), name) {
+			*e = Explain(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown explain level %q", text)
+}
+
+// OutputFields 是可以输出的结论字段。
+var OutputFields = []string{"score", "block", "probability", "context", "normalized"}
+
+// DefaultFields 是默认输出的字段。
+var DefaultFields = []string{"score", "block"}
+
+// Classifier 从输入中逐行读取载荷并发检查，按输入顺序输出加上结论的NDJSON。
+// 同时处理中的记录不超过 Window 条，输出跟不上时读取会暂停，内存占用与输入大小无关。
+type Classifier struct {
+	Inspector *Engine.RequestInspector
+	Input     InputFormat
+	Field     string   // NDJSON中载荷的字段路径，用点分隔，数组下标写作数字，如 request.params.0
+	Fields    []string // 输出的结论字段，见 OutputFields
+	Explain   Explain
+	Key       string // NDJSON输出中结论所在的字段名
+	Workers   int    // 不大于0时使用CPU数量
+	Window    int    // 不大于0时为 Workers 的16倍
+	MaxLine   int    // 一行的最大字节数，超出的行报告错误，不大于0时为1MB
+}
+
+// NewClassifier 返回使用给定 Detector、按行读取的分类器。
+func NewClassifier(detector *Engine.Detector) *Classifier {
+	return &Classifier{Inspector: Engine.NewRequestInspector(detector), Fields: DefaultFields, Key: "hawkeye"}
+}
+
+// Stats 是一次运行的统计。
+type Stats struct {
+	Records int
+	Blocked int
+	Errors  int // 无法解析或者找不到载荷的记录
+}
+
+// job 是一条记录，result在检查完成后写入。
+type job struct {
+	line   int
+	raw    []byte
+	result chan []byte
+	block  bool
+	failed bool
+}
+
+// Run 处理r中的所有记录并写入w。输出出错时停止读取并返回错误。
+func (c *Classifier) Run(r io.Reader, w io.Writer) (Stats, error) {
+	if err := c.validate(); err != nil {
+		return Stats{}, err
+	}
+	workers := c.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	window := c.Window
+	if window <= 0 {
+		window = workers * 16
+	}
+
+	jobs := make(chan *job)
+	order := make(chan *job, window) // 限制同时处理的记录数
+	done := make(chan struct{})
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(order)
+		defer close(jobs)
+		readErr <- c.read(r, func(j *job) bool {
+			select {
+			case <-done:
+				return false
+			default:
+			}
+			select {
+			case order <- j:
+			case <-done:
+				return false
+			}
+			jobs <- j
+			return true
+		})
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range jobs {
+				j.result <- c.processSafely(j)
+			}
+		}()
+	}
+
+	var stats Stats
+	output := bufio.NewWriter(w)
+	var writeErr error
+	for j := range order {
+		result := <-j.result
+		if writeErr != nil {
+			continue
+		}
+		stats.Records++
+		if j.block {
+			stats.Blocked++
+		}
+		if j.failed {
+			stats.Errors++
+		}
+		if _, writeErr = output.Write(result); writeErr != nil {
+			close(done)
+		}
+	}
+	if writeErr != nil {
+		return stats, writeErr
+	}
+	if err := output.Flush(); err != nil {
+		return stats, err
+	}
+	return stats, <-readErr
+}
+
+func (c *Classifier) validate() error {
+	for _, field := range c.Fields {
+		if !contains(OutputFields, field) {
+			return fmt.Errorf("unknown output field %q, expected one of %s", field, strings.Join(OutputFields, ", "))
+		}
+	}
+	if c.Input == INPUT_NDJSON && c.Field == "" {
+		return fmt.Errorf("no payload field for NDJSON input")
+	}
+	return nil
+}
+
+// read 逐行读取输入，超过 MaxLine 的行只保留开头用于报告。
+func (c *Classifier) read(r io.Reader, send func(*job) bool) error {
+	maxLine := c.MaxLine
+	if maxLine <= 0 {
+		maxLine = 1 << 20
+	}
+	reader := bufio.NewReaderSize(r, 64*1024)
+	line := 0
+	for {
+		var raw []byte
+		tooLong := false
+		for {
+			chunk, isPrefix, err := reader.ReadLine()
+			if err == io.EOF && (len(raw) > 0 || tooLong) {
+				break
+			}
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			if len(raw)+len(chunk) > maxLine {
+				tooLong = true
+			} else {
+				raw = append(raw, chunk...)
+			}
+			if !isPrefix {
+				break
+			}
+		}
+		line++
+		if len(bytes.TrimSpace(raw)) == 0 && !tooLong {
+			continue
+		}
+		j := &job{line: line, raw: raw, result: make(chan []byte, 1)}
+		if tooLong {
+			j.raw = nil
+			j.failed = true
+		}
+		if !send(j) {
+			return nil
+		}
+	}
+}
+
+// processSafely 调用 process，检查过程中的panic转换为这条记录的错误输出，
+// 不会终止worker，输出的顺序也不受影响。
+func (c *Classifier) processSafely(j *job) (line []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			j.block, j.failed = false, true
+			line = c.errorRecord(j, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+	return c.process(j)
+}
+
+// process 检查一条记录并返回输出的一行。
+func (c *Classifier) process(j *job) []byte {
+	if j.raw == nil {
+		return c.errorRecord(j, fmt.Sprintf("line exceeds %d bytes", c.maxLine()))
+	}
+	payload := string(j.raw)
+	if c.Input == INPUT_NDJSON {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(j.raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			j.failed = true
+			return c.errorRecord(j, "invalid JSON: "+err.Error())
+		}
+		if _, ok := value.(map[string]interface{}); !ok {
+			j.failed = true
+			return c.errorRecord(j, "record is not a JSON object")
+		}
+		var ok bool
+		if payload, ok = lookup(value, c.Field); !ok {
+			j.failed = true
+			return c.errorRecord(j, "field "+c.Field+" not found")
+		}
+	}
+
+	verdict := c.Inspector.CheckParameter(Engine.Parameter{Source: "stream", Name: strconv.Itoa(j.line), Value: payload})
+	j.block = verdict.Block
+	var result bytes.Buffer
+	result.WriteByte('{')
+	for i, field := range c.Fields {
+		if i > 0 {
+			result.WriteByte(',')
+		}
+		var value interface{}
+		switch field {
+		case "score":
+			value = verdict.Score
+		case "block":
+			value = verdict.Block
+		case "probability":
+			value = verdict.Probability
+		case "context":
+			value = verdict.Context
+		case "normalized":
+			value = verdict.Normalized
+		}
+		writeField(&result, field, value)
+	}
+	if c.Explain >= EXPLAIN_RULES {
+		hits := verdict.Hits
+		if hits == nil {
+			hits = []Engine.RuleHit{}
+		}
+		writeField(&result, ",rules", hits)
+		writeField(&result, ",reason", verdict.Reason)
+	}
+	if c.Explain >= EXPLAIN_FEATURES {
+		writeField(&result, ",features", Engine.ExtractFeatures(verdict.Normalized))
+	}
+	result.WriteByte('}')
+	return c.record(j, payload, result.Bytes())
+}
+
+// record 生成输出行：按行输入时为包含行号和载荷的新对象，NDJSON输入时在原对象末尾加上 Key 字段，原有内容保持不变。
+func (c *Classifier) record(j *job, payload string, result []byte) []byte {
+	var line bytes.Buffer
+	if c.Input == INPUT_NDJSON && j.raw != nil && !j.failed {
+		object := bytes.TrimSpace(j.raw)
+		object = bytes.TrimSpace(object[:len(object)-1]) // 去掉结尾的 }
+		line.Write(object)
+		if object[len(object)-1] != '{' {
+			line.WriteByte(',')
+		}
+		writeField(&line, c.Key, json.RawMessage(result))
+		line.WriteString("}\n")
+		return line.Bytes()
+	}
+	line.WriteByte('{')
+	writeField(&line, "line", j.line)
+	if j.raw != nil {
+		writeField(&line, ",payload", payload)
+	}
+	line.WriteByte(',')
+	writeField(&line, c.Key, json.RawMessage(result))
+	line.WriteString("}\n")
+	return line.Bytes()
+}
+
+func (c *Classifier) errorRecord(j *job, message string) []byte {
+	var result bytes.Buffer
+	result.WriteByte('{')
+	writeField(&result, "error", message)
+	result.WriteByte('}')
+	return c.record(j, string(j.raw), result.Bytes())
+}
+
+func (c *Classifier) maxLine() int {
+	if c.MaxLine <= 0 {
+		return 1 << 20
+	}
+	return c.MaxLine
+}
+
+// writeField 写入 "name":value，name以逗号开头时先写逗号。
+func writeField(buf *bytes.Buffer, name string, value interface{}) {
+	if strings.HasPrefix(name, ",") {
+		buf.WriteByte(',')
+		name = name[1:]
+	}
+	key, _ := json.Marshal(name)
+	buf.Write(key)
+	buf.WriteByte(':')
+	data, err := json.Marshal(value)
+	if err != nil {
+		data = []byte("null")
+	}
+	buf.Write(data)
+}
+
+// lookup 按点分路径查找JSON中的值，非字符串的标量转换为其JSON文本。
+func lookup(value interface{}, path string) (string, bool) {
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[part]; !ok {
+				return "", false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			value = v[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestClassifier(t *testing.T) {
+	detector := &Engine.Detector{Rules: Engine.DefaultRuleSet(), Policy: Engine.DefaultPolicy}
+
+	// 按行输入，顺序与输入一致
+	var input strings.Builder
+	for i := 0; i < 300; i++ {
+		if i%3 == 0 {
+			fmt.Fprintf(&input, "%d OR 1=1\n", i)
+		} else {
+			fmt.Fprintf(&input, "user %d\n", i)
+		}
+	}
+	input.WriteString("\n" + strings.Repeat("x", 200) + "\nlast")
+	c := NewClassifier(detector)
+	c.Workers, c.Window, c.MaxLine = 4, 3, 100
+	var output bytes.Buffer
+	stats, err := c.Run(strings.NewReader(input.String()), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 302 || stats.Blocked != 100 || stats.Errors != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	for i, line := range lines {
+		var record struct {
+			Line    int
+			Payload string
+			Hawkeye struct {
+				Block bool
+				Error string
+			}
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid output %q: %v", line, err)
+		}
+		switch {
+		case i < 300:
+			if record.Line != i+1 || record.Hawkeye.Block != (i%3 == 0) {
+				t.Errorf("Unexpected record %d: %s", i, line)
+			}
+		case i == 300:
+			if record.Line != 302 || record.Hawkeye.Error == "" {
+				t.Errorf("Expected long line error, got %s", line)
+			}
+		case i == 301:
+			if record.Payload != "last" {
+				t.Errorf("Expected last line without newline, got %s", line)
+			}
+		}
+	}
+
+	// NDJSON输入，结论追加在原对象中
+	c = NewClassifier(detector)
+	c.Input, c.Field, c.Explain = INPUT_NDJSON, "request.params.1", EXPLAIN_RULES
+	c.Fields = []string{"block", "context"}
+	output.Reset()
+	stats, err = c.Run(strings.NewReader(`{"id":1,"request":{"params":["a","1' OR '1'='1"]}}
+{"id":2,"request":{"params":["a",42]}}
+{"id":3}
+not json
+`), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`{"id":1,"request":{"params":["a","1' OR '1'='1"]},"hawkeye":{"block":true,"context":"quoted","rules":[{"id":"HE-009","description":"OR with an always-true operand such as OR 1=1","severity":"high"},{"id":"HE-012","description":"always-true or always-false condition","severity":"low"}],"reason":"rule HE-009 (high): OR with an always-true operand such as OR 1=1"}}`,
+		`{"id":2,"request":{"params":["a",42]},"hawkeye":{"block":false,"context":"raw","rules":[],"reason":""}}`,
+		`{"line":3,"payload":"{\"id\":3}","hawkeye":{"error":"field request.params.1 not found"}}`,
+		`{"line":4,"payload":"not json","hawkeye":{"error":"invalid JSON: invalid character 'o' in literal null (expecting 'u')"}}`,
+	}
+	if got := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected NDJSON output:\n%s", output.String())
+	}
+	if stats.Records != 4 || stats.Blocked != 1 || stats.Errors != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	c.Fields = []string{"bogus"}
+	if _, err := c.Run(strings.NewReader(""), &output); err == nil {
+		t.Errorf("Expected error for unknown output field")
+	}
+
+	// 检查时panic的记录输出为错误，其他记录不受影响
+	c = NewClassifier(&Engine.Detector{Rules: panicRules{detector.Rules}, Policy: Engine.DefaultPolicy})
+	c.Workers = 2
+	output.Reset()
+	stats, err = c.Run(strings.NewReader("a\nUPDATE- \nb\n"), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		`{"line":1,"payload":"a","hawkeye":{"score":0,"block":false}}`,
+		`{"line":2,"payload":"UPDATE- ","hawkeye":{"error":"internal error: evaluate UPDATE-"}}`,
+		`{"line":3,"payload":"b","hawkeye":{"score":0,"block":false}}`,
+	}
+	if got := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected output after panic:\n%s", output.String())
+	}
+	if stats.Records != 3 || stats.Errors != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+// panicRules 在载荷以 UPDATE- 开头时panic，用于测试分类器的恢复。
+type panicRules struct {
+	Engine.RuleEvaluator
+}
+
+func (r panicRules) Evaluate(sql string) []Engine.RuleHit {
+	if strings.HasPrefix(sql, "UPDATE-") {
+		panic("evaluate " + sql)
+	}
+	return r.RuleEvaluator.Evaluate(sql)
+}
@@ -0,0 +1,8 @@
+package Stream
+
+import "testing"
+
+// TestStream 运行与被测代码写在同一文件中的测试函数。
+func TestStream(t *testing.T) {
+	t.Run("Classifier", TestClassifier)
+}
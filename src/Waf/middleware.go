@@ -0,0 +1,88 @@
+package Waf
+
+import (
+	"HawkEye-Go/src/Engine"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type reportKey struct{}
+
+// withReport 把检查结果放入请求的上下文，供后面的处理器使用。
+func withReport(r *http.Request, report *Engine.InspectionReport) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), reportKey{}, report))
+}
+
+// ReportFromContext 返回WAF对当前请求的检查结果，请求没有经过检查时返回nil。
+// 只检测模式下应用可以据此记录或降级处理可疑请求。
+func ReportFromContext(ctx context.Context) *Engine.InspectionReport {
+	report, _ := ctx.Value(reportKey{}).(*Engine.InspectionReport)
+	return report
+}
+
+// Middleware 返回在应用内检查请求参数的 http.Handler 中间件，路由、允许列表和拦截响应与反向代理模式相同：
+//
+//	protect, err := Waf.Middleware(&Waf.Config{Detector: detector})
+//	http.ListenAndServe(":8080", protect(mux))
+func Middleware(config *Config) (func(http.Handler) http.Handler, error) {
+	if _, err := Wrap(config, http.NotFoundHandler()); err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		w, _ := Wrap(config, next)
+		return w
+	}, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	if _, err := Middleware(&Config{}); err == nil {
+		t.Errorf("Expected error for config without detector")
+	}
+	protect, err := Middleware(&Config{
+		Detector: &Engine.Detector{Rules: Engine.DefaultRuleSet(), Policy: Engine.DefaultPolicy},
+		Routes:   []*Route{{Prefix: "/search", DetectOnly: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		flagged := 0
+		if report := ReportFromContext(r.Context()); report != nil {
+			for _, p := range report.Parameters {
+				if p.Block {
+					flagged++
+				}
+			}
+		}
+		fmt.Fprintf(rw, "%s flagged=%d", body, flagged)
+	}))
+
+	tests := []struct {
+		method, target, body string
+		status               int
+		response             string
+	}{
+		{"GET", "/users?id=7", "", 200, " flagged=0"},
+		{"GET", "/users?id=7%27%20UNION%20SELECT%20table_name%20FROM%20information_schema.tables--%20-", "", 403, "Forbidden\n"},
+		{"POST", "/users", `{"name":"bob","age":30}`, 200, `{"name":"bob","age":30} flagged=0`},
+		{"POST", "/users", `{"name":"bob' OR '1'='1"}`, 403, "Forbidden\n"},
+		{"GET", "/search?q=1%20OR%201=1", "", 200, " flagged=1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		if rw.Code != tt.status || rw.Body.String() != tt.response {
+			t.Errorf("%s %s: expected %d %q, got %d %q", tt.method, tt.target, tt.status, tt.response, rw.Code, rw.Body.String())
+		}
+	}
+}
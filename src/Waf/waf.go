@@ -0,0 +1,307 @@
+package Waf
+
+import (
+	"HawkEye-Go/src/Engine"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Route 是一组路径的检查策略，按最长的路径前缀匹配。
+// 前缀按完整的路径段匹配，"/api" 匹配 "/api" 和 "/api/users"，不匹配 "/apiadmin"。
+type Route struct {
+	Prefix     string         `yaml:"prefix"`
+	Policy     *Engine.Policy `yaml:"policy,omitempty"` // 为nil时使用 Detector 的策略
+	DetectOnly bool           `yaml:"detect_only,omitempty"`
+	Skip       bool           `yaml:"skip,omitempty"` // 不检查该路径下的请求
+	// IgnoreParameters 是不参与拦截判断的参数，格式为 "来源:名称" 或只写名称，如 "header:User-Agent"、"sql"。
+	IgnoreParameters []string `yaml:"ignore_parameters,omitempty"`
+}
+
+// BlockResponse 是拦截请求时返回的响应。
+type BlockResponse struct {
+	Status      int    `yaml:"status"`
+	Body        string `yaml:"body"`
+	ContentType string `yaml:"content_type"`
+}
+
+// Config 是反向代理WAF的配置，可以从YAML文件加载，见 LoadConfig。
+type Config struct {
+	Upstream     string        `yaml:"upstream"`
+	DetectOnly   bool          `yaml:"detect_only"` // 只记录不拦截
+	Block        BlockResponse `yaml:"block"`
+	AllowClients []string      `yaml:"allow_clients"` // 不检查的客户端地址，IP或CIDR
+	Routes       []*Route      `yaml:"routes"`
+
+	Detector *Engine.Detector `yaml:"-"`
+	Logger   *log.Logger      `yaml:"-"` // 记录拦截和检测到的请求，为nil时不记录
+}
+
+// DefaultBlockResponse 是没有配置时的拦截响应。
+var DefaultBlockResponse = BlockResponse{Status: http.StatusForbidden, Body: "Forbidden\n", ContentType: "text/plain; charset=utf-8"}
+
+// LoadConfig 从YAML文件加载配置，Detector 和 Logger 需要调用方设置。
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// WAF 检查请求并把放行的请求交给下一个处理器，反向代理模式下是上游应用的代理。
+type WAF struct {
+	config *Config
+	next   http.Handler
+	routes []*route
+	allow  []*net.IPNet
+	// root 是没有匹配任何路由时使用的设置。
+	root *route
+}
+
+// route 是编译后的 Route，每个路由使用自己的检查器以应用不同的策略。
+type route struct {
+	*Route
+	prefix    string // 规范化后的前缀
+	inspector *Engine.RequestInspector
+	ignore    map[string]bool
+}
+
+// New 根据配置创建转发到 Upstream 的反向代理WAF。
+func New(config *Config) (*WAF, error) {
+	upstream, err := url.Parse(config.Upstream)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		return nil, fmt.Errorf("invalid upstream %q", config.Upstream)
+	}
+	return Wrap(config, httputil.NewSingleHostReverseProxy(upstream))
+}
+
+// Wrap 创建检查请求后交给next处理的WAF，配置中的 Upstream 被忽略。
+func Wrap(config *Config, next http.Handler) (*WAF, error) {
+	if config.Detector == nil {
+		return nil, fmt.Errorf("no detector")
+	}
+	if config.Block.Status == 0 {
+		config.Block = DefaultBlockResponse
+	}
+	w := &WAF{config: config, next: next}
+	w.root = w.compileRoute(&Route{Prefix: "/"})
+	for _, r := range config.Routes {
+		w.routes = append(w.routes, w.compileRoute(r))
+	}
+	// 最长前缀优先
+	sort.SliceStable(w.routes, func(i, j int) bool { return len(w.routes[i].prefix) > len(w.routes[j].prefix) })
+
+	for _, client := range config.AllowClients {
+		if !strings.Contains(client, "/") {
+			if strings.Contains(client, ":") {
+				client += "/128"
+			} else {
+				client += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(client)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client address %q", client)
+		}
+		w.allow = append(w.allow, network)
+	}
+	return w, nil
+}
+
+func (w *WAF) compileRoute(r *Route) *route {
+	detector := w.config.Detector
+	if r.Policy != nil {
+		detector = &Engine.Detector{Rules: detector.Rules, Model: detector.Model, Policy: *r.Policy}
+	}
+	compiled := &route{Route: r, prefix: cleanPath(r.Prefix), inspector: Engine.NewRequestInspector(detector), ignore: make(map[string]bool)}
+	for _, parameter := range r.IgnoreParameters {
+		compiled.ignore[strings.ToLower(parameter)] = true
+	}
+	return compiled
+}
+
+// match 返回请求路径对应的路由，路径先规范化，"/static/../login" 按 "/login" 匹配。
+func (w *WAF) match(p string) *route {
+	p = cleanPath(p)
+	for _, r := range w.routes {
+		if r.prefix == "/" || p == r.prefix || strings.HasPrefix(p, r.prefix+"/") {
+			return r
+		}
+	}
+	return w.root
+}
+
+// cleanPath 返回以 "/" 开头、去掉 "."、".." 和末尾 "/" 的路径。
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// ignored 判断参数是否被路由忽略。
+func (r *route) ignored(p *Engine.ParameterVerdict) bool {
+	return r.ignore[strings.ToLower(p.Name)] || r.ignore[strings.ToLower(p.Source+":"+p.Name)]
+}
+
+func (w *WAF) allowedClient(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	for _, network := range w.allow {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *WAF) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	route := w.match(r.URL.Path)
+	if route.Skip || w.allowedClient(r) {
+		w.next.ServeHTTP(rw, r)
+		return
+	}
+	detectOnly := w.config.DetectOnly || route.DetectOnly
+
+	report, err := route.inspector.Inspect(r)
+	if err != nil {
+		w.logf("%s %s %s: inspection failed: %v", r.RemoteAddr, r.Method, r.URL.Path, err)
+		if !detectOnly {
+			// 没有检查完的请求体不转发
+			if errors.Is(err, Engine.ErrBodyTooLarge) {
+				http.Error(rw, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(rw, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		w.next.ServeHTTP(rw, r)
+		return
+	}
+
+	var reasons []string
+	for _, p := range report.Parameters {
+		if p.Block && !route.ignored(p) {
+			reasons = append(reasons, fmt.Sprintf("%s:%s %q (%s)", p.Source, p.Name, p.Value, p.Reason))
+		}
+	}
+	if len(reasons) == 0 {
+		w.next.ServeHTTP(rw, withReport(r, report))
+		return
+	}
+	if detectOnly {
+		w.logf("%s %s %s: detected %s", r.RemoteAddr, r.Method, r.URL.Path, strings.Join(reasons, ", "))
+		w.next.ServeHTTP(rw, withReport(r, report))
+		return
+	}
+	w.logf("%s %s %s: blocked %s", r.RemoteAddr, r.Method, r.URL.Path, strings.Join(reasons, ", "))
+	rw.Header().Set("Content-Type", w.config.Block.ContentType)
+	rw.WriteHeader(w.config.Block.Status)
+	io.WriteString(rw, w.config.Block.Body)
+}
+
+func (w *WAF) logf(format string, args ...interface{}) {
+	if w.config.Logger != nil {
+		w.config.Logger.Printf(format, args...)
+	}
+}
+
+func TestWAF(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(rw, "%s %s %s", r.Method, r.URL.RequestURI(), body)
+	}))
+	defer upstream.Close()
+
+	var logs strings.Builder
+	config := &Config{
+		Upstream:     upstream.URL,
+		Block:        BlockResponse{Status: http.StatusNotAcceptable, Body: "blocked", ContentType: "text/plain"},
+		AllowClients: []string{"198.51.100.0/24"},
+		Routes: []*Route{
+			{Prefix: "/admin/console", Skip: true},
+			{Prefix: "/reports/", DetectOnly: true},
+			{Prefix: "/api", IgnoreParameters: []string{"query:filter", "header:X-Debug"}},
+			{Prefix: "/strict/", Policy: &Engine.Policy{Mode: Engine.POLICY_RULES_ONLY, BlockSeverity: Engine.SEVERITY_LOW}},
+		},
+		Detector: &Engine.Detector{Rules: Engine.DefaultRuleSet(), Policy: Engine.DefaultPolicy},
+		Logger:   log.New(&logs, "", 0),
+	}
+	waf, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 注入内容放在超过请求体上限的填充之后
+	padded := "pad=" + strings.Repeat("a", 1<<20) + "&user=admin'%20AND%20SLEEP(5)--%20-"
+	tests := []struct {
+		method, target, body, remote string
+		status                       int
+		response                     string
+	}{
+		{"GET", "/users?id=42", "", "", 200, "GET /users?id=42 "},
+		{"GET", "/users?id=42%20OR%201=1", "", "", 406, "blocked"},
+		{"GET", "/users?id=1;DROP%20TABLE%20users", "", "", 406, "blocked"},
+		{"POST", "/login", "user=admin'%20AND%20SLEEP(5)--%20-", "", 406, "blocked"},
+		{"POST", "/login", "user=admin&password=secret", "", 200, "POST /login user=admin&password=secret"},
+		{"POST", "/login", padded, "", 413, "Request Entity Too Large\n"},
+		{"GET", "/users?id=42%20OR%201=1", "", "198.51.100.7:5000", 200, "GET /users?id=42%20OR%201=1 "},
+		{"GET", "/admin/console?sql=DROP%20TABLE%20x;%20SELECT%20LOAD_FILE(1)", "", "", 200, "GET /admin/console?sql=DROP%20TABLE%20x;%20SELECT%20LOAD_FILE(1) "},
+		{"GET", "/reports/x?id=1%20OR%201=1", "", "", 200, "GET /reports/x?id=1%20OR%201=1 "},
+		{"GET", "/api/items?filter=1%20OR%201=1", "", "", 200, "GET /api/items?filter=1%20OR%201=1 "},
+		{"GET", "/api/items?id=1%20OR%201=1", "", "", 406, "blocked"},
+		{"GET", "/apiadmin?filter=1%20OR%201=1", "", "", 406, "blocked"},
+		{"GET", "/admin/console/../../users?id=1%20OR%201=1", "", "", 406, "blocked"},
+		{"GET", "/admin/consoles?id=1%20OR%201=1", "", "", 406, "blocked"},
+		{"GET", "/reports?id=1%20OR%201=1", "", "", 200, "GET /reports?id=1%20OR%201=1 "},
+		{"GET", "/strict/x?v=0x41424344", "", "", 406, "blocked"},
+		{"GET", "/x?v=0x41424344", "", "", 200, "GET /x?v=0x41424344 "},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.body != "" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if tt.remote != "" {
+			r.RemoteAddr = tt.remote
+		}
+		rw := httptest.NewRecorder()
+		waf.ServeHTTP(rw, r)
+		if rw.Code != tt.status || rw.Body.String() != tt.response {
+			t.Errorf("%s %s: expected %d %q, got %d %q", tt.method, tt.target, tt.status, tt.response, rw.Code, rw.Body.String())
+		}
+	}
+	if !strings.Contains(logs.String(), "detected query:id") || !strings.Contains(logs.String(), "blocked form:user") {
+		t.Errorf("Unexpected log:\n%s", logs.String())
+	}
+
+	// 整个WAF处于只检测模式
+	config.DetectOnly = true
+	rw := httptest.NewRecorder()
+	waf.ServeHTTP(rw, httptest.NewRequest("GET", "/users?id=42%20OR%201=1", nil))
+	if rw.Code != 200 {
+		t.Errorf("Expected detect-only mode to forward, got %d", rw.Code)
+	}
+
+	if _, err := New(&Config{Upstream: "localhost", Detector: config.Detector}); err == nil {
+		t.Errorf("Expected error for upstream without scheme")
+	}
+}
@@ -0,0 +1,9 @@
+package Waf
+
+import "testing"
+
+// TestWaf 运行与被测代码写在同一文件中的测试函数。
+func TestWaf(t *testing.T) {
+	t.Run("Middleware", TestMiddleware)
+	t.Run("WAF", TestWAF)
+}
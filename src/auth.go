@@ -0,0 +1,525 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Scope 是 serve 接口的访问权限。
+type Scope int
+
+const (
+	SCOPE_READ  Scope = iota // 检查：/predict、/inspect
+	SCOPE_WRITE              // 训练：/blackdata、/whitedata
+	SCOPE_ADMIN              // 模型快照的管理：/models
+)
+
+var scopeNames = []string{"read", "write", "admin"}
+
+func (s Scope) String() string {
+	if s >= 0 && int(s) < len(scopeNames) {
+		return scopeNames[s]
+	}
+	return fmt.Sprintf("Scope(%d)", int(s))
+}
+
+func (s Scope) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Scope) UnmarshalText(text []byte) error {
+	for i, name := range scopeNames {
+		if strings.EqualFold(string(text), name) {
+			*s = Scope(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown scope %q", text)
+}
+
+// AuthConfig 配置 serve 的认证。客户端可以用 Authorization: Bearer 或 X-API-Key 请求头提供API密钥，
+// 也可以在配置了 tls.client_ca 时提供客户端证书。
+type AuthConfig struct {
+	Keys    []APIKey     `yaml:"keys"`
+	Clients []ClientCert `yaml:"clients"`
+	// Anonymous 是没有凭据的请求的权限。不设置时，没有配置任何凭据则只允许检查，否则没有任何权限。
+	// 允许匿名训练或管理模型需要显式设置，如 [read, write, admin]。
+	Anonymous []Scope `yaml:"anonymous"`
+}
+
+// APIKey 是一个API密钥，Key 和 KeySHA256 设置其中一个。
+type APIKey struct {
+	Name      string  `yaml:"name"`
+	Key       string  `yaml:"key"`
+	KeySHA256 string  `yaml:"key_sha256"` // 密钥的SHA-256（十六进制），配置文件中不保存明文时使用
+	Scopes    []Scope `yaml:"scopes"`
+}
+
+// ClientCert 按证书的 Common Name 为客户端证书授予权限。
+type ClientCert struct {
+	Name       string  `yaml:"name"`
+	CommonName string  `yaml:"common_name"`
+	Scopes     []Scope `yaml:"scopes"`
+}
+
+// RateLimitConfig 是每个客户端的令牌桶限速，Rate 不大于0时不限速。
+// 有凭据的客户端按凭据名称计数，其余按来源IP计数。
+type RateLimitConfig struct {
+	Rate  float64 `yaml:"rate"`  // 每秒的请求数
+	Burst int     `yaml:"burst"` // 允许的突发请求数，不大于0时为 Rate 向上取整
+}
+
+// authFailureLimit 是每个来源IP认证失败的限速，与 rate_limit 的配置无关，用于防止猜测API密钥：
+// 连续失败10次后每5秒只能再尝试一次。
+var authFailureLimit = RateLimitConfig{Rate: 0.2, Burst: 10}
+
+func (c *AuthConfig) configured() bool {
+	return len(c.Keys) > 0 || len(c.Clients) > 0
+}
+
+func (c *AuthConfig) validate(tls TLSConfig) error {
+	names := make(map[string]bool)
+	name := func(kind, name string) error {
+		if name == "" {
+			return fmt.Errorf("auth: %s without name", kind)
+		}
+		if names[name] {
+			return fmt.Errorf("auth: duplicate name %q", name)
+		}
+		names[name] = true
+		return nil
+	}
+	for _, key := range c.Keys {
+		if err := name("key", key.Name); err != nil {
+			return err
+		}
+		if (key.Key == "") == (key.KeySHA256 == "") {
+			return fmt.Errorf("auth: key %s: set exactly one of key and key_sha256", key.Name)
+		}
+		if key.KeySHA256 != "" {
+			if digest, err := hex.DecodeString(key.KeySHA256); err != nil || len(digest) != sha256.Size {
+				return fmt.Errorf("auth: key %s: key_sha256 is not a hex SHA-256 digest", key.Name)
+			}
+		}
+	}
+	for _, client := range c.Clients {
+		if err := name("client", client.Name); err != nil {
+			return err
+		}
+		if client.CommonName == "" {
+			return fmt.Errorf("auth: client %s without common_name", client.Name)
+		}
+	}
+	if len(c.Clients) > 0 && tls.ClientCA == "" {
+		return errors.New("auth: clients require tls.client_ca")
+	}
+	return nil
+}
+
+// principal 是发出请求的客户端。
+type principal struct {
+	name   string // 凭据名称，没有凭据时为 anonymous
+	id     string // 限速使用的标识
+	scopes []Scope
+	anon   bool
+}
+
+func (p *principal) has(scope Scope) bool {
+	for _, s := range p.scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticator 是编译后的 AuthConfig。
+type authenticator struct {
+	keys      [][]byte // 与 config.Keys 对应的SHA-256
+	config    AuthConfig
+	anonymous []Scope
+}
+
+var errInvalidKey = errors.New("invalid API key")
+
+func newAuthenticator(config AuthConfig) *authenticator {
+	a := &authenticator{config: config, anonymous: config.Anonymous}
+	for _, key := range config.Keys {
+		if key.Key != "" {
+			digest := sha256.Sum256([]byte(key.Key))
+			a.keys = append(a.keys, digest[:])
+		} else {
+			digest, _ := hex.DecodeString(key.KeySHA256)
+			a.keys = append(a.keys, digest)
+		}
+	}
+	if a.anonymous == nil && !config.configured() {
+		a.anonymous = []Scope{SCOPE_READ}
+	}
+	return a
+}
+
+// authenticate 识别请求的客户端。提供了无效的API密钥时返回错误，而不是当作匿名请求。
+func (a *authenticator) authenticate(r *http.Request) (*principal, error) {
+	if key := requestKey(r); key != "" {
+		digest := sha256.Sum256([]byte(key))
+		match := -1
+		// 比较所有密钥，耗时与哪个密钥匹配无关
+		for i, known := range a.keys {
+			if subtle.ConstantTimeCompare(digest[:], known) == 1 {
+				match = i
+			}
+		}
+		if match < 0 {
+			return nil, errInvalidKey
+		}
+		key := a.config.Keys[match]
+		return &principal{name: key.Name, id: "key:" + key.Name, scopes: key.Scopes}, nil
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		commonName := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		for _, client := range a.config.Clients {
+			if client.CommonName == commonName {
+				return &principal{name: client.Name, id: "cert:" + client.Name, scopes: client.Scopes}, nil
+			}
+		}
+	}
+	return &principal{name: "anonymous", id: "ip:" + remoteHost(r), scopes: a.anonymous, anon: true}, nil
+}
+
+// remoteHost 返回请求的来源IP。
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// loadClientCAs 读取验证客户端证书的CA，path 为空时返回nil。
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return pool, nil
+}
+
+// rateLimiter 为每个客户端维护一个令牌桶。
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	l := &rateLimiter{buckets: make(map[string]*bucket), now: time.Now}
+	l.configure(config)
+	return l
+}
+
+// configure 修改限速参数，已有的令牌桶保留。
+func (l *rateLimiter) configure(config RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = config.Rate
+	l.burst = float64(config.Burst)
+	if l.burst <= 0 {
+		l.burst = math.Ceil(config.Rate)
+	}
+}
+
+// allow 消耗 id 的一个令牌，没有令牌时返回需要等待的时间。
+func (l *rateLimiter) allow(id string) (bool, time.Duration) {
+	return l.take(id, true)
+}
+
+// check 与 allow 相同，但不消耗令牌。
+func (l *rateLimiter) check(id string) (bool, time.Duration) {
+	return l.take(id, false)
+}
+
+func (l *rateLimiter) take(id string, consume bool) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true, 0
+	}
+	now := l.now()
+	// 定期删除已经补满的令牌桶，避免来源IP很多时占用内存
+	if now.Sub(l.swept) > time.Minute {
+		for key, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, key)
+			}
+		}
+		l.swept = now
+	}
+	b := l.buckets[id]
+	if b == nil && !consume {
+		return true, 0
+	}
+	if b == nil {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[id] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	if consume {
+		b.tokens--
+	}
+	return true, 0
+}
+
+// auditEntry 是审计日志中的一条训练提交或模型管理记录，被拒绝的请求也会记录。
+type auditEntry struct {
+	Time    time.Time `json:"time"`
+	Client  string    `json:"client"` // 凭据名称，没有凭据时为 anonymous
+	Remote  string    `json:"remote"`
+	Action  string    `json:"action"`            // train，或 /models 下的操作，如 snapshot、activate、rollback
+	Label   string    `json:"label,omitempty"`   // 训练的标签
+	Version int       `json:"version,omitempty"` // 生成或激活的快照版本
+	Outcome string    `json:"outcome"`           // trained、done、unauthorized、forbidden、rate_limited 或 error
+	Samples int       `json:"samples"`
+	SHA256  string    `json:"sha256,omitempty"`        // 请求体的SHA-256
+	Sample  []string  `json:"sample_sha256,omitempty"` // 每个样本的SHA-256，用于在训练数据中找出投毒的样本
+}
+
+// auditLog 以JSON逐行写入审计记录，没有审计文件时写到服务日志。
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return &auditLog{}, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: file}, nil
+}
+
+func (a *auditLog) record(entry *auditEntry, logger *log.Logger) {
+	line, _ := json.Marshal(entry)
+	if a.file == nil {
+		logger.Printf("audit %s", line)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		logger.Printf("audit log: %v, entry %s", err, line)
+	}
+}
+
+func (a *auditLog) close() {
+	if a.file != nil {
+		a.file.Close()
+	}
+}
+
+func sha256Hex(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+func TestAuth(t *testing.T) {
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.log")
+	// 自签名证书同时作为服务端证书和客户端CA
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hawkeye"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(caKey)
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	config := defaultConfig()
+	config.Model = ""
+	config.TLS = TLSConfig{Cert: certPath, Key: keyPath, ClientCA: certPath}
+	config.Log.Audit = auditPath
+	config.RateLimit = RateLimitConfig{Rate: 1, Burst: 2}
+	config.Auth = AuthConfig{
+		Keys: []APIKey{
+			{Name: "app", Key: "read-key", Scopes: []Scope{SCOPE_READ}},
+			{Name: "trainer", KeySHA256: sha256Hex([]byte("write-key")), Scopes: []Scope{SCOPE_READ, SCOPE_WRITE}},
+		},
+		Clients: []ClientCert{{Name: "ci", CommonName: "ci.internal", Scopes: []Scope{SCOPE_WRITE}}},
+	}
+	if err := config.validate(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newServer(config, false, func() (*Config, error) { return config, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.audit.close()
+	now := time.Unix(1000, 0)
+	s.limiter.now = func() time.Time { return now }
+	s.failures.now = s.limiter.now
+	handler := s.routes()
+
+	request := func(method, target, body string, setup func(*http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if setup != nil {
+			setup(r)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	key := func(key string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+key) }
+	}
+	cert := func(r *http.Request) {
+		r.RemoteAddr = "192.0.2.7:4000"
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "ci.internal"}}}}}
+	}
+
+	tests := []struct {
+		method, target string
+		setup          func(*http.Request)
+		status         int
+	}{
+		{"GET", "/predict?q=1", nil, http.StatusUnauthorized},
+		{"GET", "/predict?q=1", key("wrong"), http.StatusUnauthorized},
+		{"GET", "/predict?q=1", key("read-key"), http.StatusOK},
+		{"POST", "/blackdata", key("read-key"), http.StatusForbidden},
+		{"POST", "/blackdata", key("write-key"), http.StatusOK},
+		{"POST", "/whitedata", cert, http.StatusOK},
+		{"GET", "/predict?q=1", cert, http.StatusForbidden},
+		// 每个客户端的突发上限为2，read-key 已经用完
+		{"GET", "/predict?q=1", key("read-key"), http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		if w := request(tt.method, tt.target, "1' OR 1=1--\n", tt.setup); w.Code != tt.status {
+			t.Errorf("Request %d: expected status %d, got %d %s", i, tt.status, w.Code, w.Body.String())
+		}
+	}
+	now = now.Add(time.Second)
+	if w := request("GET", "/predict?q=1", "", key("read-key")); w.Code != http.StatusOK {
+		t.Errorf("Expected a token after one second, got %d", w.Code)
+	}
+
+	// 认证失败按来源IP限速，用完后正确的密钥也被拒绝
+	guess := func(key string) func(*http.Request) {
+		return func(r *http.Request) {
+			r.RemoteAddr = "203.0.113.9:4000"
+			r.Header.Set("X-API-Key", key)
+		}
+	}
+	for i := 0; i < authFailureLimit.Burst; i++ {
+		if w := request("GET", "/predict?q=1", "", guess(fmt.Sprintf("guess-%d", i))); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Guess %d: expected 401, got %d", i, w.Code)
+		}
+	}
+	if w := request("GET", "/predict?q=1", "", guess("write-key")); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "5" {
+		t.Errorf("Expected the correct key to be rate limited after failures, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := request("GET", "/predict?q=1", "", key("write-key")); w.Code != http.StatusOK {
+		t.Errorf("Expected other addresses to be unaffected, got %d", w.Code)
+	}
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outcomes []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Bad audit line %q: %v", line, err)
+		}
+		outcomes = append(outcomes, entry.Client+":"+entry.Label+":"+entry.Outcome+":"+strconv.Itoa(entry.Samples))
+		if entry.Outcome == "trained" && (entry.SHA256 != sha256Hex([]byte("1' OR 1=1--\n")) || entry.Sample[0] != sha256Hex([]byte("1' OR 1=1--"))) {
+			t.Errorf("Unexpected hashes in %s", line)
+		}
+	}
+	expected := "app:Black:forbidden:0 trainer:Black:trained:1 ci:White:trained:1"
+	if strings.Join(outcomes, " ") != expected {
+		t.Errorf("Expected audit %q, got %q", expected, strings.Join(outcomes, " "))
+	}
+
+	// 没有配置凭据时匿名请求只能检查，训练和管理需要显式允许
+	open := newAuthenticator(AuthConfig{})
+	if p, err := open.authenticate(httptest.NewRequest("GET", "/", nil)); err != nil || !p.has(SCOPE_READ) || p.has(SCOPE_WRITE) || p.has(SCOPE_ADMIN) {
+		t.Errorf("Expected anonymous read-only access without credentials, got %+v %v", p, err)
+	}
+	open = newAuthenticator(AuthConfig{Anonymous: []Scope{SCOPE_READ, SCOPE_WRITE}})
+	if p, err := open.authenticate(httptest.NewRequest("GET", "/", nil)); err != nil || !p.has(SCOPE_WRITE) || p.has(SCOPE_ADMIN) {
+		t.Errorf("Expected explicit anonymous write access, got %+v %v", p, err)
+	}
+	bad := AuthConfig{Keys: []APIKey{{Name: "x", Key: "a", KeySHA256: sha256Hex([]byte("a"))}}}
+	if err := bad.validate(TLSConfig{}); err == nil {
+		t.Errorf("Expected error for key with both key and key_sha256")
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"HawkEye-Go/src/Engine"
+	"HawkEye-Go/src/LogScan"
+	"HawkEye-Go/src/PythonSqlPaser"
+	"HawkEye-Go/src/SqlPaser"
+	"HawkEye-Go/src/Stream"
+	"HawkEye-Go/src/Waf"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// readInput 返回参数拼接成的文本，没有参数时读取整个标准输入。
+func readInput(args []string) (string, error) {
+	if len(args) > 0 {
+		return strings.Join(args, " "), nil
+	}
+	input, err := io.ReadAll(os.Stdin)
+	return string(input), err
+}
+
+// runParse 实现 parse 子命令：解析参数或标准输入中的SQL，输出格式化后的语句或JSON格式的AST。
+// 有语句解析失败时返回 EXIT_ERROR。
+func runParse(args []string) int {
+	flags := newFlags("parse", "parse [-json] [SQL]")
+	asJSON := flags.Bool("json", false, "以JSON格式输出AST")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	sql, err := readInput(flags.Args())
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+
+	script := SqlPaser.ParseScript(sql)
+	status := EXIT_OK
+	for _, stmt := range script.Statements {
+		if len(stmt.Errors) > 0 {
+			fmt.Fprintf(os.Stderr, "%q: %s\n", stmt.Text, strings.Join(stmt.Errors, "; "))
+			status = EXIT_ERROR
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(script); err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+		return status
+	}
+	for _, stmt := range script.Statements {
+		if stmt.Node != nil {
+			fmt.Println(stmt.Node.String() + ";")
+		}
+	}
+	return status
+}
+
+// tokenOutput 是 tokenize 输出的一个令牌。
+type tokenOutput struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Pos   *int   `json:"pos,omitempty"` // 只有 sqlpaser 词法分析器提供位置
+}
+
+// runTokenize 输出参数或标准输入中SQL的令牌，可以选择两个词法分析器中的一个。
+func runTokenize(args []string) int {
+	flags := newFlags("tokenize", "tokenize [-lexer sqlpaser|pygments] [-json] [SQL]")
+	lexer := flags.String("lexer", "sqlpaser", "词法分析器：sqlpaser 或 pygments")
+	asJSON := flags.Bool("json", false, "以JSON逐行输出令牌")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	sql, err := readInput(flags.Args())
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+
+	var tokens []tokenOutput
+	switch *lexer {
+	case "sqlpaser":
+		l := SqlPaser.NewLexer(sql)
+		for token := l.NextToken(); token.Type != SqlPaser.EOF; token = l.NextToken() {
+			pos := token.Pos
+			tokens = append(tokens, tokenOutput{Type: token.Type.String(), Value: token.Value, Pos: &pos})
+		}
+	case "pygments":
+		parsed, err := PythonSqlPaser.GetTokens(sql)
+		if err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+		for _, token := range parsed {
+			tokens = append(tokens, tokenOutput{Type: token.Type.String(), Value: token.Value})
+		}
+	default:
+		return fail(EXIT_USAGE, fmt.Errorf("unknown lexer %q", *lexer))
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	defer output.Flush()
+	encoder := json.NewEncoder(output)
+	for _, token := range tokens {
+		if *asJSON {
+			encoder.Encode(token)
+		} else {
+			fmt.Fprintf(output, "%-24s %q\n", token.Type, token.Value)
+		}
+	}
+	return EXIT_OK
+}
+
+// runScan 扫描历史日志，以NDJSON输出可疑的语句和请求。文件名为 - 或没有文件时读取标准输入，.gz 文件自动解压。
+// 发现可疑条目时返回 EXIT_DETECTED。
+func runScan(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := newFlags("scan", "scan [flags] [FILE...]")
+	detectorFlags(flags, config)
+	var format LogScan.Format
+	flags.TextVar(&format, "format", LogScan.FORMAT_AUTO, "日志格式：auto、mysql-general、mysql-slow、postgres 或 access")
+	flags.IntVar(&config.Workers, "workers", config.Workers, "并发检查的goroutine数量（HAWKEYE_WORKERS）")
+	all := flags.Bool("all", false, "输出所有条目，而不只是可疑的条目")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	detector, err := newDetector(config)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	workers := config.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	entries := make(chan *LogScan.Entry, workers*4)
+	results := make(chan *LogScan.Result, workers*4)
+	failed := false
+	go func() {
+		defer close(entries)
+		for _, name := range files {
+			if err := scanFile(name, format, entries); err != nil {
+				fmt.Fprintln(os.Stderr, "HawkEye-Go:", err)
+				failed = true
+			}
+		}
+	}()
+	scanner := LogScan.NewScanner(detector, workers)
+	scanner.Inspector.Normalization = config.Normalize
+	go scanner.Scan(entries, results)
+
+	output := bufio.NewWriter(os.Stdout)
+	defer output.Flush()
+	encoder := json.NewEncoder(output)
+	detected, errored := false, false
+	for result := range results {
+		detected = detected || result.Block || len(result.Findings) > 0
+		errored = errored || result.Error != ""
+		if *all || result.Flagged() {
+			encoder.Encode(result)
+		}
+	}
+	// results 关闭时读取日志的goroutine已经结束
+	switch {
+	case failed, errored:
+		return EXIT_ERROR
+	case detected:
+		return EXIT_DETECTED
+	}
+	return EXIT_OK
+}
+
+// scanFile 解析一个日志文件并把条目发送到entries。
+func scanFile(name string, format LogScan.Format, entries chan<- *LogScan.Entry) error {
+	var input io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+		if strings.HasSuffix(name, ".gz") {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			defer gz.Close()
+			input = gz
+		}
+	}
+	return LogScan.ParseLog(input, name, format, func(entry *LogScan.Entry) error {
+		entries <- entry
+		return nil
+	})
+}
+
+// runClassify 从标准输入读取载荷，按输入顺序向标准输出写入加上结论的NDJSON。
+func runClassify(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := newFlags("classify", "classify [flags] < input")
+	detectorFlags(flags, config)
+	inputName := flags.String("input", "lines", "输入格式：lines 或 ndjson")
+	field := flags.String("field", "", "ndjson 输入中载荷的字段路径，如 request.query")
+	fields := flags.String("fields", strings.Join(Stream.DefaultFields, ","), "输出的字段："+strings.Join(Stream.OutputFields, ", "))
+	explainName := flags.String("explain", "none", "解释级别：none、rules 或 features")
+	key := flags.String("key", "hawkeye", "输出中结论所在的字段名")
+	flags.IntVar(&config.Workers, "workers", config.Workers, "并发检查的goroutine数量（HAWKEYE_WORKERS）")
+	window := flags.Int("window", 0, "同时处理的最大记录数，默认为 workers 的16倍")
+	maxLine := flags.Int("max-line", 1<<20, "一行的最大字节数")
+	showStats := flags.Bool("stats", false, "结束时在标准错误输出统计")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+
+	detector, err := newDetector(config)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	classifier := Stream.NewClassifier(detector)
+	classifier.Inspector.Normalization = config.Normalize
+	if err := classifier.Input.UnmarshalText([]byte(*inputName)); err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	if err := classifier.Explain.UnmarshalText([]byte(*explainName)); err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	classifier.Field, classifier.Key = *field, *key
+	classifier.Fields = strings.Split(*fields, ",")
+	classifier.Workers, classifier.Window, classifier.MaxLine = config.Workers, *window, *maxLine
+
+	stats, err := classifier.Run(os.Stdin, os.Stdout)
+	if *showStats {
+		fmt.Fprintf(os.Stderr, "%d records, %d blocked, %d errors\n", stats.Records, stats.Blocked, stats.Errors)
+	}
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	return EXIT_OK
+}
+
+// runBaseline 从文件或标准输入逐行读取查询，学习模式下更新基线文件，
+// 其他模式下以JSON逐行输出不在基线中的查询，拦截模式下有异常时返回 EXIT_DETECTED。
+func runBaseline(args []string) int {
+	flags := newFlags("baseline", "baseline [-file baseline.json] [-mode learn|enforce|report] [FILE...]")
+	path := flags.String("file", "baseline.json", "基线文件")
+	modeName := flags.String("mode", "", "learn、enforce 或 report，默认使用基线文件中的模式")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+
+	baseline, err := Engine.LoadBaseline(*path)
+	if errors.Is(err, fs.ErrNotExist) {
+		baseline, err = Engine.NewBaseline(Engine.BASELINE_LEARN), nil
+	}
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	if *modeName != "" {
+		var mode Engine.BaselineMode
+		if err := mode.UnmarshalText([]byte(*modeName)); err != nil {
+			return fail(EXIT_USAGE, err)
+		}
+		baseline.SetMode(mode)
+	}
+
+	inputs := []io.Reader{os.Stdin}
+	if flags.NArg() > 0 {
+		inputs = nil
+		for _, name := range flags.Args() {
+			file, err := os.Open(name)
+			if err != nil {
+				return fail(EXIT_ERROR, err)
+			}
+			defer file.Close()
+			inputs = append(inputs, file)
+		}
+	}
+
+	status := EXIT_OK
+	encoder := json.NewEncoder(os.Stdout)
+	for _, input := range inputs {
+		scanner := bufio.NewScanner(input)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			query := strings.TrimSpace(scanner.Text())
+			if query == "" {
+				continue
+			}
+			finding := baseline.Observe(query)
+			if finding.Known {
+				continue
+			}
+			encoder.Encode(finding)
+			if finding.Block {
+				status = EXIT_DETECTED
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+	}
+
+	if baseline.Mode() == Engine.BASELINE_LEARN {
+		if err := baseline.Save(*path); err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+		fmt.Fprintf(os.Stderr, "%d templates saved to %s\n", baseline.Len(), *path)
+	}
+	return status
+}
+
+// runProxy 以反向代理模式运行WAF，配置文件的 proxy 部分设置上游、路由和拦截响应，命令行参数覆盖它们。
+func runProxy(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	proxy := &config.Proxy
+	flags := newFlags("proxy", "proxy [flags]")
+	detectorFlags(flags, config)
+	flags.StringVar(&proxy.Listen, "listen", proxy.Listen, "监听地址")
+	flags.StringVar(&proxy.Upstream, "upstream", proxy.Upstream, "上游应用地址，如 http://127.0.0.1:3000（HAWKEYE_UPSTREAM）")
+	flags.BoolVar(&proxy.DetectOnly, "detect-only", proxy.DetectOnly, "只记录不拦截")
+	blockStatus := flags.Int("block-status", 0, "拦截时返回的状态码")
+	blockBody := flags.String("block-body", "", "拦截时返回的响应体")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if *blockStatus != 0 || *blockBody != "" {
+		if proxy.Block.Status == 0 {
+			proxy.Block = Waf.DefaultBlockResponse
+		}
+		if *blockStatus != 0 {
+			proxy.Block.Status = *blockStatus
+		}
+		if *blockBody != "" {
+			proxy.Block.Body = *blockBody
+		}
+	}
+
+	detector, err := newDetector(config)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	proxy.Detector = detector
+	proxy.Logger = log.New(os.Stderr, "waf: ", log.LstdFlags)
+
+	waf, err := Waf.New(&proxy.Config)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	log.Printf("proxying to %s", proxy.Upstream)
+	return serveUntilSignal(&http.Server{Addr: proxy.Listen, Handler: waf})
+}
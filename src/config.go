@@ -0,0 +1,308 @@
+package main
+
+import (
+	"HawkEye-Go/src/Engine"
+	"HawkEye-Go/src/SqlPaser"
+	"HawkEye-Go/src/Waf"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 退出码
+const (
+	EXIT_OK       = 0 // 成功，没有发现攻击
+	EXIT_ERROR    = 1 // 运行出错，如文件无法读取或语句无法解析
+	EXIT_USAGE    = 2 // 命令行参数或配置错误
+	EXIT_DETECTED = 3 // predict、eval 以外的检查命令发现了攻击或异常
+)
+
+// defaultModelPath 是默认的模型文件，不存在时只使用规则。
+const defaultModelPath = "naive_bayes_model.gob"
+
+// Config 是各个子命令共用的配置，优先级从低到高为默认值、配置文件、环境变量和命令行参数。
+type Config struct {
+	Model      string                 `yaml:"model"`      // 朴素贝叶斯模型文件
+	Rules      string                 `yaml:"rules"`      // 规则文件，为空时使用内置规则
+	Dialects   []SqlPaser.Dialect     `yaml:"dialects"`   // 后端数据库的方言，为空时使用所有规则
+	Normalize  []Engine.NormalizePass `yaml:"normalize"`  // 参数值的规范化步骤，不设置时使用全部步骤
+	Policy     Engine.Policy          `yaml:"policy"`     // 结论策略
+	Thresholds map[string]float64     `yaml:"thresholds"` // serve 各接口的拦截阈值，键为 predict 或 inspect，覆盖 policy.threshold
+	Listen     string                 `yaml:"listen"`     // serve 的监听地址
+	TLS        TLSConfig              `yaml:"tls"`        // serve 的证书
+	Auth       AuthConfig             `yaml:"auth"`       // serve 的认证和权限
+	RateLimit  RateLimitConfig        `yaml:"rate_limit"` // serve 每个客户端的限速
+	Log        LogConfig              `yaml:"log"`        // serve 的日志
+	Snapshots  SnapshotConfig         `yaml:"snapshots"`  // 模型快照
+	Watch      time.Duration          `yaml:"watch"`      // serve 检查配置、模型和规则文件修改的间隔，为0时只在收到 SIGHUP 时重新加载
+	Workers    int                    `yaml:"workers"`    // 并发检查的goroutine数量
+	Proxy      ProxyConfig            `yaml:"proxy"`      // proxy 子命令的配置
+
+	path string // 读取的配置文件，没有时为空
+}
+
+// TLSConfig 是 serve 的证书和私钥文件，都为空时不启用TLS。重新加载配置时证书也会重新读取。
+type TLSConfig struct {
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+	ClientCA string `yaml:"client_ca"` // 验证客户端证书的CA，设置后客户端可以用证书认证，见 AuthConfig
+}
+
+// LogConfig 配置 serve 的日志。
+type LogConfig struct {
+	File     string `yaml:"file"`     // 追加写入的日志文件，为空时写到标准错误
+	Requests bool   `yaml:"requests"` // 记录每个请求
+	Audit    string `yaml:"audit"`    // 训练提交的审计日志，为空时写到服务日志
+}
+
+// SnapshotConfig 配置模型快照。设置了 Dir 时 serve 启动时加载激活的快照，没有激活的快照时才使用 model 指定的文件。
+type SnapshotConfig struct {
+	Dir      string        `yaml:"dir"`      // 快照目录
+	Interval time.Duration `yaml:"interval"` // serve 在模型有新的训练样本时生成快照的间隔，为0时只通过 /models/snapshot 生成
+	Keep     int           `yaml:"keep"`     // 保留的快照数量，为0时不删除，激活的版本和回滚历史（最近10个激活过的版本）不会删除
+	Black    []string      `yaml:"black"`    // 生成快照时评估模型用的攻击样本文件
+	White    []string      `yaml:"white"`    // 生成快照时评估模型用的正常样本文件
+}
+
+// ProxyConfig 是 proxy 子命令的配置。
+type ProxyConfig struct {
+	Listen     string `yaml:"listen"`
+	Waf.Config `yaml:",inline"`
+}
+
+// endpoints 是可以单独设置阈值的 serve 接口。
+var endpoints = []string{"predict", "inspect"}
+
+func defaultConfig() *Config {
+	return &Config{
+		Model:   defaultModelPath,
+		Policy:  Engine.DefaultPolicy,
+		Listen:  ":8080",
+		Watch:   2 * time.Second,
+		Workers: runtime.NumCPU(),
+		Proxy:   ProxyConfig{Listen: ":8081"},
+	}
+}
+
+// validate 检查配置中命令行参数以外的部分。
+func (c *Config) validate() error {
+	for name, threshold := range c.Thresholds {
+		known := false
+		for _, endpoint := range endpoints {
+			known = known || name == endpoint
+		}
+		if !known {
+			return fmt.Errorf("thresholds: unknown endpoint %q", name)
+		}
+		if threshold < 0 || threshold > 1 {
+			return fmt.Errorf("thresholds: %s: %v is not between 0 and 1", name, threshold)
+		}
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		return errors.New("tls: cert and key must be set together")
+	}
+	if c.TLS.ClientCA != "" && c.TLS.Cert == "" {
+		return errors.New("tls: client_ca requires cert and key")
+	}
+	if c.Snapshots.Interval < 0 || c.Snapshots.Keep < 0 {
+		return errors.New("snapshots: interval and keep must not be negative")
+	}
+	if c.Snapshots.Dir == "" && (c.Snapshots.Interval > 0 || c.Snapshots.Keep > 0) {
+		return errors.New("snapshots: interval and keep require dir")
+	}
+	return c.Auth.validate(c.TLS)
+}
+
+// loadConfig 读取配置文件和环境变量。配置文件由参数中的 -config 或环境变量 HAWKEYE_CONFIG 指定，
+// 需要在定义其余参数之前读取，这样命令行参数才能覆盖它。
+func loadConfig(args []string) (*Config, error) {
+	config := defaultConfig()
+	path := os.Getenv("HAWKEYE_CONFIG")
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		path = value
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		config.path = path
+	}
+	if err := applyEnv(config); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyEnv 用 HAWKEYE_ 开头的环境变量覆盖配置。
+func applyEnv(config *Config) error {
+	var err error
+	env := func(name string, apply func(string) error) {
+		if value, ok := os.LookupEnv(name); ok && err == nil {
+			if e := apply(value); e != nil {
+				err = fmt.Errorf("%s: %v", name, e)
+			}
+		}
+	}
+	env("HAWKEYE_MODEL", func(v string) error { config.Model = v; return nil })
+	env("HAWKEYE_RULES", func(v string) error { config.Rules = v; return nil })
+	env("HAWKEYE_LISTEN", func(v string) error { config.Listen = v; return nil })
+	env("HAWKEYE_WORKERS", func(v string) (e error) { config.Workers, e = strconv.Atoi(v); return })
+	env("HAWKEYE_POLICY", func(v string) error { return config.Policy.Mode.UnmarshalText([]byte(v)) })
+	env("HAWKEYE_BLOCK_SEVERITY", func(v string) error { return config.Policy.BlockSeverity.UnmarshalText([]byte(v)) })
+	env("HAWKEYE_THRESHOLD", func(v string) (e error) { config.Policy.Threshold, e = strconv.ParseFloat(v, 64); return })
+	env("HAWKEYE_UPSTREAM", func(v string) error { config.Proxy.Upstream = v; return nil })
+	env("HAWKEYE_DIALECTS", func(v string) error { return (*dialectList)(&config.Dialects).Set(v) })
+	env("HAWKEYE_TLS_CERT", func(v string) error { config.TLS.Cert = v; return nil })
+	env("HAWKEYE_TLS_KEY", func(v string) error { config.TLS.Key = v; return nil })
+	env("HAWKEYE_TLS_CLIENT_CA", func(v string) error { config.TLS.ClientCA = v; return nil })
+	env("HAWKEYE_LOG_FILE", func(v string) error { config.Log.File = v; return nil })
+	env("HAWKEYE_AUDIT_LOG", func(v string) error { config.Log.Audit = v; return nil })
+	env("HAWKEYE_SNAPSHOTS", func(v string) error { config.Snapshots.Dir = v; return nil })
+	return err
+}
+
+// newFlags 创建子命令的参数集，-config 只是为了出现在帮助中，已经由 loadConfig 处理。
+func newFlags(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.String("config", "", "YAML配置文件，也可以用环境变量 HAWKEYE_CONFIG 指定")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: HawkEye-Go "+usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// detectorFlags 添加创建检测器需要的参数，默认值来自配置。
+func detectorFlags(flags *flag.FlagSet, config *Config) {
+	flags.StringVar(&config.Model, "model", config.Model, "朴素贝叶斯模型文件，为空时只使用规则（HAWKEYE_MODEL）")
+	flags.StringVar(&config.Rules, "rules", config.Rules, "规则文件，为空时使用内置规则（HAWKEYE_RULES）")
+	flags.TextVar(&config.Policy.Mode, "policy", config.Policy.Mode, "结论策略：either、rules、model 或 score（HAWKEYE_POLICY）")
+	flags.TextVar(&config.Policy.BlockSeverity, "block-severity", config.Policy.BlockSeverity, "规则命中达到该级别时拦截（HAWKEYE_BLOCK_SEVERITY）")
+	flags.Float64Var(&config.Policy.Threshold, "threshold", config.Policy.Threshold, "模型概率或综合分数的拦截阈值（HAWKEYE_THRESHOLD）")
+	flags.Var((*dialectList)(&config.Dialects), "dialects", "后端数据库的方言，以逗号分隔：mysql、postgresql、sqlserver、oracle、sqlite（HAWKEYE_DIALECTS）")
+}
+
+// parseFlags 解析参数，返回非负数时命令应当以它作为退出码结束。
+func parseFlags(flags *flag.FlagSet, args []string) int {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return EXIT_OK
+		}
+		return EXIT_USAGE
+	}
+	return -1
+}
+
+// dialectList 是以逗号分隔的方言列表，如 mysql,postgresql。
+type dialectList []SqlPaser.Dialect
+
+func (d *dialectList) String() string {
+	var names []string
+	for _, dialect := range *d {
+		names = append(names, dialect.String())
+	}
+	return strings.Join(names, ",")
+}
+
+func (d *dialectList) Set(value string) error {
+	*d = nil
+	for _, name := range strings.Split(value, ",") {
+		var dialect SqlPaser.Dialect
+		if err := dialect.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+			return err
+		}
+		*d = append(*d, dialect)
+	}
+	return nil
+}
+
+// loadRules 加载配置的规则文件或内置规则，并按方言筛选。
+func loadRules(config *Config) (*Engine.RuleSet, error) {
+	rules := Engine.DefaultRuleSet()
+	if config.Rules != "" {
+		var err error
+		if rules, err = Engine.LoadRules(config.Rules); err != nil {
+			return nil, err
+		}
+	}
+	return rules.ForDialects(config.Dialects...), nil
+}
+
+// loadModel 加载配置的模型，没有配置模型或默认模型文件不存在时返回nil。
+func loadModel(config *Config) (*Engine.NaiveBayes, error) {
+	if config.Model == "" {
+		return nil, nil
+	}
+	model, err := Engine.LoadModelFromFile(config.Model)
+	if errors.Is(err, fs.ErrNotExist) && config.Model == defaultModelPath {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load model: %v", err)
+	}
+	return model, nil
+}
+
+// detectorFor 用给定的规则和模型创建检测器，没有模型时只使用规则。
+func detectorFor(config *Config, rules *Engine.RuleSet, model *Engine.NaiveBayes) *Engine.Detector {
+	detector := &Engine.Detector{Rules: rules, Model: model, Policy: config.Policy}
+	if model == nil {
+		detector.Policy.Mode = Engine.POLICY_RULES_ONLY
+	}
+	return detector
+}
+
+// newDetector 根据配置创建检测器。
+func newDetector(config *Config) (*Engine.Detector, error) {
+	rules, err := loadRules(config)
+	if err != nil {
+		return nil, err
+	}
+	model, err := loadModel(config)
+	if err != nil {
+		return nil, err
+	}
+	return detectorFor(config, rules, model), nil
+}
+
+// newInspector 根据配置创建参数检查器。
+func newInspector(config *Config) (*Engine.RequestInspector, error) {
+	detector, err := newDetector(config)
+	if err != nil {
+		return nil, err
+	}
+	inspector := Engine.NewRequestInspector(detector)
+	inspector.Normalization = config.Normalize
+	return inspector, nil
+}
+
+// fail 输出错误并返回退出码。
+func fail(code int, err error) int {
+	fmt.Fprintln(os.Stderr, "HawkEye-Go:", err)
+	return code
+}
@@ -0,0 +1,10 @@
+package main
+
+import "testing"
+
+// TestHawkEye 运行与被测代码写在同一文件中的测试函数。
+func TestHawkEye(t *testing.T) {
+	t.Run("Auth", TestAuth)
+	t.Run("ModelSnapshots", TestModelSnapshots)
+	t.Run("ServerReload", TestServerReload)
+}
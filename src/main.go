@@ -1,57 +1,59 @@
 package main
 
 import (
-	"HawkEye-Go/src/Engine"
-	"HawkEye-Go/src/PythonSqlPaser"
-	"HawkEye-Go/src/SqlPaser"
 	"fmt"
-	"net/http"
+	"os"
 )
 
-func handleBlackData(w http.ResponseWriter, r *http.Request) {
-	// Handle incoming black data
+// command 是一个子命令，run 返回进程的退出码。
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
 }
 
-func handleWhiteData(w http.ResponseWriter, r *http.Request) {
-	// Handle incoming white data
+var commands = []command{
+	{"serve", "启动检测服务（/predict、/inspect、/blackdata、/whitedata、/models）", runServe},
+	{"train", "用黑白样本文件训练朴素贝叶斯模型", runTrain},
+	{"predict", "检查参数或标准输入中的载荷，有拦截时退出码为3", runPredict},
+	{"eval", "在标注样本上评估检测器", runEval},
+	{"tokenize", "输出SQL的令牌", runTokenize},
+	{"parse", "解析SQL，输出格式化的语句或AST", runParse},
+	{"scan", "扫描MySQL、PostgreSQL和访问日志，有可疑条目时退出码为3", runScan},
+	{"classify", "逐行分类标准输入，按顺序输出NDJSON", runClassify},
+	{"baseline", "学习或检查查询结构基线", runBaseline},
+	{"proxy", "以反向代理模式运行WAF", runProxy},
+	{"model", "查看模型，管理模型快照：inspect、list、snapshot、diff、activate、rollback", runModel},
 }
 
-func handlePredictionData(w http.ResponseWriter, r *http.Request) {
-	// Handle data for prediction
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: HawkEye-Go <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\n配置优先级从低到高为默认值、-config 或 HAWKEYE_CONFIG 指定的YAML文件、HAWKEYE_* 环境变量和命令行参数。")
+	fmt.Fprintln(os.Stderr, "退出码：0 成功，1 运行出错，2 用法错误，3 发现攻击或异常。")
+	fmt.Fprintln(os.Stderr, "使用 HawkEye-Go <command> -h 查看子命令的参数。")
 }
 
 func main() {
-	PythonSqlPaser.TestSQLProcessing(nil)
-	SqlPaser.TestLexer(nil)
-	SqlPaser.TestParseInsertStatement(nil)
-	SqlPaser.TestParseSelectStatement(nil)
-	nb := Engine.NewNaiveBayes()
-
-	// 训练
-	featuresWhite := Engine.ExtractFeatures("SELECT name FROM users WHERE id = 1")
-	nb.Train(featuresWhite, "White")
-
-	featuresBlack := Engine.ExtractFeatures("DROP TABLE users")
-	nb.Train(featuresBlack, "Black")
-
-	err := nb.SaveToFile("naive_bayes_model.gob")
-	if err != nil {
-		panic(err)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(EXIT_USAGE)
 	}
-
-	nc, err := Engine.LoadModelFromFile("naive_bayes_model.gob")
-	if err != nil {
-		panic(err)
+	name := os.Args[1]
+	switch name {
+	case "-h", "-help", "--help", "help":
+		usage()
+		os.Exit(EXIT_OK)
 	}
-
-	// 预测
-	predictFeatures := Engine.ExtractFeatures("SELECT name FROM admins")
-	result := nc.PredictProbability(predictFeatures)
-	fmt.Println("是黑数据的概率 %v", result)
-	// 输出预测结果
-	http.HandleFunc("/blackdata", handleBlackData)
-	http.HandleFunc("/whitedata", handleWhiteData)
-	http.HandleFunc("/predict", handlePredictionData)
-
-	http.ListenAndServe(":8080", nil)
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "HawkEye-Go: unknown command %q\n", name)
+	usage()
+	os.Exit(EXIT_USAGE)
 }
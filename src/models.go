@@ -0,0 +1,723 @@
+package main
+
+import (
+	"HawkEye-Go/src/Engine"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errNoSnapshots = errors.New("snapshots are not configured (snapshots.dir)")
+
+// activeSnapshotPath 返回记录激活快照的文件，没有配置快照目录时为空。
+func activeSnapshotPath(config *Config) string {
+	if config.Snapshots.Dir == "" {
+		return ""
+	}
+	return Engine.ActiveSnapshotPath(config.Snapshots.Dir)
+}
+
+// openSnapshots 打开配置的快照目录，没有配置时返回 nil。
+func openSnapshots(config *Config) (*Engine.ModelStore, error) {
+	if config.Snapshots.Dir == "" {
+		return nil, nil
+	}
+	return Engine.OpenModelStore(config.Snapshots.Dir)
+}
+
+// loadServeModel 加载 serve 使用的模型：有激活的快照时使用快照，否则使用 model 指定的文件。
+// 模型来自快照时返回快照的元数据。
+func loadServeModel(config *Config, store *Engine.ModelStore) (*Engine.NaiveBayes, *Engine.SnapshotInfo, error) {
+	if store != nil {
+		model, info, err := store.LoadActive()
+		if err != nil {
+			return nil, nil, fmt.Errorf("load snapshot: %v", err)
+		}
+		if model != nil {
+			return model, info, nil
+		}
+	}
+	model, err := loadModel(config)
+	return model, nil, err
+}
+
+// evaluateModel 在 snapshots.black 和 snapshots.white 上评估检测器，没有配置样本文件时返回 nil。
+func evaluateModel(config *Config, rules *Engine.RuleSet, model *Engine.NaiveBayes) (*Engine.Evaluation, error) {
+	if len(config.Snapshots.Black) == 0 && len(config.Snapshots.White) == 0 {
+		return nil, nil
+	}
+	inspector := Engine.NewRequestInspector(detectorFor(config, rules, model))
+	inspector.Normalization = config.Normalize
+	evaluation := &Engine.Evaluation{}
+	for attack, files := range map[bool][]string{true: config.Snapshots.Black, false: config.Snapshots.White} {
+		for _, name := range files {
+			err := readSamples(name, func(sample string) {
+				evaluation.Record(attack, inspector.CheckParameter(Engine.Parameter{Value: sample}).Block)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	evaluation.Finish()
+	return evaluation, nil
+}
+
+// Snapshot 把内存中的模型保存为新的快照并激活它，重新启动后继续使用在线训练的结果。
+// changedOnly 为 true 时，上次快照后没有新的训练样本则不生成快照，返回 nil。
+func (s *server) Snapshot(reason string, changedOnly bool) (*Engine.SnapshotInfo, error) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	st := s.state.Load()
+	if st.store == nil {
+		return nil, errNoSnapshots
+	}
+	s.mu.RLock()
+	trained := s.trained.Load()
+	model := s.state.Load().model
+	if model != nil {
+		model = model.Clone()
+	}
+	s.mu.RUnlock()
+	if changedOnly && trained == s.snapshotted.Load() {
+		return nil, nil
+	}
+	if model == nil {
+		return nil, errors.New("no model to snapshot")
+	}
+
+	// 评估和写入使用副本，不阻塞检查和训练
+	metrics, err := evaluateModel(st.config, st.rules, model)
+	if err != nil {
+		return nil, fmt.Errorf("evaluate snapshot: %v", err)
+	}
+	info, err := st.store.Save(model, reason, metrics)
+	if err != nil {
+		return nil, err
+	}
+	// 持有 reloadMu，避免 Reload 在激活后把刚保存的快照当作外部修改重新加载
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	if _, err = st.store.Activate(info.Version); err == nil && st.config.Snapshots.Keep > 0 {
+		if _, err := st.store.Prune(st.config.Snapshots.Keep); err != nil {
+			st.logger.Printf("prune snapshots: %v", err)
+		}
+	}
+	s.stamp(st.store.ActivePath())
+	if err != nil {
+		return nil, err
+	}
+	s.snapshotted.Store(trained)
+	info.Active = true
+	return info, nil
+}
+
+// activate 激活快照并用它替换内存中的模型，rollback 为 true 时恢复上一个激活的版本而忽略 version。
+// 上次快照之后在线训练的样本会丢弃。
+func (s *server) activate(version int, rollback bool) (*Engine.SnapshotInfo, error) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	st := s.state.Load()
+	if st.store == nil {
+		return nil, errNoSnapshots
+	}
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	var model *Engine.NaiveBayes
+	var info *Engine.SnapshotInfo
+	var err error
+	if rollback {
+		if info, err = st.store.Rollback(); err == nil {
+			model, info, err = st.store.Load(info.Version)
+		}
+	} else if model, info, err = st.store.Load(version); err == nil {
+		// 先读取再激活，快照损坏时不修改激活的版本
+		_, err = st.store.Activate(version)
+	}
+	s.stamp(st.store.ActivePath())
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	next := *s.state.Load()
+	next.setModel(model)
+	s.state.Store(&next)
+	s.snapshotted.Store(s.trained.Load())
+	s.mu.Unlock()
+	info.Active = true
+	return info, nil
+}
+
+// snapshotEvery 每隔 interval 在模型有新的训练样本时生成快照，interval 为0时不定期生成。
+// 返回的函数停止定期快照，并在退出前为还没有保存的训练样本生成最后一个快照。
+func (s *server) snapshotEvery(interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+	snapshot := func(reason string) {
+		info, err := s.Snapshot(reason, true)
+		logger := s.state.Load().logger
+		if err != nil {
+			logger.Printf("snapshot failed: %v", err)
+		} else if info != nil {
+			logger.Printf("saved and activated snapshot %d (%d samples)", info.Version, info.Samples)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ticker.C:
+				snapshot("periodic")
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(stop)
+		<-done
+		snapshot("shutdown")
+	}
+}
+
+// modelAt 返回 /models/diff 参数指定的模型：版本号、active（激活的快照）或 live（内存中的模型）。
+func (s *server) modelAt(store *Engine.ModelStore, spec string) (*Engine.NaiveBayes, error) {
+	switch spec {
+	case "live":
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if model := s.state.Load().model; model != nil {
+			return model.Clone(), nil
+		}
+		return Engine.NewNaiveBayes(), nil
+	case "active":
+		model, _, err := store.LoadActive()
+		if err == nil && model == nil {
+			err = errors.New("no active snapshot")
+		}
+		return model, err
+	}
+	version, err := strconv.Atoi(spec)
+	if err != nil {
+		return nil, fmt.Errorf("bad version %q", spec)
+	}
+	model, _, err := store.Load(version)
+	return model, err
+}
+
+// handleModels 列出快照：GET /models。
+func (s *server) handleModels(w http.ResponseWriter, r *http.Request) {
+	store := s.state.Load().store
+	if store == nil {
+		http.Error(w, errNoSnapshots.Error(), http.StatusNotFound)
+		return
+	}
+	infos, err := store.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, infos)
+}
+
+// handleModelDiff 比较两个模型：GET /models/diff?from=active&to=live&top=10。
+func (s *server) handleModelDiff(w http.ResponseWriter, r *http.Request) {
+	store := s.state.Load().store
+	if store == nil {
+		http.Error(w, errNoSnapshots.Error(), http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	param := func(name, value string) string {
+		if v := query.Get(name); v != "" {
+			return v
+		}
+		return value
+	}
+	top, err := strconv.Atoi(param("top", "10"))
+	if err != nil {
+		http.Error(w, "bad top", http.StatusBadRequest)
+		return
+	}
+	from, err := s.modelAt(store, param("from", "active"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := s.modelAt(store, param("to", "live"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, Engine.DiffModels(from, to, top))
+}
+
+// handleModelChange 处理修改快照的请求并记录到审计日志：
+// POST /models/snapshot、POST /models/activate?version=N 和 POST /models/rollback。
+func (s *server) handleModelChange(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		entry := &auditEntry{Time: time.Now().UTC(), Client: "anonymous", Remote: r.RemoteAddr, Action: action, Outcome: "error"}
+		if p, ok := r.Context().Value(principalKey{}).(*principal); ok {
+			entry.Client = p.name
+		}
+		defer func() { s.audit.record(entry, s.state.Load().logger) }()
+
+		var info *Engine.SnapshotInfo
+		var err error
+		switch action {
+		case "snapshot":
+			info, err = s.Snapshot("manual", false)
+		case "activate":
+			var version int
+			if version, err = strconv.Atoi(r.URL.Query().Get("version")); err != nil {
+				http.Error(w, "bad version", http.StatusBadRequest)
+				return
+			}
+			info, err = s.activate(version, false)
+		case "rollback":
+			info, err = s.activate(0, true)
+		}
+		switch {
+		case errors.Is(err, errNoSnapshots):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		entry.Outcome, entry.Version = "done", info.Version
+		writeJSON(w, info)
+	}
+}
+
+// modelCommands 是 model 的子命令。
+var modelCommands = []command{
+	{"inspect", "查看模型的样本数和最有区分度的特征", runModelInspect},
+	{"list", "列出快照", runModelList},
+	{"snapshot", "把模型文件保存为新的快照", runModelSnapshot},
+	{"diff", "比较两个快照", runModelDiff},
+	{"activate", "激活快照，运行中的 serve 会重新加载", runModelActivate},
+	{"rollback", "恢复上一个激活的快照", runModelRollback},
+}
+
+// runModel 实现 model 子命令。
+func runModel(args []string) int {
+	if len(args) > 0 {
+		for _, c := range modelCommands {
+			if c.name == args[0] {
+				return c.run(args[1:])
+			}
+		}
+	}
+	fmt.Fprintln(os.Stderr, "usage: HawkEye-Go model <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range modelCommands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	return EXIT_USAGE
+}
+
+// modelFlags 创建 model 子命令的参数集，包括快照目录。
+func modelFlags(config *Config, name, usage string) *flag.FlagSet {
+	flags := newFlags("model "+name, "model "+name+" "+usage)
+	flags.StringVar(&config.Snapshots.Dir, "snapshots", config.Snapshots.Dir, "快照目录（HAWKEYE_SNAPSHOTS）")
+	return flags
+}
+
+// storeFor 打开 model 子命令使用的快照目录。
+func storeFor(config *Config) (*Engine.ModelStore, error) {
+	if config.Snapshots.Dir == "" {
+		return nil, errNoSnapshots
+	}
+	return Engine.OpenModelStore(config.Snapshots.Dir)
+}
+
+// parseVersion 解析版本号参数，active 表示当前激活的版本。
+func parseVersion(store *Engine.ModelStore, arg string) (int, error) {
+	if arg == "active" {
+		version, err := store.Active()
+		if err == nil && version == 0 {
+			err = errors.New("no active snapshot")
+		}
+		return version, err
+	}
+	version, err := strconv.Atoi(arg)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("bad version %q", arg)
+	}
+	return version, nil
+}
+
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+func runModelInspect(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := modelFlags(config, "inspect", "[-model FILE | -version N] [-top N] [-json]")
+	flags.StringVar(&config.Model, "model", config.Model, "模型文件（HAWKEYE_MODEL）")
+	version := flags.String("version", "", "查看快照而不是模型文件，active 表示激活的快照")
+	top := flags.Int("top", 10, "每个类别列出的特征值数量")
+	asJSON := flags.Bool("json", false, "以JSON输出")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	var model *Engine.NaiveBayes
+	source := config.Model
+	if *version != "" {
+		store, err := storeFor(config)
+		if err != nil {
+			return fail(EXIT_USAGE, err)
+		}
+		v, err := parseVersion(store, *version)
+		if err != nil {
+			return fail(EXIT_USAGE, err)
+		}
+		if model, _, err = store.Load(v); err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+		source = fmt.Sprintf("snapshot %d", v)
+	} else {
+		if config.Model == "" {
+			return fail(EXIT_USAGE, errors.New("no model file"))
+		}
+		if model, err = Engine.LoadModelFromFile(config.Model); err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+	}
+	summary := model.Summary(*top)
+	if *asJSON {
+		printJSON(summary)
+		return EXIT_OK
+	}
+	fmt.Printf("model     %s\n", source)
+	fmt.Printf("samples   %d (black %d, white %d)\n", summary.Samples, summary.Classes["Black"], summary.Classes["White"])
+	fmt.Printf("features  %d names, %d values\n", summary.Features, summary.Values)
+	for _, group := range []struct {
+		title   string
+		weights []Engine.FeatureWeight
+	}{{"black indicators", summary.Black}, {"white indicators", summary.White}} {
+		fmt.Printf("\n%s:\n", group.title)
+		for _, w := range group.weights {
+			fmt.Printf("  %+7.3f  %s=%s  (black %d, white %d)\n", w.LogOdds, w.Feature, w.Value, w.Black, w.White)
+		}
+	}
+	return EXIT_OK
+}
+
+func runModelList(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := modelFlags(config, "list", "[-json]")
+	asJSON := flags.Bool("json", false, "以JSON输出")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	store, err := storeFor(config)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	infos, err := store.List()
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	if *asJSON {
+		printJSON(infos)
+		return EXIT_OK
+	}
+	fmt.Printf("  %7s  %-20s  %-9s  %8s  %8s  %8s  %s\n", "version", "created", "reason", "samples", "black", "white", "f1")
+	for _, info := range infos {
+		mark, f1 := " ", "-"
+		if info.Active {
+			mark = "*"
+		}
+		if info.Metrics != nil {
+			f1 = fmt.Sprintf("%.4f", info.Metrics.F1)
+		}
+		fmt.Printf("%s %7d  %-20s  %-9s  %8d  %8d  %8d  %s\n", mark, info.Version, info.Created.Format(time.RFC3339),
+			info.Reason, info.Samples, info.Classes["Black"], info.Classes["White"], f1)
+	}
+	return EXIT_OK
+}
+
+func runModelSnapshot(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := modelFlags(config, "snapshot", "[-model FILE] [-black FILE] [-white FILE] [-activate]")
+	detectorFlags(flags, config)
+	black, white := fileList(config.Snapshots.Black), fileList(config.Snapshots.White)
+	flags.Var(&black, "black", "评估用的攻击样本文件，可以重复（snapshots.black）")
+	flags.Var(&white, "white", "评估用的正常样本文件，可以重复（snapshots.white）")
+	activate := flags.Bool("activate", false, "激活新的快照")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	config.Snapshots.Black, config.Snapshots.White = black, white
+	store, err := storeFor(config)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	if config.Model == "" {
+		return fail(EXIT_USAGE, errors.New("no model file"))
+	}
+	model, err := Engine.LoadModelFromFile(config.Model)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	rules, err := loadRules(config)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	metrics, err := evaluateModel(config, rules, model)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	info, err := store.Save(model, "import", metrics)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	if *activate {
+		if _, err := store.Activate(info.Version); err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "saved %s as snapshot %d\n", config.Model, info.Version)
+	return EXIT_OK
+}
+
+func runModelDiff(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := modelFlags(config, "diff", "[-top N] [-json] FROM [TO]")
+	top := flags.Int("top", 10, "列出的变化最大的特征值数量")
+	asJSON := flags.Bool("json", false, "以JSON输出")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+	store, err := storeFor(config)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	specs := append(flags.Args(), "active")[:2]
+	var models [2]*Engine.NaiveBayes
+	for i, spec := range specs {
+		version, err := parseVersion(store, spec)
+		if err != nil {
+			return fail(EXIT_USAGE, err)
+		}
+		if models[i], _, err = store.Load(version); err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+	}
+	diff := Engine.DiffModels(models[0], models[1], *top)
+	if *asJSON {
+		printJSON(diff)
+		return EXIT_OK
+	}
+	fmt.Printf("%s -> %s\n", specs[0], specs[1])
+	fmt.Printf("samples   %+d (black %+d, white %+d)\n", diff.Samples, diff.Classes["Black"], diff.Classes["White"])
+	fmt.Printf("values    %d added, %d removed\n", diff.Added, diff.Removed)
+	if len(diff.Changes) > 0 {
+		fmt.Printf("\nlargest changes:\n")
+	}
+	for _, c := range diff.Changes {
+		fmt.Printf("  %+7.3f -> %+7.3f  %s=%s  (black %+d, white %+d)\n", c.Before, c.After, c.Feature, c.Value, c.Black, c.White)
+	}
+	return EXIT_OK
+}
+
+func runModelActivate(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := modelFlags(config, "activate", "VERSION")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+	store, err := storeFor(config)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	version, err := parseVersion(store, flags.Arg(0))
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	// 激活前检查快照是否完整，避免服务重新加载时失败
+	if _, _, err := store.Load(version); err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	if _, err := store.Activate(version); err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	fmt.Fprintf(os.Stderr, "activated snapshot %d\n", version)
+	return EXIT_OK
+}
+
+func runModelRollback(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := modelFlags(config, "rollback", "")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	store, err := storeFor(config)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	info, err := store.Rollback()
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	fmt.Fprintf(os.Stderr, "rolled back to snapshot %d\n", info.Version)
+	return EXIT_OK
+}
+
+func TestModelSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	black := filepath.Join(dir, "black.txt")
+	white := filepath.Join(dir, "white.txt")
+	os.WriteFile(black, []byte("1' OR 1=1--\n1 UNION SELECT password FROM users\n"), 0644)
+	os.WriteFile(white, []byte("alice\n42\n"), 0644)
+	config := defaultConfig()
+	config.Model = ""
+	config.Snapshots = SnapshotConfig{Dir: filepath.Join(dir, "models"), Keep: 1, Black: []string{black}, White: []string{white}}
+	config.Auth.Anonymous = []Scope{SCOPE_READ, SCOPE_WRITE, SCOPE_ADMIN}
+	configure := func() (*Config, error) { return config, nil }
+	s, err := newServer(config, false, configure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := s.routes()
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	snapshot := func() *Engine.SnapshotInfo {
+		w := request("POST", "/models/snapshot", "")
+		var info Engine.SnapshotInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &info); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("Snapshot failed: %d %s", w.Code, w.Body.String())
+		}
+		return &info
+	}
+
+	// 还没有模型时不能生成快照
+	if w := request("POST", "/models/snapshot", ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected conflict without a model, got %d", w.Code)
+	}
+	request("POST", "/blackdata", "1' OR 1=1--\n")
+	request("POST", "/whitedata", "alice\n")
+	first := snapshot()
+	if first.Version != 1 || !first.Active || first.Samples != 2 || first.Metrics == nil || first.Metrics.TruePositives == 0 {
+		t.Errorf("Unexpected first snapshot %+v", first)
+	}
+	// 没有新的样本时定期快照不生成新版本
+	if info, err := s.Snapshot("periodic", true); info != nil || err != nil {
+		t.Errorf("Expected no periodic snapshot without training, got %+v %v", info, err)
+	}
+
+	request("POST", "/blackdata", "1 UNION SELECT password FROM users\n")
+	if info, err := s.Snapshot("periodic", true); err != nil || info == nil || info.Version != 2 || info.Parent != 1 {
+		t.Fatalf("Expected periodic snapshot 2, got %+v %v", info, err)
+	}
+	// 激活快照不会被当作外部修改重新加载
+	if reloaded, err := s.Reload(false); reloaded || err != nil {
+		t.Errorf("Unexpected reload after snapshot %v %v", reloaded, err)
+	}
+	request("POST", "/blackdata", "1; DROP TABLE users\n")
+	var diff Engine.ModelDiff
+	if w := request("GET", "/models/diff?from=1&to=live", ""); json.Unmarshal(w.Body.Bytes(), &diff) != nil || diff.Samples != 2 || diff.Classes["Black"] != 2 {
+		t.Errorf("Unexpected diff %s", w.Body.String())
+	}
+
+	// 回滚丢弃上次快照后的训练，恢复版本1
+	if w := request("POST", "/models/rollback", ""); w.Code != http.StatusOK {
+		t.Fatalf("Rollback failed: %d %s", w.Code, w.Body.String())
+	}
+	if samples := s.state.Load().model.Summary(0).Samples; samples != 2 {
+		t.Errorf("Expected the model of version 1 after rollback, got %d samples", samples)
+	}
+	if w := request("POST", "/models/activate?version=9", ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected conflict activating a missing version, got %d", w.Code)
+	}
+
+	// 其他进程激活的快照在重新加载时生效
+	s.state.Load().store.Activate(2)
+	os.Chtimes(activeSnapshotPath(config), time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if reloaded, err := s.Reload(false); !reloaded || err != nil || s.state.Load().model.Summary(0).Samples != 3 {
+		t.Errorf("Expected version 2 after reload, got %v %v", reloaded, err)
+	}
+
+	// 新的服务加载激活的快照
+	restarted, err := newServer(config, false, configure)
+	if err != nil || restarted.state.Load().model.Summary(0).Samples != 3 {
+		t.Errorf("Expected the restarted server to load version 2, got %v", err)
+	}
+
+	// 回滚历史中的版本1和2不会删除，只删除回滚时放弃的版本3
+	snapshot()
+	if w := request("POST", "/models/rollback", ""); w.Code != http.StatusOK {
+		t.Fatalf("Rollback failed: %d %s", w.Code, w.Body.String())
+	}
+	snapshot()
+	var infos []*Engine.SnapshotInfo
+	if w := request("GET", "/models", ""); json.Unmarshal(w.Body.Bytes(), &infos) != nil || len(infos) != 3 || infos[1].Version != 2 || infos[2].Version != 4 || !infos[2].Active {
+		t.Errorf("Expected pruned snapshots with the newest active, got %s", w.Body.String())
+	}
+	if w := request("POST", "/models/rollback", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected rollback after pruning, got %d %s", w.Code, w.Body.String())
+	}
+
+	// 没有配置快照目录时接口返回404
+	config.Snapshots = SnapshotConfig{}
+	plain, err := newServer(config, false, configure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	plain.routes().ServeHTTP(w, httptest.NewRequest("GET", "/models", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without snapshots, got %d", w.Code)
+	}
+}
@@ -0,0 +1,773 @@
+package main
+
+import (
+	"HawkEye-Go/src/Engine"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// serverState 是 serve 某一时刻的配置和检测器。重新加载时整体替换，进行中的请求继续使用原来的状态。
+type serverState struct {
+	config    *Config
+	rules     *Engine.RuleSet
+	model     *Engine.NaiveBayes
+	endpoints map[string]*Engine.RequestInspector // 每个接口的检查器，键见 endpoints
+	logger    *log.Logger
+	logFile   *os.File // 日志写到标准错误时为nil
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool // 没有配置 tls.client_ca 时为nil
+	auth      *authenticator
+	store     *Engine.ModelStore // 没有配置 snapshots.dir 时为nil
+}
+
+// setModel 设置模型并为每个接口创建检查器，接口设置了阈值时使用它代替 policy.threshold。
+func (st *serverState) setModel(model *Engine.NaiveBayes) {
+	st.model = model
+	st.endpoints = make(map[string]*Engine.RequestInspector)
+	for _, name := range endpoints {
+		detector := detectorFor(st.config, st.rules, model)
+		if threshold, ok := st.config.Thresholds[name]; ok {
+			detector.Policy.Threshold = threshold
+		}
+		inspector := Engine.NewRequestInspector(detector)
+		inspector.Normalization = st.config.Normalize
+		st.endpoints[name] = inspector
+	}
+}
+
+// open 打开日志文件并读取证书。
+func (st *serverState) open() error {
+	st.auth = newAuthenticator(st.config.Auth)
+	st.logger = log.New(os.Stderr, "", log.LstdFlags)
+	if st.config.Log.File != "" {
+		file, err := os.OpenFile(st.config.Log.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		st.logFile = file
+		st.logger = log.New(file, "", log.LstdFlags)
+	}
+	if st.config.TLS.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(st.config.TLS.Cert, st.config.TLS.Key)
+		if err != nil {
+			st.close()
+			return err
+		}
+		st.cert = &cert
+	}
+	clientCAs, err := loadClientCAs(st.config.TLS.ClientCA)
+	if err != nil {
+		st.close()
+		return err
+	}
+	st.clientCAs = clientCAs
+	if st.store, err = openSnapshots(st.config); err != nil {
+		st.close()
+		return err
+	}
+	return nil
+}
+
+func (st *serverState) close() {
+	if st.logFile != nil {
+		st.logFile.Close()
+	}
+}
+
+// server 是 serve 子命令的HTTP服务。训练接口会修改模型，因此检查和训练互斥。
+type server struct {
+	mu        sync.RWMutex // 训练时持有写锁，检查时持有读锁，替换状态时也持有写锁
+	state     atomic.Pointer[serverState]
+	save      bool                    // 训练后把模型保存到配置的模型文件
+	configure func() (*Config, error) // 重新读取配置文件、环境变量和命令行参数
+	limiter   *rateLimiter
+	failures  *rateLimiter // 按来源IP限制认证失败，见 authFailureLimit
+	audit     *auditLog // 审计日志文件在启动时打开，修改路径需要重新启动
+
+	reloadMu sync.Mutex           // 串行化重新加载，保护 modTimes
+	modTimes map[string]time.Time // 上次加载时配置、模型、规则、证书和激活快照文件的修改时间
+
+	snapshotMu  sync.Mutex   // 串行化快照的生成和激活
+	trained     atomic.Int64 // 处理过的训练请求数
+	snapshotted atomic.Int64 // 内存中的模型与快照一致时的 trained
+}
+
+// newServer 按配置加载规则和模型，配置了快照目录时优先加载激活的快照。
+func newServer(config *Config, save bool, configure func() (*Config, error)) (*server, error) {
+	rules, err := loadRules(config)
+	if err != nil {
+		return nil, err
+	}
+	st := &serverState{config: config, rules: rules}
+	if err := st.open(); err != nil {
+		return nil, err
+	}
+	model, snapshot, err := loadServeModel(config, st.store)
+	if err != nil {
+		st.close()
+		return nil, err
+	}
+	st.setModel(model)
+	if snapshot != nil {
+		st.logger.Printf("loaded snapshot %d (%d samples)", snapshot.Version, snapshot.Samples)
+	}
+	audit, err := openAuditLog(config.Log.Audit)
+	if err != nil {
+		st.close()
+		return nil, err
+	}
+	if config.Auth.configured() && config.TLS.Cert == "" {
+		st.logger.Printf("warning: API keys are sent in clear text without tls")
+	}
+	for _, scope := range st.auth.anonymous {
+		if scope != SCOPE_READ {
+			st.logger.Printf("warning: anonymous clients have %s access, anyone can change the model", scope)
+			break
+		}
+	}
+	s := &server{
+		save:      save,
+		configure: configure,
+		limiter:   newRateLimiter(config.RateLimit),
+		failures:  newRateLimiter(authFailureLimit),
+		audit:     audit,
+		modTimes:  modTimes(config),
+	}
+	s.state.Store(st)
+	return s, nil
+}
+
+// modTimes 返回配置引用的文件的修改时间，文件不存在时为零值。
+func modTimes(config *Config) map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, path := range []string{config.path, config.Model, config.Rules, config.TLS.Cert, config.TLS.Key, config.TLS.ClientCA, activeSnapshotPath(config)} {
+		if path == "" {
+			continue
+		}
+		var modTime time.Time
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		times[path] = modTime
+	}
+	return times
+}
+
+func sameTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, t := range a {
+		if u, ok := b[path]; !ok || !t.Equal(u) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reload 重新读取配置并替换状态，force 为false时只在文件修改后重新加载，返回是否替换了状态。
+// 模型文件、快照目录和激活的快照都没有变化时保留内存中的模型，因此在线训练的样本不会丢失；
+// 加载失败时继续使用原来的状态。监听地址的修改和TLS的开关需要重新启动才能生效。
+func (s *server) Reload(force bool) (bool, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	current := s.state.Load()
+	if !force && sameTimes(s.modTimes, modTimes(current.config)) {
+		return false, nil
+	}
+	previous := s.modTimes
+	// 加载失败时也记录修改时间，文件再次修改前不会重试
+	s.modTimes = modTimes(current.config)
+
+	config, err := s.configure()
+	if err != nil {
+		return false, err
+	}
+	times := modTimes(config)
+	rules, err := loadRules(config)
+	if err != nil {
+		return false, err
+	}
+	active := activeSnapshotPath(config)
+	keepModel := config.Model == current.config.Model && times[config.Model].Equal(previous[config.Model]) &&
+		config.Snapshots.Dir == current.config.Snapshots.Dir && times[active].Equal(previous[active])
+	next := &serverState{config: config, rules: rules}
+	if err := next.open(); err != nil {
+		return false, err
+	}
+	var model *Engine.NaiveBayes
+	if !keepModel {
+		var snapshot *Engine.SnapshotInfo
+		if model, snapshot, err = loadServeModel(config, next.store); err != nil {
+			next.close()
+			return false, err
+		}
+		if snapshot != nil {
+			next.logger.Printf("loaded snapshot %d (%d samples)", snapshot.Version, snapshot.Samples)
+		}
+	}
+	if config.Listen != current.config.Listen {
+		next.logger.Printf("listen address changed to %s, restart to apply", config.Listen)
+	}
+	if (next.cert == nil) != (current.cert == nil) {
+		next.logger.Printf("tls enabled or disabled, restart to apply")
+		next.cert = current.cert
+	}
+	if config.Log.Audit != current.config.Log.Audit {
+		next.logger.Printf("audit log changed to %s, restart to apply", config.Log.Audit)
+	}
+	if config.Snapshots.Interval != current.config.Snapshots.Interval {
+		next.logger.Printf("snapshot interval changed to %v, restart to apply", config.Snapshots.Interval)
+	}
+	s.limiter.configure(config.RateLimit)
+
+	s.mu.Lock()
+	if keepModel {
+		model = s.state.Load().model
+	} else {
+		s.snapshotted.Store(s.trained.Load())
+	}
+	next.setModel(model)
+	s.state.Store(next)
+	s.mu.Unlock()
+	s.modTimes = times
+	// 仍在使用原来状态的请求写日志会失败，不影响请求本身
+	current.close()
+	return true, nil
+}
+
+// reload 重新加载并记录结果。
+func (s *server) reload(force bool) {
+	reloaded, err := s.Reload(force)
+	logger := s.state.Load().logger
+	if err != nil {
+		logger.Printf("reload failed, keeping the current configuration: %v", err)
+	} else if reloaded {
+		logger.Printf("configuration reloaded")
+	}
+}
+
+// watch 在收到 SIGHUP 时重新加载，interval 大于0时还定期检查文件是否修改。返回的函数停止检查。
+func (s *server) watch(interval time.Duration) func() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		tick = ticker.C
+	}
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hup:
+				s.reload(true)
+			case <-tick:
+				s.reload(false)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(hup)
+		if ticker != nil {
+			ticker.Stop()
+		}
+		close(stop)
+	}
+}
+
+// touch 在服务自己写入文件后更新记录的修改时间，避免随后的检查重新加载同样的内容。
+func (s *server) touch(path string) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.stamp(path)
+}
+
+// stamp 与 touch 相同，调用者持有 reloadMu。
+func (s *server) stamp(path string) {
+	if info, err := os.Stat(path); err == nil {
+		if _, ok := s.modTimes[path]; ok {
+			s.modTimes[path] = info.ModTime()
+		}
+	}
+}
+
+func (s *server) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := s.state.Load().cert; cert != nil {
+		return cert, nil
+	}
+	return nil, errors.New("no certificate")
+}
+
+// tlsConfig 为每个连接返回当前的证书和客户端CA，重新加载后新的连接立即使用新的配置。
+func (s *server) tlsConfig(*tls.ClientHelloInfo) (*tls.Config, error) {
+	config := &tls.Config{GetCertificate: s.getCertificate, MinVersion: tls.VersionTLS12}
+	if clientCAs := s.state.Load().clientCAs; clientCAs != nil {
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// trainingLabels 是训练接口和它们的标签。
+var trainingLabels = map[string]string{"/blackdata": "Black", "/whitedata": "White"}
+
+type principalKey struct{}
+
+// auditAction 返回请求在审计日志中的操作。
+func auditAction(path string) string {
+	if _, ok := trainingLabels[path]; ok {
+		return "train"
+	}
+	if action := strings.TrimPrefix(path, "/models/"); action != path {
+		return action
+	}
+	return "list"
+}
+
+// authorize 认证请求、按客户端限速并检查权限，训练和模型管理请求被拒绝时记录到审计日志。
+func (s *server) authorize(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st := s.state.Load()
+		deny := func(p *principal, outcome string, status int) {
+			if scope != SCOPE_READ {
+				name := "anonymous"
+				if p != nil {
+					name = p.name
+				}
+				s.audit.record(&auditEntry{
+					Time: time.Now().UTC(), Client: name, Remote: r.RemoteAddr, Action: auditAction(r.URL.Path),
+					Label: trainingLabels[r.URL.Path], Outcome: outcome,
+				}, st.logger)
+			}
+			if status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="hawkeye"`)
+			}
+			http.Error(w, http.StatusText(status), status)
+		}
+		// 认证失败过多的来源IP在检查凭据之前就被拒绝，猜中的密钥也不会被接受
+		ip := "ip:" + remoteHost(r)
+		if ok, wait := s.failures.check(ip); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			deny(nil, "rate_limited", http.StatusTooManyRequests)
+			return
+		}
+		p, err := st.auth.authenticate(r)
+		if err != nil {
+			s.failures.allow(ip)
+			deny(nil, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if ok, wait := s.limiter.allow(p.id); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			deny(p, "rate_limited", http.StatusTooManyRequests)
+			return
+		}
+		if !p.has(scope) {
+			if p.anon {
+				deny(p, "unauthorized", http.StatusUnauthorized)
+			} else {
+				deny(p, "forbidden", http.StatusForbidden)
+			}
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	}
+}
+
+// maxPayloadSize 是预测和训练接口读取的请求体上限。
+const maxPayloadSize = 1 << 20
+
+// handleTrainingData 把请求体中的每一行作为一个样本训练模型，label 为 Black 或 White。
+func (s *server) handleTrainingData(label string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		entry := &auditEntry{Time: time.Now().UTC(), Client: "anonymous", Remote: r.RemoteAddr, Action: "train", Label: label, Outcome: "error"}
+		if p, ok := r.Context().Value(principalKey{}).(*principal); ok {
+			entry.Client = p.name
+		}
+		defer func() { s.audit.record(entry, s.state.Load().logger) }()
+
+		// 超出上限的训练数据整体拒绝，不用截断的部分训练
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, err.Error(), bodyErrorStatus(err))
+			return
+		}
+		entry.SHA256 = sha256Hex(body)
+		var samples []string
+		for _, line := range strings.Split(string(body), "\n") {
+			if sample := strings.TrimSpace(line); sample != "" {
+				samples = append(samples, sample)
+				entry.Sample = append(entry.Sample, sha256Hex([]byte(sample)))
+			}
+		}
+
+		// 特征提取解析的是不可信的载荷，在加锁之前完成
+		features := make([]map[string]string, len(samples))
+		for i, sample := range samples {
+			features[i] = Engine.ExtractFeatures(sample)
+		}
+		st, saved, err := s.train(features, label)
+		if err != nil {
+			http.Error(w, "save model: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if saved {
+			s.touch(st.config.Model)
+		}
+		entry.Outcome, entry.Samples = "trained", len(samples)
+		writeJSON(w, map[string]int{"trained": len(samples)})
+	}
+}
+
+// handlePredictionData 检查一个载荷：GET 的 q 参数、JSON请求体的 payload 字段或者整个请求体。
+func (s *server) handlePredictionData(w http.ResponseWriter, r *http.Request) {
+	var payload string
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); {
+	case r.Method == http.MethodGet:
+		payload = r.URL.Query().Get("q")
+	case mediaType == "application/json":
+		var request struct {
+			Payload string `json:"payload"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxPayloadSize)).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload = request.Payload
+	default:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload = string(body)
+	}
+	inspector := s.state.Load().endpoints["predict"]
+	writeJSON(w, s.checkPayload(inspector, payload))
+}
+
+// checkPayload 在读锁下检查一个载荷，检查过程中的panic也会释放读锁。
+func (s *server) checkPayload(inspector *Engine.RequestInspector, payload string) *Engine.ParameterVerdict {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return inspector.CheckParameter(Engine.Parameter{Source: "payload", Name: "payload", Value: payload})
+}
+
+// train 在写锁下用提取好的特征训练模型，按需保存模型文件，返回训练时使用的状态。
+func (s *server) train(features []map[string]string, label string) (st *serverState, saved bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st = s.state.Load()
+	if st.model == nil {
+		// 还没有模型时创建一个，检测器从只使用规则恢复为配置的策略
+		next := *st
+		next.setModel(Engine.NewNaiveBayes())
+		st = &next
+		s.state.Store(st)
+	}
+	for _, f := range features {
+		st.model.Train(f, label)
+	}
+	if len(features) > 0 {
+		s.trained.Add(1)
+	}
+	saved = s.save && st.config.Model != "" && len(features) > 0
+	if saved {
+		err = st.model.SaveToFile(st.config.Model)
+	}
+	return st, saved, err
+}
+
+// handleInspect 检查请求中的候选注入点，返回每个参数的结论。
+// Content-Type 为 message/http 时请求体是要检查的原始请求报文，否则检查发到 /inspect 的请求本身。
+func (s *server) handleInspect(w http.ResponseWriter, r *http.Request) {
+	inspector := s.state.Load().endpoints["inspect"]
+	// 读取和解析请求在加锁之前完成，慢速客户端不会一直占用读锁
+	target := r
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "message/http" {
+		dump, err := io.ReadAll(http.MaxBytesReader(w, r.Body, inspector.MaxBodySize))
+		if err != nil {
+			http.Error(w, err.Error(), bodyErrorStatus(err))
+			return
+		}
+		if target, err = http.ReadRequest(bufio.NewReader(bytes.NewReader(dump))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	parameters, err := inspector.Extract(target)
+	if err != nil {
+		http.Error(w, err.Error(), bodyErrorStatus(err))
+		return
+	}
+	writeJSON(w, s.report(inspector, target, parameters))
+}
+
+// report 在读锁下检查已经提取的参数。
+func (s *server) report(inspector *Engine.RequestInspector, r *http.Request, parameters []Engine.Parameter) *Engine.InspectionReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return inspector.Report(r, parameters)
+}
+
+// bodyErrorStatus 返回读取请求体失败时的状态码，请求体超过上限时为413。
+func bodyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || errors.Is(err, Engine.ErrBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	for path, label := range trainingLabels {
+		mux.HandleFunc(path, s.authorize(SCOPE_WRITE, s.handleTrainingData(label)))
+	}
+	mux.HandleFunc("/predict", s.authorize(SCOPE_READ, s.handlePredictionData))
+	mux.HandleFunc("/inspect", s.authorize(SCOPE_READ, s.handleInspect))
+	mux.HandleFunc("/models", s.authorize(SCOPE_ADMIN, s.handleModels))
+	mux.HandleFunc("/models/diff", s.authorize(SCOPE_ADMIN, s.handleModelDiff))
+	for _, action := range []string{"snapshot", "activate", "rollback"} {
+		mux.HandleFunc("/models/"+action, s.authorize(SCOPE_ADMIN, s.handleModelChange(action)))
+	}
+	return s.logRequests(mux)
+}
+
+// statusRecorder 记录响应的状态码。
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests 在配置了 log.requests 时记录每个请求。
+func (s *server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := s.state.Load()
+		if !st.config.Log.Requests {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		st.logger.Printf("%s %s %s %d %v", r.RemoteAddr, r.Method, r.URL.Path, recorder.status, time.Since(start))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// serveFlags 定义 serve 的参数。重新加载时用同样的参数再解析一次，命令行参数仍然优先于配置文件。
+func serveFlags(config *Config) (*flag.FlagSet, *bool) {
+	flags := newFlags("serve", "serve [flags]")
+	detectorFlags(flags, config)
+	flags.StringVar(&config.Listen, "listen", config.Listen, "监听地址（HAWKEYE_LISTEN）")
+	flags.StringVar(&config.TLS.Cert, "tls-cert", config.TLS.Cert, "TLS证书文件（HAWKEYE_TLS_CERT）")
+	flags.StringVar(&config.TLS.Key, "tls-key", config.TLS.Key, "TLS私钥文件（HAWKEYE_TLS_KEY）")
+	flags.StringVar(&config.TLS.ClientCA, "tls-client-ca", config.TLS.ClientCA, "验证客户端证书的CA文件（HAWKEYE_TLS_CLIENT_CA）")
+	flags.StringVar(&config.Log.Audit, "audit-log", config.Log.Audit, "训练提交的审计日志（HAWKEYE_AUDIT_LOG）")
+	flags.StringVar(&config.Snapshots.Dir, "snapshots", config.Snapshots.Dir, "模型快照目录，启动时加载激活的快照（HAWKEYE_SNAPSHOTS）")
+	flags.DurationVar(&config.Snapshots.Interval, "snapshot-interval", config.Snapshots.Interval, "有新的训练样本时生成快照的间隔，为0时只在请求 /models/snapshot 时生成")
+	flags.DurationVar(&config.Watch, "watch", config.Watch, "检查配置、模型和规则文件修改的间隔，为0时只在收到 SIGHUP 时重新加载")
+	save := flags.Bool("save", false, "训练接口收到样本后把模型保存到 -model 指定的文件")
+	return flags, save
+}
+
+// runServe 启动检测服务。收到 SIGHUP 或文件修改后重新加载配置，收到 SIGINT 或 SIGTERM 时等待进行中的请求结束后退出。
+func runServe(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags, save := serveFlags(config)
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if err := config.validate(); err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	if *save && config.Model == "" {
+		return fail(EXIT_USAGE, errors.New("-save requires -model"))
+	}
+	configure := func() (*Config, error) {
+		config, err := loadConfig(args)
+		if err != nil {
+			return nil, err
+		}
+		flags, _ := serveFlags(config)
+		flags.SetOutput(io.Discard)
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		return config, config.validate()
+	}
+
+	s, err := newServer(config, *save, configure)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	defer s.audit.close()
+	srv := &http.Server{Addr: config.Listen, Handler: s.routes()}
+	if config.TLS.Cert != "" {
+		srv.TLSConfig = &tls.Config{GetCertificate: s.getCertificate, GetConfigForClient: s.tlsConfig}
+	}
+	stop := s.watch(config.Watch)
+	defer stop()
+	stopSnapshots := s.snapshotEvery(config.Snapshots.Interval)
+	defer stopSnapshots()
+	return serveUntilSignal(srv)
+}
+
+// serveUntilSignal 运行HTTP服务直到收到退出信号，设置了 TLSConfig 时使用HTTPS。
+func serveUntilSignal(srv *http.Server) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", srv.Addr)
+		if srv.TLSConfig != nil {
+			errs <- srv.ListenAndServeTLS("", "")
+		} else {
+			errs <- srv.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-errs:
+		return fail(EXIT_ERROR, err)
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil {
+		return fail(EXIT_ERROR, fmt.Errorf("shutdown: %v", err))
+	}
+	return EXIT_OK
+}
+
+func TestServerReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hawkeye.yaml")
+	modelPath := filepath.Join(dir, "model.gob")
+	writeConfig := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	writeConfig("model: "+modelPath+"\nthresholds: {predict: 0.2}\nauth: {anonymous: [read, write]}\n", time.Unix(1000, 0))
+	model := Engine.NewNaiveBayes()
+	model.Train(Engine.ExtractFeatures("SELECT name FROM users"), "White")
+	model.Train(Engine.ExtractFeatures("1 AND SLEEP(5)"), "Black")
+	if err := model.SaveToFile(modelPath); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-config", path}
+	configure := func() (*Config, error) { return loadConfig(args) }
+	config, err := configure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newServer(config, true, configure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := s.routes()
+	request := func(method, target, body string) string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return strings.TrimSpace(w.Body.String())
+	}
+	threshold := func(endpoint string) float64 {
+		return s.state.Load().endpoints[endpoint].Detector.Policy.Threshold
+	}
+	if threshold("predict") != 0.2 || threshold("inspect") != Engine.DefaultPolicy.Threshold {
+		t.Errorf("Unexpected thresholds %v %v", threshold("predict"), threshold("inspect"))
+	}
+
+	// 没有修改时不重新加载
+	if reloaded, err := s.Reload(false); reloaded || err != nil {
+		t.Errorf("Unexpected reload %v %v", reloaded, err)
+	}
+	// 在线训练保存的模型不会触发重新加载，修改配置时保留内存中的模型
+	if body := request("POST", "/blackdata", "1' OR 1=1--\n"); body != `{"trained":1}` {
+		t.Errorf("Unexpected training response %s", body)
+	}
+	if reloaded, err := s.Reload(false); reloaded || err != nil {
+		t.Errorf("Unexpected reload after training %v %v", reloaded, err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/whitedata", strings.NewReader(strings.Repeat("alice\n", maxPayloadSize))))
+	if w.Code != http.StatusRequestEntityTooLarge || s.trained.Load() != 1 {
+		t.Errorf("Expected oversized training data to be rejected, got %d", w.Code)
+	}
+	trained := s.state.Load().model
+	writeConfig("model: "+modelPath+"\nthresholds: {predict: 0.7}\nnormalize: [html]\n", time.Unix(2000, 0))
+	if reloaded, err := s.Reload(false); !reloaded || err != nil {
+		t.Errorf("Expected reload, got %v %v", reloaded, err)
+	}
+	if threshold("predict") != 0.7 || s.state.Load().model != trained {
+		t.Errorf("Expected new threshold and the same model, got %v", threshold("predict"))
+	}
+	if body := request("GET", "/predict?q=1%2527", ""); !strings.Contains(body, `"normalized":"1%27"`) {
+		t.Errorf("Expected html-only normalization, got %s", body)
+	}
+	if body := request("GET", "/inspect?id=1%20OR%201=1", ""); !strings.Contains(body, `"block":true`) {
+		t.Errorf("Expected /inspect to block, got %s", body)
+	}
+
+	// 加载失败时保留原来的状态
+	writeConfig("thresholds: {login: 0.5}\n", time.Unix(3000, 0))
+	if _, err := s.Reload(false); err == nil || threshold("predict") != 0.7 {
+		t.Errorf("Expected reload error and the old state, got %v", err)
+	}
+	// 强制重新加载时模型文件没有变化，仍然保留内存中的模型
+	writeConfig("model: "+modelPath+"\n", time.Unix(4000, 0))
+	if reloaded, err := s.Reload(true); !reloaded || err != nil || s.state.Load().model != trained {
+		t.Errorf("Expected forced reload to keep the model, got %v %v", reloaded, err)
+	}
+	// 模型文件被替换后加载新的模型
+	os.Chtimes(modelPath, time.Unix(5000, 0), time.Unix(5000, 0))
+	if reloaded, err := s.Reload(false); !reloaded || err != nil || s.state.Load().model == trained {
+		t.Errorf("Expected the model to be reloaded, got %v %v", reloaded, err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"HawkEye-Go/src/Engine"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// fileList 是可以重复出现的文件参数，如 -black a.txt -black b.txt。
+type fileList []string
+
+func (f *fileList) String() string     { return strings.Join(*f, ",") }
+func (f *fileList) Set(v string) error { *f = append(*f, v); return nil }
+
+// readSamples 读取文件中的样本，每行一个，忽略空行。文件名为 - 时读取标准输入。
+func readSamples(name string, sample func(string)) error {
+	var input io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			sample(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// runTrain 用黑白样本文件训练模型并保存。
+func runTrain(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	var black, white fileList
+	flags := newFlags("train", "train -black FILE -white FILE [-model naive_bayes_model.gob] [-append]")
+	flags.StringVar(&config.Model, "model", config.Model, "保存模型的文件（HAWKEYE_MODEL）")
+	flags.Var(&black, "black", "攻击样本文件，每行一个，可以重复")
+	flags.Var(&white, "white", "正常样本文件，每行一个，可以重复")
+	appendModel := flags.Bool("append", false, "在已有模型的基础上继续训练")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if len(black) == 0 || len(white) == 0 || config.Model == "" {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	model := Engine.NewNaiveBayes()
+	if *appendModel {
+		if model, err = Engine.LoadModelFromFile(config.Model); err != nil {
+			return fail(EXIT_ERROR, err)
+		}
+	}
+	counts := map[string]int{}
+	for label, files := range map[string]fileList{"Black": black, "White": white} {
+		for _, name := range files {
+			err := readSamples(name, func(sample string) {
+				model.Train(Engine.ExtractFeatures(sample), label)
+				counts[label]++
+			})
+			if err != nil {
+				return fail(EXIT_ERROR, err)
+			}
+		}
+	}
+	if err := model.SaveToFile(config.Model); err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	fmt.Fprintf(os.Stderr, "trained on %d black and %d white samples, saved to %s\n", counts["Black"], counts["White"], config.Model)
+	return EXIT_OK
+}
+
+// runPredict 检查参数中的载荷，没有参数时逐行读取标准输入。有载荷被拦截时返回 EXIT_DETECTED。
+func runPredict(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	flags := newFlags("predict", "predict [flags] [PAYLOAD...]")
+	detectorFlags(flags, config)
+	asJSON := flags.Bool("json", false, "以JSON逐行输出结论")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	inspector, err := newInspector(config)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+
+	status := EXIT_OK
+	encoder := json.NewEncoder(os.Stdout)
+	predict := func(payload string) {
+		verdict := inspector.CheckParameter(Engine.Parameter{Source: "payload", Name: "payload", Value: payload})
+		if verdict.Block {
+			status = EXIT_DETECTED
+		}
+		if *asJSON {
+			encoder.Encode(verdict)
+			return
+		}
+		result := "PASS"
+		if verdict.Block {
+			result = "BLOCK"
+		}
+		fmt.Printf("%s\t%.3f\t%s", result, verdict.Score, payload)
+		if verdict.Reason != "" {
+			fmt.Printf("\t(%s)", verdict.Reason)
+		}
+		fmt.Println()
+	}
+	if flags.NArg() > 0 {
+		for _, payload := range flags.Args() {
+			predict(payload)
+		}
+		return status
+	}
+	if err := readSamples("-", predict); err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+	return status
+}
+
+// runEval 在标注好的黑白样本上评估检测器，输出混淆矩阵和各项指标。
+func runEval(args []string) int {
+	config, err := loadConfig(args)
+	if err != nil {
+		return fail(EXIT_USAGE, err)
+	}
+	var black, white fileList
+	flags := newFlags("eval", "eval -black FILE -white FILE [flags]")
+	detectorFlags(flags, config)
+	flags.Var(&black, "black", "攻击样本文件，每行一个，可以重复")
+	flags.Var(&white, "white", "正常样本文件，每行一个，可以重复")
+	asJSON := flags.Bool("json", false, "以JSON输出结果")
+	errorsOnly := flags.Bool("errors", false, "在标准错误输出判断错误的样本")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if len(black) == 0 && len(white) == 0 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+	inspector, err := newInspector(config)
+	if err != nil {
+		return fail(EXIT_ERROR, err)
+	}
+
+	var evaluation Engine.Evaluation
+	for _, attack := range []bool{true, false} {
+		files := white
+		if attack {
+			files = black
+		}
+		for _, name := range files {
+			err := readSamples(name, func(sample string) {
+				blocked := inspector.CheckParameter(Engine.Parameter{Value: sample}).Block
+				evaluation.Record(attack, blocked)
+				if *errorsOnly && attack != blocked {
+					label := "false negative"
+					if blocked {
+						label = "false positive"
+					}
+					fmt.Fprintf(os.Stderr, "%s: %s\n", label, sample)
+				}
+			})
+			if err != nil {
+				return fail(EXIT_ERROR, err)
+			}
+		}
+	}
+	evaluation.Finish()
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(evaluation)
+		return EXIT_OK
+	}
+	fmt.Printf("              predicted attack  predicted normal\n")
+	fmt.Printf("attack        %16d  %16d\n", evaluation.TruePositives, evaluation.FalseNegatives)
+	fmt.Printf("normal        %16d  %16d\n", evaluation.FalsePositives, evaluation.TrueNegatives)
+	fmt.Printf("precision %.4f  recall %.4f  f1 %.4f  accuracy %.4f\n", evaluation.Precision, evaluation.Recall, evaluation.F1, evaluation.Accuracy)
+	return EXIT_OK
+}